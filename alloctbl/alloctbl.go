@@ -17,6 +17,9 @@ type server_AllocTbl struct {
 	i_search int // start searching for unallocated inodes here
 	z_search int // start searching for unallocated zones here
 
+	free_inodes int // the number of unallocated inodes
+	free_zones  int // the number of unallocated zones
+
 	in  chan reqAllocTbl
 	out chan resAllocTbl
 }
@@ -30,10 +33,16 @@ func NewAllocTbl(devinfo *common.DeviceInfo, cache common.BlockCache, devno int)
 		(devinfo.Blocksize / 2) * 8,
 		0,
 		0,
+		0,
+		0,
 		make(chan reqAllocTbl),
 		make(chan resAllocTbl),
 	}
 
+	// Count what is free once, and keep track of it from then on
+	alloc.free_inodes = alloc.count_free(common.IMAP)
+	alloc.free_zones = alloc.count_free(common.ZMAP)
+
 	go alloc.loop()
 	return alloc
 }
@@ -43,6 +52,11 @@ func (alloc *server_AllocTbl) loop() {
 		req := <-alloc.in
 		switch req := req.(type) {
 		case req_AllocTbl_AllocInode:
+			if alloc.devinfo.ReadOnly() {
+				alloc.out <- res_AllocTbl_AllocInode{common.NO_INODE, common.EROFS}
+				continue
+			}
+
 			b := alloc.alloc_bit(common.IMAP, alloc.i_search)
 
 			if b == common.NO_BIT {
@@ -52,8 +66,14 @@ func (alloc *server_AllocTbl) loop() {
 			}
 
 			alloc.i_search = b // next time start here
+			alloc.free_inodes--
 			alloc.out <- res_AllocTbl_AllocInode{b, nil}
 		case req_AllocTbl_AllocZone:
+			if alloc.devinfo.ReadOnly() {
+				alloc.out <- res_AllocTbl_AllocZone{common.NO_ZONE, common.EROFS}
+				continue
+			}
+
 			var bstart int
 
 			if req.zstart <= alloc.devinfo.Firstdatazone {
@@ -76,19 +96,21 @@ func (alloc *server_AllocTbl) loop() {
 			if bit < alloc.z_search || alloc.z_search == common.NO_BIT {
 				alloc.z_search = bit
 			}
+			alloc.free_zones--
 			alloc.out <- res_AllocTbl_AllocZone{(alloc.devinfo.Firstdatazone - 1) + bit, nil}
 		case req_AllocTbl_FreeInode:
-			if req.inum <= 0 || req.inum > alloc.devinfo.Inodes {
+			if req.inum <= 0 || req.inum > alloc.devinfo.Inodes || alloc.devinfo.ReadOnly() {
 				alloc.out <- res_AllocTbl_FreeInode{}
 				continue
 			}
 			alloc.free_bit(common.IMAP, req.inum)
+			alloc.free_inodes++
 			if req.inum < alloc.i_search {
 				alloc.i_search = req.inum
 			}
 			alloc.out <- res_AllocTbl_FreeInode{}
 		case req_AllocTbl_FreeZone:
-			if req.znum < alloc.devinfo.Firstdatazone || req.znum >= alloc.devinfo.Zones {
+			if req.znum < alloc.devinfo.Firstdatazone || req.znum >= alloc.devinfo.Zones || alloc.devinfo.ReadOnly() {
 				alloc.out <- res_AllocTbl_FreeZone{}
 				continue
 			}
@@ -96,11 +118,14 @@ func (alloc *server_AllocTbl) loop() {
 			// Turn this from an absolute zone into a bit number
 			bit := req.znum - (alloc.devinfo.Firstdatazone - 1)
 			alloc.free_bit(common.ZMAP, bit)
+			alloc.free_zones++
 
 			if bit < alloc.z_search || alloc.z_search == common.NO_BIT {
 				alloc.z_search = bit
 			}
 			alloc.out <- res_AllocTbl_FreeZone{}
+		case req_AllocTbl_FreeCount:
+			alloc.out <- res_AllocTbl_FreeCount{alloc.free_inodes, alloc.free_zones}
 		case req_AllocTbl_Shutdown:
 			// This is always successful
 			alive = false
@@ -222,3 +247,38 @@ func (alloc *server_AllocTbl) free_bit(which int, bit_returned int) {
 	bp.Dirty = true
 	alloc.cache.PutBlock(bp, common.MAP_BLOCK)
 }
+
+// Count the number of unallocated bits in a bit map. This is only done when
+// the table is created, the counts are maintained from then on.
+func (alloc *server_AllocTbl) count_free(which int) int {
+	var start_block int // first bit block
+	var map_bits int    // how many bits are there in the bit map
+	var bit_blocks int  // how many blocks are there in the bit map
+
+	if which == common.IMAP {
+		start_block = common.START_BLOCK
+		map_bits = alloc.devinfo.Inodes + 1
+		bit_blocks = alloc.devinfo.ImapBlocks
+	} else {
+		start_block = common.START_BLOCK + alloc.devinfo.ImapBlocks
+		map_bits = alloc.devinfo.Zones - (alloc.devinfo.Firstdatazone - 1)
+		bit_blocks = alloc.devinfo.ZmapBlocks
+	}
+
+	free := 0
+	b := 0 // the bit number of the current bit
+	for block := 0; block < bit_blocks; block++ {
+		bp := alloc.cache.GetBlock(alloc.devno, start_block+block, common.MAP_BLOCK, common.NORMAL)
+		allocTbls := bp.Block.(common.MapBlock)
+		for i := 0; i < len(allocTbls) && b < map_bits; i++ {
+			for bit := uint(0); bit < FS_BITCHUNK_BITS && b < map_bits; bit++ {
+				if allocTbls[i]&(1<<bit) == 0 {
+					free++
+				}
+				b++
+			}
+		}
+		alloc.cache.PutBlock(bp, common.MAP_BLOCK)
+	}
+	return free
+}
@@ -25,6 +25,11 @@ type req_AllocTbl_FreeZone struct {
 type res_AllocTbl_FreeZone struct {
 	Arg0 error
 }
+type req_AllocTbl_FreeCount struct{}
+type res_AllocTbl_FreeCount struct {
+	Arg0 int
+	Arg1 int
+}
 type req_AllocTbl_Shutdown struct{}
 type res_AllocTbl_Shutdown struct {
 	Arg0 error
@@ -46,6 +51,8 @@ func (r req_AllocTbl_FreeInode) is_reqAllocTbl()  {}
 func (r res_AllocTbl_FreeInode) is_resAllocTbl()  {}
 func (r req_AllocTbl_FreeZone) is_reqAllocTbl()   {}
 func (r res_AllocTbl_FreeZone) is_resAllocTbl()   {}
+func (r req_AllocTbl_FreeCount) is_reqAllocTbl()  {}
+func (r res_AllocTbl_FreeCount) is_resAllocTbl()  {}
 func (r req_AllocTbl_Shutdown) is_reqAllocTbl()   {}
 func (r res_AllocTbl_Shutdown) is_resAllocTbl()   {}
 
@@ -58,6 +65,8 @@ var _ reqAllocTbl = req_AllocTbl_FreeInode{}
 var _ resAllocTbl = res_AllocTbl_FreeInode{}
 var _ reqAllocTbl = req_AllocTbl_FreeZone{}
 var _ resAllocTbl = res_AllocTbl_FreeZone{}
+var _ reqAllocTbl = req_AllocTbl_FreeCount{}
+var _ resAllocTbl = res_AllocTbl_FreeCount{}
 var _ reqAllocTbl = req_AllocTbl_Shutdown{}
 var _ resAllocTbl = res_AllocTbl_Shutdown{}
 
@@ -81,6 +90,11 @@ func (s *server_AllocTbl) FreeZone(znum int) error {
 	result := (<-s.out).(res_AllocTbl_FreeZone)
 	return result.Arg0
 }
+func (s *server_AllocTbl) FreeCount() (int, int) {
+	s.in <- req_AllocTbl_FreeCount{}
+	result := (<-s.out).(res_AllocTbl_FreeCount)
+	return result.Arg0, result.Arg1
+}
 func (s *server_AllocTbl) Shutdown() error {
 	s.in <- req_AllocTbl_Shutdown{}
 	result := (<-s.out).(res_AllocTbl_Shutdown)
@@ -38,8 +38,7 @@ type LRUCache struct {
 	in  chan reqBlockCache
 	out chan resBlockCache
 
-	showdebug     bool
-	actuallywrite bool
+	showdebug bool
 }
 
 // NewLRUCache creates a new LRUCache with the given size
@@ -80,7 +79,6 @@ func NewLRUCache(numdevices int, numslots int, numhash int) common.BlockCache {
 	cache.hash_mask = numhash - 1
 
 	cache.showdebug = false
-	cache.actuallywrite = false
 
 	// Start the main processing loop
 	go cache.loop()
@@ -101,9 +99,9 @@ func (c *LRUCache) loop() {
 			c.devinfo[req.devnum] = req.info
 			c.out <- res_BlockCache_MountDevice{nil}
 		case req_BlockCache_UnmountDevice:
-			c.flush(req.devnum)
+			err := c.flush(req.devnum)
 			c.devices[req.devnum] = nil
-			c.out <- res_BlockCache_UnmountDevice{}
+			c.out <- res_BlockCache_UnmountDevice{err}
 		case req_BlockCache_GetBlock:
 			callback := make(chan resBlockCache)
 
@@ -171,15 +169,22 @@ func (c *LRUCache) loop() {
 			c.invalidate(req.devnum)
 			c.out <- res_BlockCache_Invalidate{}
 		case req_BlockCache_Flush:
-			c.flush(req.devnum)
-			c.out <- res_BlockCache_Flush{}
+			err := c.flush(req.devnum)
+			c.out <- res_BlockCache_Flush{err}
+		case req_BlockCache_Sync:
+			err := c.sync(req.devnum, req.bnums)
+			c.out <- res_BlockCache_Sync{err}
 		case req_BlockCache_Shutdown:
+			busy := false
 			for i := 0; i < len(c.devices); i++ {
 				if c.devices[i] != nil {
-					c.out <- res_BlockCache_Shutdown{common.EBUSY}
-					continue
+					busy = true
 				}
 			}
+			if busy {
+				c.out <- res_BlockCache_Shutdown{common.EBUSY}
+				continue
+			}
 			c.out <- res_BlockCache_Shutdown{nil}
 			alive = false
 		}
@@ -196,6 +201,24 @@ func (c *LRUCache) evictBlock() *lru_buf {
 		// Instead we need to return an error, and panic from the handler.
 		return nil
 	}
+
+	// If the block taken is dirty, make it clean by writing it to the disk.
+	// Avoid hysterisis by flushing all other dirty blocks for the same
+	// device. There is no caller to report a failure to, so just log it.
+	if bp.Devnum != common.NO_DEV && bp.Dirty {
+		if err := c.flush(bp.Devnum); err != nil {
+			log.Printf("Failed flushing device %d during eviction: %s", bp.Devnum, err)
+		}
+	}
+
+	// A block that could not be written still holds the only copy of its
+	// data, so take the oldest clean block instead.
+	for bp != nil && bp.Dirty {
+		bp = bp.next
+	}
+	if bp == nil {
+		return nil
+	}
 	c.rm_lru(bp)
 
 	// Remove the block that was just taken from its hash chain
@@ -215,13 +238,6 @@ func (c *LRUCache) evictBlock() *lru_buf {
 		}
 	}
 
-	// If the block taken is dirty, make it clean by writing it to the disk.
-	// Avoid hysterisis by flushing all other dirty blocks for the same
-	// device.
-	if bp.Devnum != common.NO_DEV && bp.Dirty {
-		c.flush(bp.Devnum)
-	}
-
 	return bp
 }
 
@@ -334,29 +350,40 @@ func (c *LRUCache) putBlock(cb *common.CacheBlock, btype common.BlockType) error
 
 	// Some blocks are so important (e.g., inodes, indirect blocks) that they
 	// should be written to the disk immediately to avoid messing up the file
-	// system in the event of a crash.
-	if (btype&common.WRITE_IMMED > 0) && bp.Dirty {
+	// system in the event of a crash. Every block is written immediately on a
+	// device mounted with MS_SYNC.
+	immed := btype&common.WRITE_IMMED > 0 || c.devinfo[bp.Devnum].Flags&common.MS_SYNC != 0
+	if immed && bp.Dirty {
 		blocksize := c.devinfo[bp.Devnum].Blocksize
 		pos := int64(blocksize) * int64(bp.Blocknum)
 		err := c.devices[bp.Devnum].Write(bp.Block, pos)
-		return err
+		if err != nil {
+			return err
+		}
+		bp.Dirty = false
 	}
 
 	return nil
 }
 
 func (c *LRUCache) invalidate(dev int) {
-	for i := 0; i < common.NR_BUFS; i++ {
+	for i := 0; i < len(c.buf); i++ {
 		if c.buf[i].Devnum == dev {
 			c.buf[i].Devnum = common.NO_DEV
+			c.buf[i].Dirty = false
 		}
 	}
 }
 
-func (c *LRUCache) flush(dev int) {
+// Write all of the dirty blocks for the given device back to the device,
+// marking them clean. Every block is tried even if an earlier write fails;
+// the first error reported by the device is returned and any blocks that
+// could not be written are left dirty.
+func (c *LRUCache) flush(dev int) error {
 	// TODO: These should be static (or pre-created) so the file server can't
 	// possible panic due to failed memory allocation.
 	var dirty []*lru_buf
+	var ferr error
 	ndirty := 0
 
 	// TODO: Remove this debug code
@@ -381,15 +408,56 @@ func (c *LRUCache) flush(dev int) {
 		for i := 0; i < ndirty; i++ {
 			bp = dirty[i]
 			pos := blocksize * int64(bp.Blocknum)
-			if c.actuallywrite {
-				err := dev.Write(bp.Block, pos)
-				if err != nil {
-					panic("something went wrong during flushall")
+			if err := dev.Write(bp.Block, pos); err != nil {
+				if ferr == nil {
+					ferr = err
 				}
+				continue
 			}
+			bp.Dirty = false
 		}
 		//c.devs[dev].Scatter(dirty[:ndirty]) // write the list of dirty blocks
 	}
+
+	return ferr
+}
+
+// Write the given blocks of a device back to the device if they are dirty,
+// then ask the device to commit them to stable storage. If 'bnums' is nil,
+// every dirty block for the device is written.
+func (c *LRUCache) sync(dev int, bnums []int) error {
+	if bnums == nil {
+		if err := c.flush(dev); err != nil {
+			return err
+		}
+		return c.devices[dev].Sync()
+	}
+
+	wanted := make(map[int]bool, len(bnums))
+	for _, bnum := range bnums {
+		wanted[bnum] = true
+	}
+
+	var ferr error
+	blocksize := int64(c.devinfo[dev].Blocksize)
+	for i := 0; i < len(c.buf); i++ {
+		bp := c.buf[i]
+		if bp.Dirty && bp.Devnum == dev && wanted[bp.Blocknum] {
+			pos := blocksize * int64(bp.Blocknum)
+			if err := c.devices[dev].Write(bp.Block, pos); err != nil {
+				if ferr == nil {
+					ferr = err
+				}
+				continue
+			}
+			bp.Dirty = false
+		}
+	}
+	if ferr != nil {
+		return ferr
+	}
+
+	return c.devices[dev].Sync()
 }
 
 // Remove a block from its LRU chain
@@ -40,7 +40,16 @@ type res_BlockCache_Invalidate struct{}
 type req_BlockCache_Flush struct {
 	devnum int
 }
-type res_BlockCache_Flush struct{}
+type res_BlockCache_Flush struct {
+	Arg0 error
+}
+type req_BlockCache_Sync struct {
+	devnum int
+	bnums  []int
+}
+type res_BlockCache_Sync struct {
+	Arg0 error
+}
 type req_BlockCache_Shutdown struct{}
 type res_BlockCache_Shutdown struct {
 	Arg0 error
@@ -69,6 +78,8 @@ func (r req_BlockCache_Invalidate) is_reqBlockCache()    {}
 func (r res_BlockCache_Invalidate) is_resBlockCache()    {}
 func (r req_BlockCache_Flush) is_reqBlockCache()         {}
 func (r res_BlockCache_Flush) is_resBlockCache()         {}
+func (r req_BlockCache_Sync) is_reqBlockCache()          {}
+func (r res_BlockCache_Sync) is_resBlockCache()          {}
 func (r req_BlockCache_Shutdown) is_reqBlockCache()      {}
 func (r res_BlockCache_Shutdown) is_resBlockCache()      {}
 func (r res_BlockCache_Async) is_resBlockCache()         {}
@@ -86,6 +97,8 @@ var _ reqBlockCache = req_BlockCache_Invalidate{}
 var _ resBlockCache = res_BlockCache_Invalidate{}
 var _ reqBlockCache = req_BlockCache_Flush{}
 var _ resBlockCache = res_BlockCache_Flush{}
+var _ reqBlockCache = req_BlockCache_Sync{}
+var _ resBlockCache = res_BlockCache_Sync{}
 var _ reqBlockCache = req_BlockCache_Shutdown{}
 var _ resBlockCache = res_BlockCache_Shutdown{}
 var _ resBlockCache = res_BlockCache_Async{}
@@ -121,10 +134,15 @@ func (c *LRUCache) Invalidate(devnum int) {
 	<-c.out
 	return
 }
-func (c *LRUCache) Flush(devnum int) {
+func (c *LRUCache) Flush(devnum int) error {
 	c.in <- req_BlockCache_Flush{devnum}
-	<-c.out
-	return
+	result := (<-c.out).(res_BlockCache_Flush)
+	return result.Arg0
+}
+func (c *LRUCache) Sync(devnum int, bnums []int) error {
+	c.in <- req_BlockCache_Sync{devnum, bnums}
+	result := (<-c.out).(res_BlockCache_Sync)
+	return result.Arg0
 }
 func (c *LRUCache) Shutdown() error {
 	c.in <- req_BlockCache_Shutdown{}
@@ -1,6 +1,7 @@
 package bcache
 
 import (
+	"errors"
 	"github.com/jnwhiteh/minixfs/common"
 	"github.com/jnwhiteh/minixfs/testutils"
 	"sync"
@@ -205,3 +206,161 @@ func TestDoesCache(test *testing.T) {
 	// 	ErrorHere(test, "Failed when closing device: %s", err)
 	// }
 }
+
+// Test that flushing the cache writes dirty blocks back to the device and
+// marks them clean.
+func TestFlushWritesDirty(test *testing.T) {
+	dev, cache := openTestCache(test)
+
+	cb := cache.GetBlock(0, 3, common.FULL_DATA_BLOCK, common.NORMAL)
+	data := cb.Block.(common.FullDataBlock)
+	for i := 0; i < len(data); i++ {
+		data[i] = 0xff
+	}
+	cb.Dirty = true
+	cache.PutBlock(cb, common.FULL_DATA_BLOCK)
+
+	if err := cache.Flush(0); err != nil {
+		testutils.FatalHere(test, "Failed when flushing cache: %s", err)
+	}
+	if cb.Dirty {
+		testutils.ErrorHere(test, "Block still dirty after flush")
+	}
+
+	ondisk := make(common.FullDataBlock, 64)
+	if err := dev.Read(ondisk, 3*64); err != nil {
+		testutils.FatalHere(test, "Failed when reading from device: %s", err)
+	}
+	if ondisk[0] != 0xff || ondisk[63] != 0xff {
+		testutils.ErrorHere(test, "Data on device did not match, expected %x, got %x", 0xff, ondisk[0])
+	}
+
+	closeTestCache(test, dev, cache)
+}
+
+// Test that a device error during a flush is returned to the caller and the
+// block remains dirty.
+func TestFlushError(test *testing.T) {
+	ferr := errors.New("write failed")
+	dev := testutils.NewFailingDevice(testutils.NewTestDevice(test, 64, 100), ferr)
+	cache := NewLRUCache(4, 10, 16)
+	if err := cache.MountDevice(0, dev, getDevInfo(64)); err != nil {
+		testutils.FatalHere(test, "Failed when mounting device into cache: %s", err)
+	}
+
+	cb := cache.GetBlock(0, 3, common.FULL_DATA_BLOCK, common.NORMAL)
+	cb.Dirty = true
+	cache.PutBlock(cb, common.FULL_DATA_BLOCK)
+
+	if err := cache.Flush(0); err != ferr {
+		testutils.ErrorHere(test, "Expected flush error %v, got %v", ferr, err)
+	}
+	if !cb.Dirty {
+		testutils.ErrorHere(test, "Block marked clean after failed flush")
+	}
+
+	cache.Invalidate(0)
+	if err := cache.UnmountDevice(0); err != nil {
+		testutils.ErrorHere(test, "Failed when unmounting device: %s", err)
+	}
+}
+
+// Test that syncing a list of blocks only writes those blocks
+func TestSyncBlocks(test *testing.T) {
+	dev, cache := openTestCache(test)
+
+	cb3 := cache.GetBlock(0, 3, common.FULL_DATA_BLOCK, common.NORMAL)
+	cb4 := cache.GetBlock(0, 4, common.FULL_DATA_BLOCK, common.NORMAL)
+	cb3.Dirty = true
+	cb4.Dirty = true
+	cache.PutBlock(cb3, common.FULL_DATA_BLOCK)
+	cache.PutBlock(cb4, common.FULL_DATA_BLOCK)
+
+	if err := cache.Sync(0, []int{3}); err != nil {
+		testutils.FatalHere(test, "Failed when syncing block: %s", err)
+	}
+	if cb3.Dirty {
+		testutils.ErrorHere(test, "Synced block still dirty")
+	}
+	if !cb4.Dirty {
+		testutils.ErrorHere(test, "Block not in sync list was written")
+	}
+
+	if err := cache.Sync(0, nil); err != nil {
+		testutils.FatalHere(test, "Failed when syncing device: %s", err)
+	}
+	if cb4.Dirty {
+		testutils.ErrorHere(test, "Block still dirty after syncing device")
+	}
+
+	closeTestCache(test, dev, cache)
+}
+
+// A device that fails writes to a single position
+type failAtDevice struct {
+	common.BlockDevice
+	pos int64
+	err error
+}
+
+func (dev *failAtDevice) Write(buf interface{}, pos int64) error {
+	if pos == dev.pos {
+		return dev.err
+	}
+	return dev.BlockDevice.Write(buf, pos)
+}
+
+// Test that a flush writes every block it can when one of them fails, and
+// that a block which could not be written is not reused by the cache.
+func TestEvictFailedBlock(test *testing.T) {
+	ferr := errors.New("write failed")
+	rdev := testutils.NewTestDevice(test, 64, 100)
+	cache := NewLRUCache(4, 10, 16)
+	if err := cache.MountDevice(0, &failAtDevice{rdev, 3 * 64, ferr}, getDevInfo(64)); err != nil {
+		testutils.FatalHere(test, "Failed when mounting device into cache: %s", err)
+	}
+
+	blocks := make([]*common.CacheBlock, 10)
+	for i := 0; i < 10; i++ {
+		blocks[i] = cache.GetBlock(0, i, common.FULL_DATA_BLOCK, common.NORMAL)
+	}
+	for _, bnum := range []int{3, 4} {
+		data := blocks[bnum].Block.(common.FullDataBlock)
+		data[0] = 0xff
+		blocks[bnum].Dirty = true
+	}
+	for i := 0; i < 10; i++ {
+		cache.PutBlock(blocks[i], common.FULL_DATA_BLOCK)
+	}
+
+	// Evict every block that can be evicted, which flushes the device
+	for i := 10; i < 19; i++ {
+		cb := cache.GetBlock(0, i, common.FULL_DATA_BLOCK, common.NORMAL)
+		if cb == blocks[3] {
+			testutils.ErrorHere(test, "Dirty block was reused for block %d", i)
+		}
+		cache.PutBlock(cb, common.FULL_DATA_BLOCK)
+	}
+
+	ondisk := make(common.FullDataBlock, 64)
+	if err := rdev.Read(ondisk, 4*64); err != nil {
+		testutils.FatalHere(test, "Failed when reading from device: %s", err)
+	}
+	if ondisk[0] != 0xff {
+		testutils.ErrorHere(test, "Block after the failed write was not written")
+	}
+
+	cb := cache.GetBlock(0, 3, common.FULL_DATA_BLOCK, common.NORMAL)
+	if cb != blocks[3] || !cb.Dirty || cb.Block.(common.FullDataBlock)[0] != 0xff {
+		testutils.ErrorHere(test, "Block that failed to write lost its data")
+	}
+	cache.PutBlock(cb, common.FULL_DATA_BLOCK)
+
+	if err := cache.Flush(0); err != ferr {
+		testutils.ErrorHere(test, "Expected flush error %v, got %v", ferr, err)
+	}
+	cache.Invalidate(0)
+	if err := cache.UnmountDevice(0); err != nil {
+		testutils.ErrorHere(test, "Failed when unmounting device: %s", err)
+	}
+}
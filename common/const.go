@@ -28,14 +28,16 @@ const (
 	ROOT_DEVICE  = 0 // the root device number
 	ROOT_INODE   = 1 // the root inode number
 	ROOT_PROCESS = 0 // the root process number (dummy)
+	SU_UID       = 0 // the super user's uid
 
 	NR_FILPS   = 128 // # slots in filp table
 	NR_INODES  = 64  // # slots in "in core" inode table
 	NR_PROCS   = 32  // # slots in the process table
 	NR_DEVICES = 8   // # slots in the devices/bitmap tables
 
-	OPEN_MAX = 20 // the maximum number of files that can be opened by a process
+	OPEN_MAX = 20 // the default limit on the number of files a process can have open
 	NAME_MAX = 60 // the maximum size of a filename
+	SYMLOOP  = 16 // the maximum number of symbolic links followed in a path
 
 	// The buffer cache should be made as large as you can afford
 	NR_BUFS     = 1280            // # blocks in the buffer cache
@@ -65,6 +67,11 @@ const (
 	NO_INODE       = -1
 	RESERVED_INODE = -2
 
+	// Flags for the times of an inode that need to be updated
+	ATIME = 002 // set if atime field needs updating
+	CTIME = 004 // set if ctime field needs updating
+	MTIME = 010 // set if mtime field needs updating
+
 	// When a block is released, the type of usage is passed to put_block()
 	WRITE_IMMED = 0100 // block should be written to disk now
 	ONE_SHOT    = 0200 // set if block not likely to be needed soon
@@ -99,6 +106,7 @@ const (
 	O_APPEND   = 02000  // set append mode
 	O_NONBLOCK = 04000  // no delay
 	O_REOPEN   = 010000 // automaticalloy re-open device after driver restart?
+	O_SYNC     = 020000 // write data through to the device before returning
 
 	// File access modes for open() and fcntl().  POSIX Table 6-6.
 	O_RDONLY = 0 // open(name, O_RDONLY) opens read only
@@ -108,6 +116,51 @@ const (
 	// Mask for use with file access modes. POSIX Table 6-7.
 	O_ACCMODE = 03 /* mask for file access modes */
 
+	// Values for the 'whence' argument to lseek()
+	SEEK_SET  = 0 // offset is absolute
+	SEEK_CUR  = 1 // offset is relative to current position
+	SEEK_END  = 2 // offset is relative to end of file
+	SEEK_DATA = 3 // seek to the next data at or after offset
+	SEEK_HOLE = 4 // seek to the next hole at or after offset
+
+	// Flags for mounting a file system
+	MS_RDONLY  = 0001 // mount read-only, any change fails with EROFS
+	MS_NOATIME = 0002 // do not update access times
+	MS_SYNC    = 0004 // write changes through to the device immediately
+
+	// Arguments to the *at calls
+	AT_FDCWD            = -100 // resolve relative paths from the working directory
+	AT_SYMLINK_NOFOLLOW = 0001 // do not follow a symbolic link in the final component
+	AT_REMOVEDIR        = 0002 // remove a directory rather than a file
+
+	// Flags for unmounting a file system
+	MNT_FORCE  = 0001 // revoke open files and move working directories off the device
+	MNT_DETACH = 0002 // detach now, unmount once the device is no longer in use
+
+	// Operations for flock()
+	LOCK_SH = 1 // shared lock
+	LOCK_EX = 2 // exclusive lock
+	LOCK_NB = 4 // fail with EAGAIN rather than waiting
+	LOCK_UN = 8 // remove the lock
+
+	// Commands for fcntl(), including those for byte-range locks
+	F_DUPFD  = 0 // duplicate onto the lowest free descriptor at or above the argument
+	F_GETLK  = 5 // find a lock that would prevent the one described
+	F_SETLK  = 6 // set or clear a lock, failing with EAGAIN on conflict
+	F_SETLKW = 7 // set or clear a lock, waiting for conflicting locks to go
+	F_RDLCK  = 1 // shared or read lock
+	F_WRLCK  = 2 // exclusive or write lock
+	F_UNLCK  = 3 // unlock
+
+	// Events reported to watches
+	IN_CREATE  = 0001 // an entry was created in a directory
+	IN_DELETE  = 0002 // an entry was removed from a directory, or the watched file was deleted
+	IN_MODIFY  = 0004 // the contents of the file changed
+	IN_ATTRIB  = 0010 // the mode, owner, times or link count changed
+	IN_RENAME  = 0020 // an entry was renamed into or out of a directory
+	IN_UNMOUNT = 0040 // the device containing the file was unmounted
+	IN_ALL     = 0077 // all of the above
+
 	NORMAL   = 0 // forces get_block to do disk read
 	NO_READ  = 1 // prevents get_block from doing disk read
 	PREFETCH = 2 // tells get_block not to read or mark dev
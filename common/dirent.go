@@ -0,0 +1,67 @@
+package common
+
+// Dirent describes a single entry in a directory, as returned by the
+// readdir() and getdents() calls.
+type Dirent struct {
+	Inum int    // the inode number of the entry
+	Name string // the name of the entry
+	Type int    // the file type of the entry, e.g. I_REGULAR, or 0 if not read
+}
+
+// Read up to 'count' entries from the directory 'rip', starting at the byte
+// offset 'pos' and skipping any empty slots. If 'count' is zero or less then
+// all remaining entries are read. The file type is not stored in the
+// directory entry, so it is only filled in when 'types' is set, at the cost
+// of fetching the inode of every entry. Returns the entries along with the
+// offset of the first slot that has not been examined.
+func ReadDir(rip *Inode, pos, count int, types bool) ([]Dirent, int, error) {
+	if !rip.IsDirectory() {
+		return nil, pos, ENOTDIR
+	}
+
+	devinfo := rip.Devinfo
+	blocksize := devinfo.Blocksize
+	cache := rip.Bcache
+
+	// Always start at the beginning of a slot
+	pos -= pos % DIR_ENTRY_SIZE
+
+	var entries []Dirent
+	for pos < int(rip.Size) && (count <= 0 || len(entries) < count) {
+		b := ReadMap(rip, pos, cache)
+		if b == NO_BLOCK {
+			// A hole in the directory, skip to the next block
+			pos += blocksize - (pos % blocksize)
+			continue
+		}
+
+		bp := cache.GetBlock(devinfo.Devnum, b, DIRECTORY_BLOCK, NORMAL)
+		dirarr := bp.Block.(DirectoryBlock)
+		for i := (pos % blocksize) / DIR_ENTRY_SIZE; i < len(dirarr); i++ {
+			if pos >= int(rip.Size) || (count > 0 && len(entries) >= count) {
+				break
+			}
+			pos += DIR_ENTRY_SIZE
+
+			dp := &dirarr[i]
+			if dp.Inum != 0 {
+				entries = append(entries, Dirent{int(dp.Inum), dp.String(), 0})
+			}
+		}
+		cache.PutBlock(bp, DIRECTORY_BLOCK)
+	}
+
+	rip.Accessed()
+
+	// Fetch the types once the directory blocks have been released
+	for i := 0; types && i < len(entries); i++ {
+		ip, err := rip.Icache.GetInode(devinfo.Devnum, entries[i].Inum)
+		if err != nil {
+			return entries[:i], pos, err
+		}
+		entries[i].Type = ip.Type()
+		rip.Icache.PutInode(ip)
+	}
+
+	return entries, pos, nil
+}
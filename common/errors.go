@@ -1,23 +1,199 @@
 package common
 
-import "errors"
-
-// The following string constants are taken from the Minix 3.1.0 source,
-// specifically from lib/ansi/errlist.c.
-
-var (
-	EBADF     = errors.New("Bad file number")
-	EBUSY     = errors.New("Resource busy")
-	EEXIST    = errors.New("File exists")
-	EFBIG     = errors.New("File too large")
-	EINVAL    = errors.New("Invalid argument")
-	EISDIR    = errors.New("Is a directory")
-	EMFILE    = errors.New("Too many open files")
-	EMLINK    = errors.New("Too many links")
-	ENFILE    = errors.New("File table overflow")
-	ENOENT    = errors.New("No such file or directory")
-	ENOSPC    = errors.New("No space left on device")
-	ENOTDIR   = errors.New("Not a directory")
-	ENOTEMPTY = errors.New("Directory not empty")
-	EXDEV     = errors.New("Cross-device link")
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// An Errno is an error number, as returned by the system calls. The numbers
+// and strings are taken from the Minix 3.1.0 source, specifically from
+// include/errno.h and lib/ansi/errlist.c, with ELOOP added for symbolic
+// links.
+type Errno int
+
+const (
+	EPERM        Errno = 1  // operation not permitted
+	ENOENT       Errno = 2  // no such file or directory
+	ESRCH        Errno = 3  // no such process
+	EINTR        Errno = 4  // interrupted function call
+	EIO          Errno = 5  // input/output error
+	ENXIO        Errno = 6  // no such device or address
+	E2BIG        Errno = 7  // arg list too long
+	ENOEXEC      Errno = 8  // exec format error
+	EBADF        Errno = 9  // bad file descriptor
+	ECHILD       Errno = 10 // no child process
+	EAGAIN       Errno = 11 // resource temporarily unavailable
+	ENOMEM       Errno = 12 // not enough space
+	EACCES       Errno = 13 // permission denied
+	EFAULT       Errno = 14 // bad address
+	ENOTBLK      Errno = 15 // Extension: not a block special file
+	EBUSY        Errno = 16 // resource busy
+	EEXIST       Errno = 17 // file exists
+	EXDEV        Errno = 18 // improper link
+	ENODEV       Errno = 19 // no such device
+	ENOTDIR      Errno = 20 // not a directory
+	EISDIR       Errno = 21 // is a directory
+	EINVAL       Errno = 22 // invalid argument
+	ENFILE       Errno = 23 // too many open files in system
+	EMFILE       Errno = 24 // too many open files
+	ENOTTY       Errno = 25 // inappropriate I/O control operation
+	ETXTBSY      Errno = 26 // no longer used
+	EFBIG        Errno = 27 // file too large
+	ENOSPC       Errno = 28 // no space left on device
+	ESPIPE       Errno = 29 // invalid seek
+	EROFS        Errno = 30 // read-only file system
+	EMLINK       Errno = 31 // too many links
+	EPIPE        Errno = 32 // broken pipe
+	EDOM         Errno = 33 // domain error (from ANSI C std)
+	ERANGE       Errno = 34 // result too large (from ANSI C std)
+	EDEADLK      Errno = 35 // resource deadlock avoided
+	ENAMETOOLONG Errno = 36 // file name too long
+	ENOLCK       Errno = 37 // no locks available
+	ENOSYS       Errno = 38 // function not implemented
+	ENOTEMPTY    Errno = 39 // directory not empty
+	ELOOP        Errno = 40 // too many levels of symbolic links
+)
+
+var errnoStrings = [...]string{
+	EPERM:        "Operation not permitted",
+	ENOENT:       "No such file or directory",
+	ESRCH:        "No such process",
+	EINTR:        "Interrupted system call",
+	EIO:          "I/O error",
+	ENXIO:        "No such device or address",
+	E2BIG:        "Arg list too long",
+	ENOEXEC:      "Exec format error",
+	EBADF:        "Bad file number",
+	ECHILD:       "No child process",
+	EAGAIN:       "Resource temporarily unavailable",
+	ENOMEM:       "Not enough core",
+	EACCES:       "Permission denied",
+	EFAULT:       "Bad address",
+	ENOTBLK:      "Block device required",
+	EBUSY:        "Resource busy",
+	EEXIST:       "File exists",
+	EXDEV:        "Cross-device link",
+	ENODEV:       "No such device",
+	ENOTDIR:      "Not a directory",
+	EISDIR:       "Is a directory",
+	EINVAL:       "Invalid argument",
+	ENFILE:       "File table overflow",
+	EMFILE:       "Too many open files",
+	ENOTTY:       "Not a typewriter",
+	ETXTBSY:      "Text file busy",
+	EFBIG:        "File too large",
+	ENOSPC:       "No space left on device",
+	ESPIPE:       "Illegal seek",
+	EROFS:        "Read-only file system",
+	EMLINK:       "Too many links",
+	EPIPE:        "Broken pipe",
+	EDOM:         "Math argument",
+	ERANGE:       "Result too large",
+	EDEADLK:      "Resource deadlock avoided",
+	ENAMETOOLONG: "File name too long",
+	ENOLCK:       "No locks available",
+	ENOSYS:       "Function not implemented",
+	ENOTEMPTY:    "Directory not empty",
+	ELOOP:        "Too many levels of symbolic links",
+}
+
+// The equivalent error numbers on the host operating system
+var syscallErrnos = [...]syscall.Errno{
+	EPERM:        syscall.EPERM,
+	ENOENT:       syscall.ENOENT,
+	ESRCH:        syscall.ESRCH,
+	EINTR:        syscall.EINTR,
+	EIO:          syscall.EIO,
+	ENXIO:        syscall.ENXIO,
+	E2BIG:        syscall.E2BIG,
+	ENOEXEC:      syscall.ENOEXEC,
+	EBADF:        syscall.EBADF,
+	ECHILD:       syscall.ECHILD,
+	EAGAIN:       syscall.EAGAIN,
+	ENOMEM:       syscall.ENOMEM,
+	EACCES:       syscall.EACCES,
+	EFAULT:       syscall.EFAULT,
+	ENOTBLK:      syscall.ENOTBLK,
+	EBUSY:        syscall.EBUSY,
+	EEXIST:       syscall.EEXIST,
+	EXDEV:        syscall.EXDEV,
+	ENODEV:       syscall.ENODEV,
+	ENOTDIR:      syscall.ENOTDIR,
+	EISDIR:       syscall.EISDIR,
+	EINVAL:       syscall.EINVAL,
+	ENFILE:       syscall.ENFILE,
+	EMFILE:       syscall.EMFILE,
+	ENOTTY:       syscall.ENOTTY,
+	ETXTBSY:      syscall.ETXTBSY,
+	EFBIG:        syscall.EFBIG,
+	ENOSPC:       syscall.ENOSPC,
+	ESPIPE:       syscall.ESPIPE,
+	EROFS:        syscall.EROFS,
+	EMLINK:       syscall.EMLINK,
+	EPIPE:        syscall.EPIPE,
+	EDOM:         syscall.EDOM,
+	ERANGE:       syscall.ERANGE,
+	EDEADLK:      syscall.EDEADLK,
+	ENAMETOOLONG: syscall.ENAMETOOLONG,
+	ENOLCK:       syscall.ENOLCK,
+	ENOSYS:       syscall.ENOSYS,
+	ENOTEMPTY:    syscall.ENOTEMPTY,
+	ELOOP:        syscall.ELOOP,
+}
+
+func (e Errno) Error() string {
+	if e > 0 && int(e) < len(errnoStrings) {
+		return errnoStrings[e]
+	}
+	return "Error " + strconv.Itoa(int(e))
+}
+
+// Report whether the error is equivalent to 'target', which may be one of
+// the io/fs errors or a syscall.Errno, so that errors.Is can be used.
+func (e Errno) Is(target error) bool {
+	switch target {
+	case fs.ErrPermission:
+		return e == EACCES || e == EPERM
+	case fs.ErrExist:
+		return e == EEXIST || e == ENOTEMPTY
+	case fs.ErrNotExist:
+		return e == ENOENT
+	case errors.ErrUnsupported:
+		return e == ENOSYS
+	}
+	if errno, ok := target.(syscall.Errno); ok {
+		return e.Syscall() == errno
+	}
+	return false
+}
+
+// Convert the error to the equivalent error number on the host operating
+// system, or zero if there is none.
+func (e Errno) Syscall() syscall.Errno {
+	if e > 0 && int(e) < len(syscallErrnos) {
+		return syscallErrnos[e]
+	}
+	return 0
+}
+
+// Convert an error number from the host operating system, returning EIO if
+// there is no equivalent.
+func FromSyscall(errno syscall.Errno) Errno {
+	for e, se := range syscallErrnos {
+		if se == errno && se != 0 {
+			return Errno(e)
+		}
+	}
+	return EIO
+}
+
+// The errors returned by the system calls name the operation and the path
+// or paths that were involved, in the same way as the os package.
+type (
+	PathError    = os.PathError
+	LinkError    = os.LinkError
+	SyscallError = os.SyscallError
 )
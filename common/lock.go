@@ -0,0 +1,29 @@
+package common
+
+// A Flock_t describes a byte-range lock for FcntlFlock
+type Flock_t struct {
+	Type   int   // F_RDLCK, F_WRLCK or F_UNLCK
+	Whence int   // SEEK_SET, SEEK_CUR or SEEK_END
+	Start  int64 // the first byte, relative to Whence
+	Len    int64 // the number of bytes, zero meaning up to the end of the file and beyond
+	Pid    int   // the process holding a conflicting lock, set by F_GETLK
+}
+
+// A Lock is an advisory lock held on the bytes [Start, End) of a file.
+// Locks taken by flock cover the whole file, belong to the open file and
+// only conflict with one another. Those taken by fcntl belong to a process.
+type Lock struct {
+	Owner interface{} // the holder of the lock, compared for equality
+	Pid   int         // the process that took the lock
+	Flock bool        // taken by flock rather than fcntl
+	Type  int         // F_RDLCK, F_WRLCK or F_UNLCK
+	Start int64
+	End   int64 // math.MaxInt64 for a lock that extends past the end of the file
+}
+
+// Check whether two locks held by different owners can not both be held
+func (a Lock) Conflicts(b Lock) bool {
+	return a.Owner != b.Owner && a.Flock == b.Flock &&
+		a.Start < b.End && b.Start < a.End &&
+		(a.Type == F_WRLCK || b.Type == F_WRLCK)
+}
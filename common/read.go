@@ -68,6 +68,30 @@ func ReadMap(rip *Inode, position int, cache BlockCache) int {
 	return b
 }
 
+// Find the offset of the first data (for SEEK_DATA) or the first hole (for
+// SEEK_HOLE) at or after 'pos' by inspecting the zone map. The end of the
+// file counts as a hole. ENXIO is returned if 'pos' is not within the file,
+// or there is no more data after it.
+func SeekHole(rip *Inode, pos, whence int) (int, error) {
+	size := int(rip.Size)
+	if pos < 0 || pos >= size {
+		return 0, ENXIO
+	}
+
+	blocksize := rip.Devinfo.Blocksize
+	for ; pos < size; pos += blocksize - (pos % blocksize) {
+		hole := ReadMap(rip, pos, rip.Bcache) == NO_BLOCK
+		if hole == (whence == SEEK_HOLE) {
+			return pos, nil
+		}
+	}
+
+	if whence == SEEK_HOLE {
+		return size, nil
+	}
+	return 0, ENXIO
+}
+
 // Given a pointer to an indirect block, read one entry with bounds checking
 // on min/max.
 func RdIndir(bp *CacheBlock, index int, cache BlockCache, min, max int) int {
@@ -83,94 +107,54 @@ func RdIndir(bp *CacheBlock, index int, cache BlockCache, min, max int) int {
 	return zone
 }
 
+// Read up to len(b) bytes from the file starting at 'pos'. A short read
+// only happens at the end of the file, and io.EOF is returned once 'pos' is
+// at or beyond the end. Holes in the file read as zeroes.
 func Read(rip *Inode, b []byte, pos int) (int, error) {
 	devinfo := rip.Devinfo
+	size := int(rip.Size)
 
-	// We want to read at most len(b) bytes from the given inode. This data
-	// will almost certainly be split up amongst multiple blocks.
-	curpos := pos
-
-	// Rather than getting fancy, just slice b to contain only enough space
-	// for the data that is available
-	// TODO: Should this rely on the inode size?
-	if curpos+len(b) > int(rip.Size) {
-		b = b[:int(rip.Size)-curpos]
+	if len(b) == 0 {
+		return 0, nil
 	}
-
-	if curpos >= int(rip.Size) {
+	if pos >= size {
 		return 0, io.EOF
 	}
 
-	blocksize := devinfo.Blocksize
-
-	// We can't just start reading at the start of a block, since we may be at
-	// an offset within that block. So work out the chunk to read
-	offset := curpos % blocksize
-	bnum := ReadMap(rip, curpos, rip.Bcache)
-
-	// TODO: Error check this
-	// read the data block and copy the portion of data we need
-	bp := rip.Bcache.GetBlock(devinfo.Devnum, bnum, FULL_DATA_BLOCK, NORMAL)
-	bdata, bok := bp.Block.(FullDataBlock)
-	if !bok {
-		// TODO: Attempt to read from an invalid location, what should happen?
-		return 0, EINVAL
-	}
-
-	if len(b) < blocksize-offset { // this block contains all the data we need
-		for i := 0; i < len(b); i++ {
-			b[i] = bdata[offset+i]
-		}
-		curpos += len(b)
-		rip.Bcache.PutBlock(bp, FULL_DATA_BLOCK)
-		return len(b), nil
-	}
-
-	// we need this entire block, so start riplling
-	var numBytes int = 0
-	for i := 0; i < blocksize-offset; i++ {
-		b[i] = bdata[offset+i]
-		numBytes++
+	// Rather than getting fancy, just slice b to contain only enough space
+	// for the data that is available
+	if pos+len(b) > size {
+		b = b[:size-pos]
 	}
 
-	rip.Bcache.PutBlock(bp, FULL_DATA_BLOCK)
-	curpos += numBytes
+	blocksize := devinfo.Blocksize
+	numBytes := 0
 
-	// At this stage, all reads should be on block boundaries. The ripnal block
-	// will likely be a partial block, so handle that specially.
+	// The first block may be read from an offset, and the last block may only
+	// be partially needed, so work out the chunk to copy from each block.
 	for numBytes < len(b) {
-		bnum = ReadMap(rip, curpos, rip.Bcache)
-		bp := rip.Bcache.GetBlock(devinfo.Devnum, bnum, FULL_DATA_BLOCK, NORMAL)
-		if _, sok := bp.Block.(FullDataBlock); !sok {
-			log.Printf("block num: %d", bp.Blocknum)
-			log.Panicf("When reading block %d for position %d, got IndirectBlock", bnum, curpos)
+		curpos := pos + numBytes
+		offset := curpos % blocksize
+		chunk := blocksize - offset
+		if chunk > len(b)-numBytes {
+			chunk = len(b) - numBytes
 		}
 
-		bdata = bp.Block.(FullDataBlock)
-
-		bytesLeft := len(b) - numBytes // the number of bytes still needed
-
-		// If we only need a portion of this block
-		if bytesLeft < blocksize {
-
-			for i := 0; i < bytesLeft; i++ {
-				b[numBytes] = bdata[i]
-				numBytes++
+		bnum := ReadMap(rip, curpos, rip.Bcache)
+		if bnum == NO_BLOCK {
+			for i := 0; i < chunk; i++ {
+				b[numBytes+i] = 0
 			}
-
-			curpos += bytesLeft
+		} else {
+			bp := rip.Bcache.GetBlock(devinfo.Devnum, bnum, FULL_DATA_BLOCK, NORMAL)
+			bdata, bok := bp.Block.(FullDataBlock)
+			if !bok {
+				log.Panicf("When reading block %d for position %d, got %T", bnum, curpos, bp.Block)
+			}
+			copy(b[numBytes:numBytes+chunk], bdata[offset:offset+chunk])
 			rip.Bcache.PutBlock(bp, FULL_DATA_BLOCK)
-			return numBytes, nil
 		}
-
-		// We need this whole block
-		for i := 0; i < len(bdata); i++ {
-			b[numBytes] = bdata[i]
-			numBytes++
-		}
-
-		curpos += len(bdata)
-		rip.Bcache.PutBlock(bp, FULL_DATA_BLOCK)
+		numBytes += chunk
 	}
 
 	return numBytes, nil
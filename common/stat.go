@@ -0,0 +1,37 @@
+package common
+
+// Stat returns a StatInfo structure describing the given inode. The number of
+// allocated blocks is found by walking the zone map, so holes in a sparse
+// file are not counted.
+func Stat(rip *Inode) *StatInfo {
+	rip.UpdateTimes()
+	devinfo := rip.Devinfo
+
+	stat := &StatInfo{
+		Dev:     devinfo.Devnum,
+		Inum:    rip.Inum,
+		Mode:    rip.Mode,
+		Nlinks:  rip.Nlinks,
+		Uid:     rip.Uid,
+		Gid:     rip.Gid,
+		Size:    rip.Size,
+		Atime:   rip.Atime,
+		Mtime:   rip.Mtime,
+		Ctime:   rip.Ctime,
+		Blksize: devinfo.Blocksize,
+	}
+
+	// The zones of special files contain device numbers, not blocks
+	ftype := rip.Mode & I_TYPE
+	if ftype == I_CHAR_SPECIAL || ftype == I_BLOCK_SPECIAL {
+		return stat
+	}
+
+	for pos := 0; pos < int(rip.Size); pos += devinfo.Blocksize {
+		if ReadMap(rip, pos, rip.Bcache) != NO_BLOCK {
+			stat.Blocks++
+		}
+	}
+
+	return stat
+}
@@ -23,6 +23,9 @@ func GetDeviceInfo(dev BlockDevice) (*DeviceInfo, error) {
 		NO_DEV,
 		nil,
 		nil,
+		0,
+		nil,
+		nil,
 	}
 
 	return info, nil
@@ -0,0 +1,54 @@
+package common
+
+import (
+	"time"
+)
+
+// A Clock supplies the current time, which is used to stamp inodes as they
+// are accessed and changed.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (c systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SystemClock is a Clock that returns the current system time
+var SystemClock Clock = systemClock{}
+
+// Flag the access time of the inode as needing an update, unless access times
+// are not being recorded on its device.
+func (rip *Inode) Accessed() {
+	if rip.Devinfo.Flags&(MS_RDONLY|MS_NOATIME) == 0 {
+		rip.Update |= ATIME
+		rip.Dirty = true
+	}
+}
+
+// Stamp the inode with the current time for any of its times that have been
+// flagged as needing an update, and clear the flags.
+func (rip *Inode) UpdateTimes() {
+	if rip.Update == 0 {
+		return
+	}
+
+	clock := SystemClock
+	if rip.Devinfo != nil && rip.Devinfo.Clock != nil {
+		clock = rip.Devinfo.Clock
+	}
+	now := int32(clock.Now().Unix())
+
+	if rip.Update&ATIME != 0 {
+		rip.Atime = now
+	}
+	if rip.Update&CTIME != 0 {
+		rip.Ctime = now
+	}
+	if rip.Update&MTIME != 0 {
+		rip.Mtime = now
+	}
+	rip.Update = 0
+}
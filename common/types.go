@@ -1,6 +1,44 @@
 package common
 
-type StatInfo struct{}
+import (
+	"io"
+)
+
+// StatInfo describes an inode, as returned by the stat() and fstat() calls.
+type StatInfo struct {
+	Dev     int    // the device number containing the inode
+	Inum    int    // the inode number
+	Mode    uint16 // file type, protection, etc.
+	Nlinks  uint16 // how many links to this file
+	Uid     int16  // user id of the file's owner
+	Gid     uint16 // group number of the file's owner
+	Size    int32  // current file size in bytes
+	Atime   int32  // when was file data last accessed
+	Mtime   int32  // when was file data last changed
+	Ctime   int32  // when was inode data last changed
+	Blksize int    // the block size of the device
+	Blocks  int    // the number of data blocks allocated to the file
+}
+
+// StatfsInfo describes a mounted file system, as returned by the statfs()
+// call.
+type StatfsInfo struct {
+	Blocksize  int // the block size of the device
+	Zones      int // the number of data zones
+	FreeZones  int // the number of unallocated data zones
+	Inodes     int // the number of inodes
+	FreeInodes int // the number of unallocated inodes
+}
+
+// MountStat describes a mounted device, as returned by Mounts().
+type MountStat struct {
+	Devnum     int    // the device slot the device is mounted in
+	Path       string // the path of the mount point
+	Flags      int    // the flags the device was mounted with
+	Blocksize  int    // the block size of the device
+	FreeInodes int    // the number of unallocated inodes
+	FreeZones  int    // the number of unallocated zones
+}
 
 type MountInfo struct {
 	MountPoint  *Inode // the inode on which another file system is mounted
@@ -14,9 +52,10 @@ type Inode struct {
 	Icache  InodeTbl    // the inode table for the file system
 	Devinfo *DeviceInfo // the device information for this inode's device
 
-	Inum  int  // the inode number of this inode
-	Count int  // the number of clients of this inode
-	Dirty bool // whether or not this inode has uncommited changes
+	Inum   int  // the inode number of this inode
+	Count  int  // the number of clients of this inode
+	Dirty  bool // whether or not this inode has uncommited changes
+	Update int  // the times that need updating (ATIME, CTIME, MTIME)
 
 	Mounted *MountInfo // non-nil if this inode is a mount point or target
 
@@ -49,9 +88,24 @@ type DeviceInfo struct {
 	ZmapBlocks    int        // the number of zone bitmap blocks
 	Devnum        int        // the number of this decide (if mounted)
 	AllocTbl      AllocTbl   // the allocation table process
+	Clock         Clock      // the source of time for this device
+	Flags         int        // the flags the device was mounted with
+	Watches       WatchTbl   // the watches to notify of changes
 	MountInfo     *MountInfo // mount point/target for this device
 }
 
+// Returns true if the device was mounted read-only
+func (info *DeviceInfo) ReadOnly() bool {
+	return info.Flags&MS_RDONLY != 0
+}
+
+// Return the block number containing the given inode, along with the offset
+// of the inode within that block.
+func (info *DeviceInfo) InodeBlock(inum int) (int, int) {
+	inodes_per_block := info.Blocksize / V2_INODE_SIZE
+	return info.MapOffset + ((inum - 1) / inodes_per_block), (inum - 1) % inodes_per_block
+}
+
 type CacheBlock struct {
 	Block    Block // the block data structure
 	Blocknum int   // the number of this block
@@ -61,25 +115,49 @@ type CacheBlock struct {
 	Buf interface{} // the cache-policy specific block
 }
 
-// This is the interface via which a user program will perform file
-// operations. These can be performed concurrency.
+// This is the interface to an open file, which one or more file descriptors
+// refer to. Operations can be performed concurrently. The ReadAt and WriteAt
+// operations do not use or alter the current position in the file.
 type Fd interface {
-	Seek(pos, whence int) (int, error)
-	Read(buf []byte) (int, error)
-	Write(buf []byte) (int, error)
+	io.Reader
+	io.Writer
+	io.Seeker
+	io.ReaderAt
+	io.WriterAt
+	io.Closer
 	Truncate(length int) error
 	Fstat() (*StatInfo, error)
+	Getdents(count int) ([]Dirent, error)
+	Sync() error
+	Flock(how int) error
+	FcntlFlock(cmd int, lk *Flock_t) error
 }
 
 // Private interface to a file, used by Filp and FileSystem
 type File interface {
 	Read(buf []byte, pos int) (int, error)
-	Write(buf []byte, pos int) (int, error)
+	Write(buf []byte, pos int, flags int) (int, int, error)
+	Seek(pos, whence int) (int, error)
 	Truncate(length int) error
 	Fstat() (*StatInfo, error)
 	Sync() error
 	Dup() File
 	Close() error
+
+	// Make a change to the attributes of the inode, such as its mode or
+	// owner, with the same exclusive access as a write.
+	Change(change func(rip *Inode) error) error
+
+	// Set or clear (with F_UNLCK) a lock. When another owner holds a
+	// conflicting lock, EAGAIN is returned unless 'wait' is set, in which
+	// case the result is delivered on the channel once the lock has been
+	// granted or the wait cancelled.
+	SetLock(lk Lock, wait bool) (<-chan error, error)
+	// Return a lock that prevents 'lk' being taken, or 'lk' with the type
+	// F_UNLCK if there is none.
+	GetLock(lk Lock) Lock
+	// Release the locks held by 'owner' and cancel its waits with EINTR
+	ReleaseLocks(owner interface{})
 }
 
 type AllocTbl interface {
@@ -87,6 +165,15 @@ type AllocTbl interface {
 	AllocZone(zstart int) (int, error)
 	FreeInode(inum int) error
 	FreeZone(znum int) error
+	FreeCount() (int, int) // the number of free inodes and zones
+	Shutdown() error       // so the server can be shut down
+}
+
+type WatchTbl interface {
+	Watch(devnum, inum, mask int) (int, <-chan Event)
+	Cancel(id int)
+	Notify(devnum, inum int, event Event)
+	UnmountDevice(devnum int)
 	Shutdown() error // so the server can be shut down
 }
 
@@ -97,6 +184,7 @@ type InodeTbl interface {
 	DupInode(inode *Inode) *Inode
 	PutInode(inode *Inode)
 	FlushInode(inode *Inode)
+	FlushAll()
 	IsDeviceBusy(devnum int) bool
 	Shutdown() error // so the server can be shut down
 }
@@ -107,12 +195,14 @@ type BlockCache interface {
 	GetBlock(devnum, bnum int, btype BlockType, only_search int) *CacheBlock
 	PutBlock(cb *CacheBlock, btype BlockType) error
 	Invalidate(devnum int)
-	Flush(devnum int)
+	Flush(devnum int) error
+	Sync(devnum int, bnums []int) error
 	Shutdown() error // so the server can be shut down
 }
 
 type BlockDevice interface {
 	Read(buf interface{}, pos int64) error
 	Write(buf interface{}, pos int64) error
+	Sync() error // commit any written data to stable storage
 	Close() error
 }
@@ -0,0 +1,17 @@
+package common
+
+// An Event reports a change to a watched file or directory. When the change
+// was to an entry in a watched directory, Name is the name of that entry.
+type Event struct {
+	Mask    int    // what happened, one of the IN_ constants
+	Name    string // the entry in the directory that was affected
+	OldName string // the name the entry had before it was renamed
+}
+
+// Tell anything watching the inode about a change to it, or to the entry
+// 'name' when the inode is a directory.
+func (rip *Inode) Notify(mask int, name string) {
+	if rip.Devinfo != nil && rip.Devinfo.Watches != nil {
+		rip.Devinfo.Watches.Notify(rip.Devinfo.Devnum, rip.Inum, Event{mask, name, ""})
+	}
+}
@@ -272,21 +272,28 @@ func WrIndir(bp *CacheBlock, index int, zone int) {
 	indb[index] = uint32(zone)
 }
 
-// Truncate the inode to 'size' bytes, removing all other zones from the
-// inode.
-func Truncate(rip *Inode, newSize int, cache BlockCache) {
+// Truncate the inode to 'newSize' bytes. The zones lying wholly beyond the
+// new end of the file are freed and removed from the inode, along with any
+// indirect blocks that are no longer needed, and the rest of the last block
+// is zeroed so that it reads as zeros if the file grows again. A file that is
+// grown this way is left with a hole.
+func Truncate(rip *Inode, newSize int, cache BlockCache) error {
 	ftype := rip.Mode & I_TYPE
 
 	// check to see if the file is special
 	if ftype == I_CHAR_SPECIAL || ftype == I_BLOCK_SPECIAL {
-		return
+		return nil
+	}
+	if rip.Devinfo.ReadOnly() {
+		return EROFS
 	}
 
 	devinfo := rip.Devinfo
 	alloc := devinfo.AllocTbl
+	blocksize := devinfo.Blocksize
 	scale := devinfo.Scale
-	zone_size := devinfo.Blocksize << scale
-	nr_indirects := devinfo.Blocksize / V2_ZONE_NUM_SIZE
+	zone_size := blocksize << scale
+	nr_indirects := blocksize / V2_ZONE_NUM_SIZE
 
 	// PIPE:
 	// // Pipes can shrink, so adjust size to make sure all zones are removed
@@ -295,15 +302,32 @@ func Truncate(rip *Inode, newSize int, cache BlockCache) {
 	// 	rip.Size = PIPE_SIZE(fs.Block_size)
 	// }
 
-	// step through the file a zone at a time, finding and freeing the zones
-	for position := newSize; position < int(rip.Size); position += zone_size {
+	// step through the file a zone at a time, starting with the first zone
+	// that is no longer needed, finding and freeing the zones
+	nr_zones := (newSize + zone_size - 1) / zone_size
+	for position := nr_zones * zone_size; position < int(rip.Size); position += zone_size {
 		if b := ReadMap(rip, position, cache); b != NO_BLOCK {
-			z := b >> scale
-			alloc.FreeZone(z)
+			alloc.FreeZone(b >> scale)
+			WriteMap(rip, position, NO_ZONE, cache)
+		}
+	}
+
+	// zero the end of the last block, which may hold old data
+	if newSize < int(rip.Size) && newSize%blocksize != 0 {
+		if b := ReadMap(rip, newSize, cache); b != NO_BLOCK {
+			bp := cache.GetBlock(devinfo.Devnum, b, FULL_DATA_BLOCK, NORMAL)
+			data := bp.Block.(FullDataBlock)
+			for i := newSize % blocksize; i < blocksize; i++ {
+				data[i] = 0
+			}
+			bp.Dirty = true
+			cache.PutBlock(bp, FULL_DATA_BLOCK)
 		}
 	}
 
 	// all the dirty zones have been freed. Now free the indirect zones
+	rip.Size = int32(newSize)
+	rip.Update |= CTIME | MTIME
 	rip.Dirty = true
 	// PIPE:
 	// if waspipe {
@@ -311,22 +335,39 @@ func Truncate(rip *Inode, newSize int, cache BlockCache) {
 	// 	return
 	// }
 	single := V2_NR_DZONES
-	alloc.FreeZone(int(rip.Zone[single]))
+	if z := int(rip.Zone[single]); z != NO_ZONE && nr_zones <= single {
+		alloc.FreeZone(z)
+		rip.Zone[single] = NO_ZONE
+	}
 
 	if z := int(rip.Zone[single+1]); z != NO_ZONE {
-		// free all the single indirect zones pointed to by the double
+		// free the single indirect zones pointed to by the double that only
+		// mapped zones beyond the new end of the file, starting with 'first'
+		first := 0
+		if excess := nr_zones - single - nr_indirects; excess > 0 {
+			first = (excess + nr_indirects - 1) / nr_indirects
+		}
 		b := int(z << scale)
 		bp := cache.GetBlock(devinfo.Devnum, b, INDIRECT_BLOCK, NORMAL)
-		for i := 0; i < nr_indirects; i++ {
-			z1 := RdIndir(bp, i, cache, devinfo.Firstdatazone, devinfo.Zones)
-			alloc.FreeZone(z1)
+		for i := first; i < nr_indirects; i++ {
+			if z1 := RdIndir(bp, i, cache, devinfo.Firstdatazone, devinfo.Zones); z1 != NO_ZONE {
+				alloc.FreeZone(z1)
+				if first > 0 {
+					WrIndir(bp, i, NO_ZONE)
+					bp.Dirty = true
+				}
+			}
 		}
-		// now free the double indirect zone itself
 		cache.PutBlock(bp, INDIRECT_BLOCK)
-		alloc.FreeZone(z)
+
+		// now free the double indirect zone itself, if nothing is left in it
+		if first == 0 {
+			alloc.FreeZone(z)
+			rip.Zone[single+1] = NO_ZONE
+		}
 	}
 
-	// leave zone numbers for de(1) to recover file after an unlink(2)
+	return nil
 }
 
 // Write len(b) bytes to the inode at position 'pos'
@@ -334,6 +375,10 @@ func Write(rip *Inode, data []byte, pos int) (n int, err error) {
 	devinfo := rip.Devinfo
 	bcache := rip.Bcache
 
+	if devinfo.ReadOnly() {
+		return 0, EROFS
+	}
+
 	// TODO: This implementation is direct and doesn't match the abstractions
 	// in the original source. At some point it should be reviewed.
 	cum_io := 0
@@ -388,8 +433,8 @@ func Write(rip *Inode, data []byte, pos int) (n int, err error) {
 		}
 	}
 
-	// TODO: Update times
 	if err == nil {
+		rip.Update |= CTIME | MTIME
 		rip.Dirty = true
 	}
 
@@ -10,6 +10,7 @@ type fileDevice struct {
 	file      *os.File
 	filename  string
 	byteOrder binary.ByteOrder
+	readonly  bool
 	in        chan m_dev_req
 	out       chan m_dev_res
 }
@@ -17,7 +18,21 @@ type fileDevice struct {
 // NewFileDevice creates a new file-backed block device, given a filename
 // and specified byte order.
 func NewFileDevice(filename string, byteOrder binary.ByteOrder) (common.BlockDevice, error) {
-	file, err := os.OpenFile(filename, os.O_RDWR, 0)
+	return newFileDevice(filename, byteOrder, false)
+}
+
+// NewFileDeviceReadOnly creates a file-backed block device that opens the
+// file read-only, so the file cannot be modified. Writes fail with EROFS.
+func NewFileDeviceReadOnly(filename string, byteOrder binary.ByteOrder) (common.BlockDevice, error) {
+	return newFileDevice(filename, byteOrder, true)
+}
+
+func newFileDevice(filename string, byteOrder binary.ByteOrder, readonly bool) (common.BlockDevice, error) {
+	flag := os.O_RDWR
+	if readonly {
+		flag = os.O_RDONLY
+	}
+	file, err := os.OpenFile(filename, flag, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -26,6 +41,7 @@ func NewFileDevice(filename string, byteOrder binary.ByteOrder) (common.BlockDev
 		file,
 		filename,
 		byteOrder,
+		readonly,
 		make(chan m_dev_req),
 		make(chan m_dev_res),
 	}
@@ -54,6 +70,10 @@ func (dev *fileDevice) loop() {
 			out <- m_dev_res{err}
 		case DEV_WRITE:
 			// device.Write
+			if dev.readonly {
+				out <- m_dev_res{common.EROFS}
+				continue
+			}
 			newPos, err := dev.file.Seek(req.pos, 0)
 			if err != nil {
 				out <- m_dev_res{err}
@@ -64,6 +84,10 @@ func (dev *fileDevice) loop() {
 			}
 			err = binary.Write(dev.file, dev.byteOrder, req.buf)
 			out <- m_dev_res{err}
+		case DEV_SYNC:
+			// device.Sync
+			err := dev.file.Sync()
+			out <- m_dev_res{err}
 		case DEV_CLOSE:
 			// device.Close
 			err := dev.file.Close()
@@ -88,6 +112,12 @@ func (dev *fileDevice) Write(buf interface{}, pos int64) error {
 	return res.err
 }
 
+func (dev *fileDevice) Sync() error {
+	dev.in <- m_dev_req{DEV_SYNC, nil, 0}
+	res := <-dev.out
+	return res.err
+}
+
 func (dev *fileDevice) Close() error {
 	dev.in <- m_dev_req{DEV_CLOSE, nil, 0}
 	res := <-dev.out
@@ -112,6 +112,11 @@ func (dev *ramdiskDevice) loop() {
 				callback <- m_dev_res{err}
 			}
 			close(callback)
+		case DEV_SYNC:
+			// device.Sync
+			// Writes go straight to memory, so there is nothing to do
+			callback <- m_dev_res{nil}
+			close(callback)
 		case DEV_CLOSE:
 			// device.Close
 			dev.data = nil
@@ -140,6 +145,13 @@ func (dev *ramdiskDevice) Write(buf interface{}, pos int64) error {
 	return res.err
 }
 
+func (dev *ramdiskDevice) Sync() error {
+	dev.in <- m_dev_req{DEV_SYNC, nil, 0}
+	cback := <-dev.out
+	res := <-cback
+	return res.err
+}
+
 func (dev *ramdiskDevice) Close() error {
 	dev.in <- m_dev_req{DEV_CLOSE, nil, 0}
 	cback := <-dev.out
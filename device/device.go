@@ -11,4 +11,5 @@ const (
 	DEV_READ  CallNumber = iota
 	DEV_WRITE CallNumber = iota
 	DEV_CLOSE CallNumber = iota
+	DEV_SYNC  CallNumber = iota
 )
@@ -13,7 +13,7 @@ func main() {
 	// Parse the flags from the commandline
 	flag.Parse()
 
-	fs, err := minixfs.OpenFileSystemFile(filename)
+	fs, err := minixfs.OpenFileSystemFile(filename, 0)
 	if err != nil {
 		panic(err)
 	}
@@ -319,7 +319,7 @@ func main() {
 	// Parse the flags from the commandline
 	flag.Parse()
 
-	fs, err := minixfs.OpenFileSystemFile(filename)
+	fs, err := minixfs.OpenFileSystemFile(filename, 0)
 	if err != nil {
 		log.Fatalf("Error opening file system: %s", err)
 	}
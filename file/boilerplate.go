@@ -13,10 +13,20 @@ type res_File_Read struct {
 	Arg1 error
 }
 type req_File_Write struct {
-	buf []byte
-	pos int
+	buf   []byte
+	pos   int
+	flags int
 }
 type res_File_Write struct {
+	Arg0 int
+	Arg1 int
+	Arg2 error
+}
+type req_File_Seek struct {
+	pos    int
+	whence int
+}
+type res_File_Seek struct {
 	Arg0 int
 	Arg1 error
 }
@@ -26,6 +36,12 @@ type req_File_Truncate struct {
 type res_File_Truncate struct {
 	Arg0 error
 }
+type req_File_Change struct {
+	change func(rip *common.Inode) error
+}
+type res_File_Change struct {
+	Arg0 error
+}
 type req_File_Fstat struct {
 }
 type res_File_Fstat struct {
@@ -47,6 +63,25 @@ type req_File_Close struct {
 type res_File_Close struct {
 	Arg0 error
 }
+type req_File_SetLock struct {
+	lk   common.Lock
+	wait bool
+}
+type res_File_SetLock struct {
+	Arg0 <-chan error
+	Arg1 error
+}
+type req_File_GetLock struct {
+	lk common.Lock
+}
+type res_File_GetLock struct {
+	Arg0 common.Lock
+}
+type req_File_ReleaseLocks struct {
+	owner interface{}
+}
+type res_File_ReleaseLocks struct {
+}
 type res_File_Async struct {
 	ch chan resFile
 }
@@ -59,29 +94,43 @@ type resFile interface {
 	is_resFile()
 }
 
-func (r req_File_Read) is_reqFile()     {}
-func (r res_File_Read) is_resFile()     {}
-func (r req_File_Write) is_reqFile()    {}
-func (r res_File_Write) is_resFile()    {}
-func (r req_File_Truncate) is_reqFile() {}
-func (r res_File_Truncate) is_resFile() {}
-func (r req_File_Fstat) is_reqFile()    {}
-func (r res_File_Fstat) is_resFile()    {}
-func (r req_File_Sync) is_reqFile()     {}
-func (r res_File_Sync) is_resFile()     {}
-func (r req_File_Dup) is_reqFile()      {}
-func (r res_File_Dup) is_resFile()      {}
-func (r req_File_Close) is_reqFile()    {}
-func (r res_File_Close) is_resFile()    {}
-func (r res_File_Async) is_resFile()    {}
+func (r req_File_Read) is_reqFile()         {}
+func (r res_File_Read) is_resFile()         {}
+func (r req_File_Write) is_reqFile()        {}
+func (r res_File_Write) is_resFile()        {}
+func (r req_File_Seek) is_reqFile()         {}
+func (r res_File_Seek) is_resFile()         {}
+func (r req_File_Truncate) is_reqFile()     {}
+func (r res_File_Truncate) is_resFile()     {}
+func (r req_File_Change) is_reqFile()       {}
+func (r res_File_Change) is_resFile()       {}
+func (r req_File_Fstat) is_reqFile()        {}
+func (r res_File_Fstat) is_resFile()        {}
+func (r req_File_Sync) is_reqFile()         {}
+func (r res_File_Sync) is_resFile()         {}
+func (r req_File_Dup) is_reqFile()          {}
+func (r res_File_Dup) is_resFile()          {}
+func (r req_File_Close) is_reqFile()        {}
+func (r res_File_Close) is_resFile()        {}
+func (r req_File_SetLock) is_reqFile()      {}
+func (r res_File_SetLock) is_resFile()      {}
+func (r req_File_GetLock) is_reqFile()      {}
+func (r res_File_GetLock) is_resFile()      {}
+func (r req_File_ReleaseLocks) is_reqFile() {}
+func (r res_File_ReleaseLocks) is_resFile() {}
+func (r res_File_Async) is_resFile()        {}
 
 // Type check request/response types
 var _ reqFile = req_File_Read{}
 var _ resFile = res_File_Read{}
 var _ reqFile = req_File_Write{}
 var _ resFile = res_File_Write{}
+var _ reqFile = req_File_Seek{}
+var _ resFile = res_File_Seek{}
 var _ reqFile = req_File_Truncate{}
 var _ resFile = res_File_Truncate{}
+var _ reqFile = req_File_Change{}
+var _ resFile = res_File_Change{}
 var _ reqFile = req_File_Fstat{}
 var _ resFile = res_File_Fstat{}
 var _ reqFile = req_File_Sync{}
@@ -90,6 +139,12 @@ var _ reqFile = req_File_Dup{}
 var _ resFile = res_File_Dup{}
 var _ reqFile = req_File_Close{}
 var _ resFile = res_File_Close{}
+var _ reqFile = req_File_SetLock{}
+var _ resFile = res_File_SetLock{}
+var _ reqFile = req_File_GetLock{}
+var _ resFile = res_File_GetLock{}
+var _ reqFile = req_File_ReleaseLocks{}
+var _ resFile = res_File_ReleaseLocks{}
 var _ resFile = res_File_Async{}
 
 func (s *server_File) Read(buf []byte, pos int) (int, error) {
@@ -98,9 +153,14 @@ func (s *server_File) Read(buf []byte, pos int) (int, error) {
 	result := (<-ares.ch).(res_File_Read)
 	return result.Arg0, result.Arg1
 }
-func (s *server_File) Write(buf []byte, pos int) (int, error) {
-	s.in <- req_File_Write{buf, pos}
+func (s *server_File) Write(buf []byte, pos int, flags int) (int, int, error) {
+	s.in <- req_File_Write{buf, pos, flags}
 	result := (<-s.out).(res_File_Write)
+	return result.Arg0, result.Arg1, result.Arg2
+}
+func (s *server_File) Seek(pos, whence int) (int, error) {
+	s.in <- req_File_Seek{pos, whence}
+	result := (<-s.out).(res_File_Seek)
 	return result.Arg0, result.Arg1
 }
 func (s *server_File) Truncate(size int) error {
@@ -108,6 +168,11 @@ func (s *server_File) Truncate(size int) error {
 	result := (<-s.out).(res_File_Truncate)
 	return result.Arg0
 }
+func (s *server_File) Change(change func(rip *common.Inode) error) error {
+	s.in <- req_File_Change{change}
+	result := (<-s.out).(res_File_Change)
+	return result.Arg0
+}
 func (s *server_File) Fstat() (*common.StatInfo, error) {
 	s.in <- req_File_Fstat{}
 	result := (<-s.out).(res_File_Fstat)
@@ -128,3 +193,17 @@ func (s *server_File) Close() error {
 	result := (<-s.out).(res_File_Close)
 	return result.Arg0
 }
+func (s *server_File) SetLock(lk common.Lock, wait bool) (<-chan error, error) {
+	s.in <- req_File_SetLock{lk, wait}
+	result := (<-s.out).(res_File_SetLock)
+	return result.Arg0, result.Arg1
+}
+func (s *server_File) GetLock(lk common.Lock) common.Lock {
+	s.in <- req_File_GetLock{lk}
+	result := (<-s.out).(res_File_GetLock)
+	return result.Arg0
+}
+func (s *server_File) ReleaseLocks(owner interface{}) {
+	s.in <- req_File_ReleaseLocks{owner}
+	<-s.out
+}
@@ -10,6 +10,9 @@ type server_File struct {
 	count int             // the number of clients of this server
 	wg    *sync.WaitGroup // tracking outstanding read requests
 
+	locks   []common.Lock // the advisory locks held on the file
+	waiters []*waiter     // requests for locks waiting on a conflicting one
+
 	in  chan reqFile
 	out chan resFile
 }
@@ -19,6 +22,8 @@ func NewFile(rip *common.Inode) common.File {
 		rip,
 		1,
 		new(sync.WaitGroup),
+		nil,
+		nil,
 		make(chan reqFile),
 		make(chan resFile),
 	}
@@ -33,6 +38,10 @@ func (file *server_File) loop() {
 		req := <-file.in
 		switch req := req.(type) {
 		case req_File_Read:
+			// The access time is updated here, rather than by the reader,
+			// so that concurrent reads don't race on the inode.
+			file.rip.Accessed()
+
 			// Indicate we have another outstanding reader
 			file.wg.Add(1)
 			callback := make(chan resFile)
@@ -47,16 +56,53 @@ func (file *server_File) loop() {
 			}()
 		case req_File_Write:
 			file.wg.Wait() // wait for any outstanding reads to complete before proceeding
-			n, err := common.Write(file.rip, req.buf, req.pos)
-			file.out <- res_File_Write{n, err}
+
+			// Appending writes always go to the end of the file, which can
+			// only be known once we have exclusive access to it.
+			pos := req.pos
+			if req.flags&common.O_APPEND != 0 {
+				pos = int(file.rip.Size)
+			}
+
+			n, err := common.Write(file.rip, req.buf, pos)
+			if err == nil && (req.flags&common.O_SYNC != 0 || file.rip.Devinfo.Flags&common.MS_SYNC != 0) {
+				err = file.sync(pos, n)
+			}
+			if n > 0 {
+				file.rip.Notify(common.IN_MODIFY, "")
+			}
+			file.out <- res_File_Write{n, pos, err}
+		case req_File_Seek:
+			// Positions relative to the end of the file or its holes depend
+			// on the inode, so they are resolved here.
+			var pos int
+			var err error
+			switch req.whence {
+			case common.SEEK_END:
+				pos = req.pos + int(file.rip.Size)
+			case common.SEEK_DATA, common.SEEK_HOLE:
+				pos, err = common.SeekHole(file.rip, req.pos, req.whence)
+			default:
+				err = common.EINVAL
+			}
+			file.out <- res_File_Seek{pos, err}
 		case req_File_Truncate:
 			file.wg.Wait() // wait for any outstanding reads to complete before proceeding
-			common.Truncate(file.rip, req.size, file.rip.Bcache)
-			file.out <- res_File_Truncate{}
+			err := common.Truncate(file.rip, req.size, file.rip.Bcache)
+			if err == nil {
+				file.rip.Notify(common.IN_MODIFY, "")
+			}
+			file.out <- res_File_Truncate{err}
+		case req_File_Change:
+			file.wg.Wait() // wait for any outstanding reads to complete before proceeding
+			file.out <- res_File_Change{req.change(file.rip)}
 		case req_File_Fstat:
-			// Code here
+			// Reads do not alter the inode, so there is no need to wait
+			file.out <- res_File_Fstat{common.Stat(file.rip), nil}
 		case req_File_Sync:
-			// Code here
+			file.wg.Wait() // wait for any outstanding reads to complete before proceeding
+			err := file.sync(0, int(file.rip.Size))
+			file.out <- res_File_Sync{err}
 		case req_File_Dup:
 			file.count++
 			file.out <- res_File_Dup{}
@@ -64,17 +110,80 @@ func (file *server_File) loop() {
 			file.wg.Wait() // wait for any outstanding reads to complete before proeceding
 			file.count--
 
+			if file.count == 0 {
+				// Detach from the inode so the next open starts a new server
+				file.rip.File = nil
+				alive = false
+
+				// Nobody is left to release the locks being waited for
+				for _, w := range file.waiters {
+					w.callback <- common.EINTR
+				}
+			}
+
 			// Let's push our changes to the inode cache
 			file.rip.Icache.FlushInode(file.rip)
 			file.rip.Icache.PutInode(file.rip)
 
-			if file.count == 0 {
-				alive = false
+			file.out <- res_File_Close{}
+		case req_File_SetLock:
+			// Locks are advisory and do not touch the inode, so there is no
+			// need to wait for reads.
+			callback, err := file.setLock(req.lk, req.wait)
+			file.out <- res_File_SetLock{callback, err}
+		case req_File_GetLock:
+			holder, ok := file.blocker(req.lk)
+			if !ok {
+				holder.Type = common.F_UNLCK
 			}
+			file.out <- res_File_GetLock{holder}
+		case req_File_ReleaseLocks:
+			file.releaseLocks(req.owner)
+			file.out <- res_File_ReleaseLocks{}
+		}
+	}
+}
 
-			file.out <- res_File_Close{}
+// Write the inode and the data blocks holding the 'length' bytes at 'pos'
+// back to the device, along with any indirect blocks, and ask the device to
+// make them durable.
+func (file *server_File) sync(pos, length int) error {
+	rip := file.rip
+	devinfo := rip.Devinfo
+	cache := rip.Bcache
+	scale := devinfo.Scale
+
+	rip.Icache.FlushInode(rip)
+	iblock, _ := devinfo.InodeBlock(rip.Inum)
+	blocks := []int{iblock}
+
+	// Start from the beginning of the block containing 'pos'
+	end := pos + length
+	for pos -= pos % devinfo.Blocksize; pos < end; pos += devinfo.Blocksize {
+		if b := common.ReadMap(rip, pos, cache); b != common.NO_BLOCK {
+			blocks = append(blocks, b)
 		}
 	}
+
+	// The single indirect block and the double indirect block, along with
+	// all of the single indirect blocks it points to.
+	single := common.V2_NR_DZONES
+	if z := int(rip.Zone[single]); z != common.NO_ZONE {
+		blocks = append(blocks, z<<scale)
+	}
+	if z := int(rip.Zone[single+1]); z != common.NO_ZONE {
+		b := z << scale
+		blocks = append(blocks, b)
+		bp := cache.GetBlock(devinfo.Devnum, b, common.INDIRECT_BLOCK, common.NORMAL)
+		for _, z1 := range bp.Block.(common.IndirectBlock) {
+			if z1 != common.NO_ZONE {
+				blocks = append(blocks, int(z1)<<scale)
+			}
+		}
+		cache.PutBlock(bp, common.INDIRECT_BLOCK)
+	}
+
+	return cache.Sync(devinfo.Devnum, blocks)
 }
 
 var _ common.File = &server_File{}
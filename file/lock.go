@@ -0,0 +1,123 @@
+package file
+
+import (
+	"github.com/jnwhiteh/minixfs/common"
+)
+
+// A request for a lock that is waiting for conflicting locks to be released
+type waiter struct {
+	lk       common.Lock
+	callback chan error
+}
+
+// Return a lock held by another owner that prevents 'lk' being taken
+func (file *server_File) blocker(lk common.Lock) (common.Lock, bool) {
+	if lk.Type == common.F_UNLCK {
+		return lk, false
+	}
+	for _, l := range file.locks {
+		if l.Conflicts(lk) {
+			return l, true
+		}
+	}
+	return lk, false
+}
+
+// Check whether waiting for 'holder' to release its lock would deadlock,
+// because it is itself waiting on a lock held by the owner of 'lk'. Locks
+// taken by flock are not checked, just as they are not on other systems.
+func (file *server_File) deadlock(lk, holder common.Lock) bool {
+	if lk.Flock {
+		return false
+	}
+	for _, w := range file.waiters {
+		if w.lk.Owner == holder.Owner {
+			if l, ok := file.blocker(w.lk); ok && l.Owner == lk.Owner {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Replace the locks held by the owner of 'lk' over its range with 'lk',
+// splitting any that extend past either end of it.
+func (file *server_File) apply(lk common.Lock) {
+	var locks []common.Lock
+	for _, l := range file.locks {
+		if l.Owner != lk.Owner || l.Flock != lk.Flock || l.End <= lk.Start || lk.End <= l.Start {
+			locks = append(locks, l)
+			continue
+		}
+		if l.Start < lk.Start {
+			left := l
+			left.End = lk.Start
+			locks = append(locks, left)
+		}
+		if l.End > lk.End {
+			right := l
+			right.Start = lk.End
+			locks = append(locks, right)
+		}
+	}
+	if lk.Type != common.F_UNLCK {
+		locks = append(locks, lk)
+	}
+	file.locks = locks
+}
+
+// Grant the waiting requests that no longer conflict, in the order they
+// were made.
+func (file *server_File) wake() {
+	var waiters []*waiter
+	for _, w := range file.waiters {
+		if _, ok := file.blocker(w.lk); ok {
+			waiters = append(waiters, w)
+		} else {
+			file.apply(w.lk)
+			w.callback <- nil
+		}
+	}
+	file.waiters = waiters
+}
+
+func (file *server_File) setLock(lk common.Lock, wait bool) (<-chan error, error) {
+	holder, ok := file.blocker(lk)
+	if !ok {
+		file.apply(lk)
+		file.wake() // the owner may have released a range others want
+		return nil, nil
+	}
+	if !wait {
+		return nil, common.EAGAIN
+	}
+	if file.deadlock(lk, holder) {
+		return nil, common.EDEADLK
+	}
+
+	// The callback is buffered so the server never blocks on a waiter
+	w := &waiter{lk, make(chan error, 1)}
+	file.waiters = append(file.waiters, w)
+	return w.callback, nil
+}
+
+func (file *server_File) releaseLocks(owner interface{}) {
+	var locks []common.Lock
+	for _, l := range file.locks {
+		if l.Owner != owner {
+			locks = append(locks, l)
+		}
+	}
+	file.locks = locks
+
+	var waiters []*waiter
+	for _, w := range file.waiters {
+		if w.lk.Owner == owner {
+			w.callback <- common.EINTR
+		} else {
+			waiters = append(waiters, w)
+		}
+	}
+	file.waiters = waiters
+	file.wake()
+}
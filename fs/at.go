@@ -0,0 +1,91 @@
+package fs
+
+import (
+	"github.com/jnwhiteh/minixfs/common"
+	"strings"
+)
+
+// The *at calls resolve relative paths from an open directory rather than
+// from the working directory, by passing that directory along as the open
+// file that lookups start from.
+
+// Return the open directory that 'path' is resolved from when given relative
+// to 'dirfd', or nil for the working directory. The descriptor is not used
+// when the path is absolute.
+func (proc *Process) atDir(dirfd int, path string) (*filp, error) {
+	if dirfd == common.AT_FDCWD || strings.HasPrefix(path, "/") {
+		return nil, nil
+	}
+	filp, err := proc.getFilp(dirfd)
+	if err != nil {
+		return nil, err
+	}
+	if filp.inode.Type() != common.I_DIRECTORY {
+		return nil, common.ENOTDIR
+	}
+	return filp, nil
+}
+
+func (fs *FileSystem) do_openat(proc *Process, dirfd int, path string, oflags int, omode uint16) (int, error) {
+	start, err := proc.atDir(dirfd, path)
+	if err != nil {
+		return -1, err
+	}
+	return fs.do_open(proc, start, path, oflags, omode)
+}
+
+func (fs *FileSystem) do_fstatat(proc *Process, dirfd int, path string, flags int) (*common.StatInfo, error) {
+	start, err := proc.atDir(dirfd, path)
+	if err != nil {
+		return nil, err
+	}
+	if flags&^common.AT_SYMLINK_NOFOLLOW != 0 {
+		return nil, common.EINVAL
+	}
+	if flags&common.AT_SYMLINK_NOFOLLOW != 0 {
+		return fs.do_lstat(proc, start, path)
+	}
+	return fs.do_stat(proc, start, path)
+}
+
+func (fs *FileSystem) do_unlinkat(proc *Process, dirfd int, path string, flags int) error {
+	start, err := proc.atDir(dirfd, path)
+	if err != nil {
+		return err
+	}
+	if flags&^common.AT_REMOVEDIR != 0 {
+		return common.EINVAL
+	}
+	if flags&common.AT_REMOVEDIR != 0 {
+		return fs.do_rmdir(proc, start, path)
+	}
+	return fs.do_unlink(proc, start, path)
+}
+
+func (fs *FileSystem) do_renameat(proc *Process, olddirfd int, oldpath string, newdirfd int, newpath string) error {
+	oldstart, err := proc.atDir(olddirfd, oldpath)
+	if err != nil {
+		return err
+	}
+	newstart, err := proc.atDir(newdirfd, newpath)
+	if err != nil {
+		return err
+	}
+	return fs.do_rename(proc, oldstart, oldpath, newstart, newpath)
+}
+
+func (fs *FileSystem) do_readlinkat(proc *Process, dirfd int, path string) (string, error) {
+	start, err := proc.atDir(dirfd, path)
+	if err != nil {
+		return "", err
+	}
+	return fs.do_readlink(proc, start, path)
+}
+
+func (fs *FileSystem) do_mkdirat(proc *Process, dirfd int, path string, mode uint16) error {
+	start, err := proc.atDir(dirfd, path)
+	if err != nil {
+		return err
+	}
+	return fs.do_mkdir(proc, start, path, mode)
+}
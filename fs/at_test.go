@@ -0,0 +1,91 @@
+package fs
+
+import (
+	"errors"
+	"github.com/jnwhiteh/minixfs/common"
+	"github.com/jnwhiteh/minixfs/testutils"
+	"testing"
+)
+
+// Test the calls that resolve relative paths from an open directory
+func TestAt(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	proc.Mkdir("/tmp/at", 0755)
+	dir, err := proc.Open("/tmp/at", common.O_RDONLY, 0)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when opening directory: %s", err)
+	}
+
+	if err = proc.MkdirAt(dir, "sub", 0755); err != nil {
+		testutils.FatalHere(test, "Failed when calling mkdirat: %s", err)
+	}
+	file, err := proc.OpenAt(dir, "sub/file", common.O_CREAT|common.O_RDWR, 0666)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when calling openat: %s", err)
+	}
+	proc.Write(file, []byte("data"))
+	proc.Close(file)
+	if stat, err := proc.FstatAt(dir, "sub/file", 0); err != nil || stat.Size != 4 {
+		testutils.ErrorHere(test, "Failed when calling fstatat: %+v (%v)", stat, err)
+	}
+
+	proc.Symlink("file", "/tmp/at/sub/link")
+	if target, err := proc.ReadlinkAt(dir, "sub/link"); err != nil || target != "file" {
+		testutils.ErrorHere(test, "Failed when calling readlinkat: %q (%v)", target, err)
+	}
+	if stat, _ := proc.FstatAt(dir, "sub/link", common.AT_SYMLINK_NOFOLLOW); stat.Mode&common.I_TYPE != common.I_SYMBOLIC_LINK {
+		testutils.ErrorHere(test, "AT_SYMLINK_NOFOLLOW followed the link, mode %o", stat.Mode)
+	}
+	if stat, _ := proc.FstatAt(dir, "sub/link", 0); stat.Mode&common.I_TYPE != common.I_REGULAR {
+		testutils.ErrorHere(test, "Link was not followed, mode %o", stat.Mode)
+	}
+
+	// Each side of a rename has its own starting directory
+	sub, _ := proc.OpenAt(dir, "sub", common.O_RDONLY, 0)
+	if err = proc.RenameAt(sub, "file", dir, "moved"); err != nil {
+		testutils.ErrorHere(test, "Failed when calling renameat: %s", err)
+	}
+	if _, err = proc.Stat("/tmp/at/moved"); err != nil {
+		testutils.ErrorHere(test, "Renamed file not found: %s", err)
+	}
+
+	// The descriptor is ignored for absolute paths, and AT_FDCWD uses the
+	// working directory.
+	if _, err = proc.FstatAt(99, "/tmp/at/moved", 0); err != nil {
+		testutils.ErrorHere(test, "Failed with absolute path: %s", err)
+	}
+	proc.Chdir("/tmp/at")
+	if _, err = proc.FstatAt(common.AT_FDCWD, "moved", 0); err != nil {
+		testutils.ErrorHere(test, "Failed with AT_FDCWD: %s", err)
+	}
+	proc.Chdir("/")
+
+	if _, err = proc.FstatAt(99, "moved", 0); !errors.Is(err, common.EBADF) {
+		testutils.ErrorHere(test, "Expected EBADF, got: %v", err)
+	}
+	file, _ = proc.Open("/tmp/at/moved", common.O_RDONLY, 0)
+	if _, err = proc.FstatAt(file, "moved", 0); !errors.Is(err, common.ENOTDIR) {
+		testutils.ErrorHere(test, "Expected ENOTDIR, got: %v", err)
+	}
+	proc.Close(file)
+	if err = proc.UnlinkAt(dir, "moved", 0777); !errors.Is(err, common.EINVAL) {
+		testutils.ErrorHere(test, "Expected EINVAL, got: %v", err)
+	}
+
+	if err = proc.UnlinkAt(dir, "moved", 0); err != nil {
+		testutils.ErrorHere(test, "Failed when calling unlinkat: %s", err)
+	}
+	proc.UnlinkAt(sub, "link", 0)
+	proc.Close(sub)
+	if err = proc.UnlinkAt(dir, "sub", common.AT_REMOVEDIR); err != nil {
+		testutils.ErrorHere(test, "Failed when removing directory: %s", err)
+	}
+
+	proc.Close(dir)
+	proc.Rmdir("/tmp/at")
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
@@ -1,6 +1,8 @@
 package fs
 
 import (
+	"errors"
+	"github.com/jnwhiteh/minixfs/common"
 	"github.com/jnwhiteh/minixfs/testutils"
 	"testing"
 )
@@ -29,8 +31,108 @@ func TestChdir(test *testing.T) {
 
 	fs.itable.PutInode(rip)
 
+	// The root directory is still usable after leaving it
+	rip, err = fs.eatPath(proc, "/var/run/syslogd.pid")
+	if err != nil || rip.Inum != 481 {
+		testutils.FatalHere(test, "Could not open absolute path after chdir: %v", err)
+	}
+	fs.itable.PutInode(rip)
+
 	err = fs.Shutdown()
 	if err != nil {
 		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
 	}
 }
+
+func expectCwd(test *testing.T, proc *Process, expected string) {
+	if path, err := proc.Getcwd(); err != nil || path != expected {
+		testutils.ErrorLevel(test, 2, "Expected working directory %q, got %q (%v)", expected, path, err)
+	}
+}
+
+// Test rebuilding the working directory as a path, across a mount point and
+// after changing into an open directory.
+func TestGetcwd(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+	expectCwd(test, proc, "/")
+
+	proc.Mkdir("/tmp/cwd", 0755)
+	proc.Mkdir("/tmp/cwd/sub", 0755)
+	proc.Chdir("/tmp/cwd/sub")
+	expectCwd(test, proc, "/tmp/cwd/sub")
+
+	dir, _ := proc.Open("/tmp/cwd", common.O_RDONLY, 0)
+	if err := proc.Fchdir(dir); err != nil {
+		testutils.ErrorHere(test, "Failed when calling fchdir: %s", err)
+	}
+	expectCwd(test, proc, "/tmp/cwd")
+	proc.Close(dir)
+
+	file, _ := proc.Open("/sample/europarl-en.txt", common.O_RDONLY, 0)
+	if err := proc.Fchdir(file); !errors.Is(err, common.ENOTDIR) {
+		testutils.ErrorHere(test, "Expected ENOTDIR, got: %v", err)
+	}
+	proc.Close(file)
+	if err := proc.Fchdir(file); !errors.Is(err, common.EBADF) {
+		testutils.ErrorHere(test, "Expected EBADF, got: %v", err)
+	}
+
+	mountMirror(test, fs, proc)
+	proc.Chdir("/mnt/sample")
+	expectCwd(test, proc, "/mnt/sample")
+	proc.Chdir("..")
+	expectCwd(test, proc, "/mnt")
+
+	proc.Chdir("/")
+	proc.Unmount("/mnt", 0)
+	proc.Rmdir("/tmp/cwd/sub")
+	proc.Rmdir("/tmp/cwd")
+	fs.Exit(proc)
+	if err := fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test confining a process to a subtree of the file system
+func TestChroot(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+	proc.Mkdir("/tmp/jail", 0755)
+	proc.Mkdir("/tmp/jail/sub", 0755)
+
+	user := forkAs(test, proc, 1, 1)
+	if err := user.Chroot("/tmp/jail"); !errors.Is(err, common.EPERM) {
+		testutils.ErrorHere(test, "Expected EPERM, got: %v", err)
+	}
+
+	jailed, _ := proc.Fork()
+	if err := jailed.Chroot("/tmp/jail/sub/file"); !errors.Is(err, common.ENOENT) {
+		testutils.ErrorHere(test, "Expected ENOENT, got: %v", err)
+	}
+	if err := jailed.Chroot("/tmp/jail"); err != nil {
+		testutils.FatalHere(test, "Failed when calling chroot: %s", err)
+	}
+
+	// The working directory is left outside the new root
+	if _, err := jailed.Getcwd(); !errors.Is(err, common.ENOENT) {
+		testutils.ErrorHere(test, "Expected ENOENT, got: %v", err)
+	}
+
+	jailed.Chdir("/sub")
+	expectCwd(test, jailed, "/sub")
+	createFile(test, jailed, "/../../file", "data")
+	if _, err := proc.Stat("/tmp/jail/file"); err != nil {
+		testutils.ErrorHere(test, "File was not created inside the new root: %s", err)
+	}
+	jailed.Chdir("../..")
+	expectCwd(test, jailed, "/")
+
+	fs.Exit(jailed)
+	fs.Exit(user)
+	proc.Unlink("/tmp/jail/file")
+	proc.Rmdir("/tmp/jail/sub")
+	proc.Rmdir("/tmp/jail")
+	fs.Exit(proc)
+	if err := fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
@@ -29,7 +29,7 @@ func TestCreateThenUnlink(test *testing.T) {
 	if err != nil {
 		testutils.FatalHere(test, "Failed when creating new file: %s", err)
 	}
-	filp := file.(*filp)
+	filp := proc.files[file]
 	if filp.inode.Inum != inum {
 		testutils.ErrorHere(test, "Inum mismatch expected %d, got %d", inum, filp.inode.Inum)
 	}
@@ -20,6 +20,36 @@ func Lookup(rip *common.Inode, name string) (bool, int, int) {
 	return true, dirp.Devinfo.Devnum, inum
 }
 
+// Search the directory 'rip' for an entry other than "." and ".." that refers
+// to the inode 'inum', returning its name.
+func LookupName(rip *common.Inode, inum int) (bool, string) {
+	if !rip.IsDirectory() {
+		return false, ""
+	}
+
+	devinfo := rip.Devinfo
+	blocksize := devinfo.Blocksize
+
+	for pos := 0; pos < int(rip.Size); pos += blocksize {
+		b := common.ReadMap(rip, pos, rip.Bcache)
+		if b == common.NO_BLOCK {
+			continue
+		}
+		bp := rip.Bcache.GetBlock(devinfo.Devnum, b, common.DIRECTORY_BLOCK, common.NORMAL)
+		dirarr := bp.Block.(common.DirectoryBlock)
+		for i := 0; i < len(dirarr) && pos+i*common.DIR_ENTRY_SIZE < int(rip.Size); i++ {
+			dp := &dirarr[i]
+			if int(dp.Inum) == inum && !dp.HasName(".") && !dp.HasName("..") {
+				name := dp.String()
+				rip.Bcache.PutBlock(bp, common.DIRECTORY_BLOCK)
+				return true, name
+			}
+		}
+		rip.Bcache.PutBlock(bp, common.DIRECTORY_BLOCK)
+	}
+	return false, ""
+}
+
 func Link(rip *common.Inode, name string, inum int) error {
 	if !rip.IsDirectory() {
 		return common.ENOTDIR
@@ -44,6 +74,18 @@ func Unlink(rip *common.Inode, name string) error {
 	return err
 }
 
+// Change the entry 'name' in the directory 'rip' to refer to the inode 'inum'
+func Relink(rip *common.Inode, name string, inum int) error {
+	if !rip.IsDirectory() {
+		return common.ENOTDIR
+	}
+
+	dirp := rip
+
+	err := search_dir(dirp, name, &inum, REPLACE)
+	return err
+}
+
 func IsEmpty(rip *common.Inode) bool {
 	if !rip.IsDirectory() {
 		return false
@@ -0,0 +1,79 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jnwhiteh/minixfs/common"
+	"github.com/jnwhiteh/minixfs/testutils"
+	iofs "io/fs"
+	"syscall"
+	"testing"
+)
+
+// Test that system call errors name the call and path, and can be compared
+// with the io/fs and syscall errors.
+func TestPathErrors(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	_, err := proc.Stat("/sample/nonexistent")
+	var perr *common.PathError
+	if !errors.As(err, &perr) || perr.Op != "stat" || perr.Path != "/sample/nonexistent" {
+		testutils.ErrorHere(test, "Expected PathError for stat, got: %#v", err)
+	}
+	if !errors.Is(err, common.ENOENT) || !errors.Is(err, iofs.ErrNotExist) || !errors.Is(err, syscall.ENOENT) {
+		testutils.ErrorHere(test, "Error does not match ENOENT: %v", err)
+	}
+	if errors.Is(err, iofs.ErrExist) || errors.Is(err, syscall.EEXIST) {
+		testutils.ErrorHere(test, "Error matches EEXIST: %v", err)
+	}
+
+	err = proc.Rename("/sample/nonexistent", "/tmp/other")
+	var lerr *common.LinkError
+	if !errors.As(err, &lerr) || lerr.Op != "rename" || lerr.Old != "/sample/nonexistent" || lerr.New != "/tmp/other" {
+		testutils.ErrorHere(test, "Expected LinkError for rename, got: %#v", err)
+	}
+
+	child := forkAs(test, proc, 1, 1)
+	if err = child.Mkdir("/nodir", 0755); !errors.Is(err, iofs.ErrPermission) {
+		testutils.ErrorHere(test, "Expected ErrPermission, got: %v", err)
+	}
+	var serr *common.SyscallError
+	if err = child.Setuid(0); !errors.As(err, &serr) || serr.Syscall != "setuid" || !errors.Is(err, common.EPERM) {
+		testutils.ErrorHere(test, "Expected SyscallError for setuid, got: %#v", err)
+	}
+
+	file, _ := proc.Open("/sample/europarl-en.txt", common.O_RDONLY, 0)
+	if _, err = proc.Write(file, []byte("x")); !errors.As(err, &perr) || perr.Path != "/sample/europarl-en.txt" {
+		testutils.ErrorHere(test, "Expected PathError naming open file, got: %#v", err)
+	}
+	proc.Close(file)
+	if err = proc.Close(file); !errors.As(err, &perr) || perr.Op != "close" || !errors.Is(err, common.EBADF) {
+		testutils.ErrorHere(test, "Expected PathError for closed descriptor, got: %#v", err)
+	} else if perr.Path != fmt.Sprintf("fd %d", file) {
+		testutils.ErrorHere(test, "Expected descriptor number in path, got: %q", perr.Path)
+	}
+
+	fs.Exit(child)
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test converting error numbers to and from those of the host
+func TestErrno(test *testing.T) {
+	for e := common.EPERM; e <= common.ELOOP; e++ {
+		if common.FromSyscall(e.Syscall()) != e {
+			testutils.ErrorHere(test, "Errno %d (%s) does not convert back from %d", e, e, e.Syscall())
+		}
+	}
+	if common.ENAMETOOLONG.Error() != "File name too long" {
+		testutils.ErrorHere(test, "Unexpected string for ENAMETOOLONG: %s", common.ENAMETOOLONG)
+	}
+	if common.Errno(1000).Error() != "Error 1000" {
+		testutils.ErrorHere(test, "Unexpected string for unknown errno: %s", common.Errno(1000))
+	}
+	if common.FromSyscall(syscall.Errno(100000)) != common.EIO {
+		testutils.ErrorHere(test, "Unknown host errno did not convert to EIO")
+	}
+}
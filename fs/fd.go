@@ -0,0 +1,155 @@
+package fs
+
+import (
+	"github.com/jnwhiteh/minixfs/common"
+)
+
+// The calls on an open file do not go through the file system server once
+// the descriptor has been looked up, so that they can proceed concurrently
+// with each other and with other system calls.
+
+func (proc *Process) getfd(fd int) (*filp, error) {
+	proc.fs.in <- req_FS_Getfd{proc, fd}
+	result := (<-proc.fs.out).(res_FS_Getfd)
+	return result.Arg0, result.Arg1
+}
+
+// An open file as returned by File, which remembers the descriptor it was
+// obtained from so that it can be closed.
+type fdFile struct {
+	*filp
+	proc   *Process
+	fd     int
+	closed bool // whether Close has been called
+}
+
+// Close the descriptor the file was obtained from, as Close(fd) would. Once
+// the file has been closed, or if the descriptor has since been closed or
+// made to refer to another open file, EBADF is returned instead.
+func (file *fdFile) Close() error {
+	if file.closed {
+		return fdError("close", file.fd, file.filp, common.EBADF)
+	}
+	file.closed = true
+
+	proc := file.proc
+	proc.fs.in <- req_FS_CloseFile{proc, file.fd, file.filp}
+	result := (<-proc.fs.out).(res_FS_CloseFile)
+	return result.Arg0
+}
+
+// Byte-range locks taken through the file belong to the process it was
+// obtained from.
+func (file *fdFile) FcntlFlock(cmd int, lk *common.Flock_t) error {
+	return file.filp.FcntlFlock(file.proc, cmd, lk)
+}
+
+// Return the open file that a descriptor refers to, for use wherever the io
+// interfaces are expected. It can be used until every descriptor referring
+// to it has been closed, and closing it closes 'fd'.
+func (proc *Process) File(fd int) (common.Fd, error) {
+	filp, err := proc.getfd(fd)
+	if err != nil {
+		return nil, fdError("file", fd, nil, err)
+	}
+	return &fdFile{filp, proc, fd, false}, nil
+}
+
+func (proc *Process) Read(fd int, buf []byte) (int, error) {
+	filp, err := proc.getfd(fd)
+	if err != nil {
+		return 0, fdError("read", fd, nil, err)
+	}
+	n, err := filp.Read(buf)
+	return n, fdError("read", fd, filp, err)
+}
+
+func (proc *Process) Write(fd int, buf []byte) (int, error) {
+	filp, err := proc.getfd(fd)
+	if err != nil {
+		return 0, fdError("write", fd, nil, err)
+	}
+	n, err := filp.Write(buf)
+	return n, fdError("write", fd, filp, err)
+}
+
+func (proc *Process) Seek(fd int, offset int64, whence int) (int64, error) {
+	filp, err := proc.getfd(fd)
+	if err != nil {
+		return -1, fdError("seek", fd, nil, err)
+	}
+	pos, err := filp.Seek(offset, whence)
+	return pos, fdError("seek", fd, filp, err)
+}
+
+// Read from the given offset without using or altering the position of the
+// descriptor.
+func (proc *Process) Pread(fd int, buf []byte, off int64) (int, error) {
+	filp, err := proc.getfd(fd)
+	if err != nil {
+		return 0, fdError("pread", fd, nil, err)
+	}
+	n, err := filp.ReadAt(buf, off)
+	return n, fdError("pread", fd, filp, err)
+}
+
+// Write at the given offset without using or altering the position of the
+// descriptor.
+func (proc *Process) Pwrite(fd int, buf []byte, off int64) (int, error) {
+	filp, err := proc.getfd(fd)
+	if err != nil {
+		return 0, fdError("pwrite", fd, nil, err)
+	}
+	n, err := filp.WriteAt(buf, off)
+	return n, fdError("pwrite", fd, filp, err)
+}
+
+func (proc *Process) Ftruncate(fd int, length int) error {
+	filp, err := proc.getfd(fd)
+	if err != nil {
+		return fdError("ftruncate", fd, nil, err)
+	}
+	return fdError("ftruncate", fd, filp, filp.Truncate(length))
+}
+
+func (proc *Process) Fstat(fd int) (*common.StatInfo, error) {
+	filp, err := proc.getfd(fd)
+	if err != nil {
+		return nil, fdError("fstat", fd, nil, err)
+	}
+	stat, err := filp.Fstat()
+	return stat, fdError("fstat", fd, filp, err)
+}
+
+func (proc *Process) Getdents(fd int, count int) ([]common.Dirent, error) {
+	filp, err := proc.getfd(fd)
+	if err != nil {
+		return nil, fdError("getdents", fd, nil, err)
+	}
+	entries, err := filp.Getdents(count)
+	return entries, fdError("getdents", fd, filp, err)
+}
+
+func (proc *Process) Fsync(fd int) error {
+	filp, err := proc.getfd(fd)
+	if err != nil {
+		return fdError("fsync", fd, nil, err)
+	}
+	return fdError("fsync", fd, filp, filp.Sync())
+}
+
+func (proc *Process) Flock(fd int, how int) error {
+	filp, err := proc.getfd(fd)
+	if err != nil {
+		return fdError("flock", fd, nil, err)
+	}
+	return fdError("flock", fd, filp, filp.Flock(how))
+}
+
+func (proc *Process) FcntlFlock(fd int, cmd int, lk *common.Flock_t) error {
+	filp, err := proc.getfd(fd)
+	if err != nil {
+		return fdError("fcntl", fd, nil, err)
+	}
+	return fdError("fcntl", fd, filp, filp.FcntlFlock(proc, cmd, lk))
+}
@@ -2,6 +2,8 @@ package fs
 
 import (
 	"github.com/jnwhiteh/minixfs/common"
+	"io"
+	"math"
 	"sync"
 )
 
@@ -17,13 +19,17 @@ type filp struct {
 	pos   int           // the current position in the file
 	file  common.File   // the file server backing the operations
 	inode *common.Inode // the inode this refers to
+	proc  *Process      // the process that opened the file
+	name  string        // the path the file was opened with
+	trail trail         // the mounts crossed to reach the inode
 
-	mode uint16 // the mode under which this file was opened
+	mode  uint16 // the mode under which this file was opened
+	flags int    // the file status flags, O_APPEND and O_SYNC
 
 	m *sync.Mutex // for mutual exclusion
 }
 
-func (fi *filp) Seek(pos, whence int) (int, error) {
+func (fi *filp) Seek(offset int64, whence int) (int64, error) {
 	fi.m.Lock()
 	defer fi.m.Unlock()
 
@@ -31,23 +37,34 @@ func (fi *filp) Seek(pos, whence int) (int, error) {
 		return -1, common.EBADF
 	}
 
+	var err error
+	pos := int(offset)
 	switch whence {
-	case 1:
-		fi.pos += pos
-	case 0:
-		fi.pos = pos
+	case common.SEEK_SET:
+	case common.SEEK_CUR:
+		pos += fi.pos
+	case common.SEEK_END, common.SEEK_DATA, common.SEEK_HOLE:
+		pos, err = fi.file.Seek(pos, whence)
 	default:
-		panic("NYI: Seek with whence > 1")
+		err = common.EINVAL
 	}
 
-	return fi.pos, nil
+	if err == nil && pos < 0 {
+		err = common.EINVAL
+	}
+	if err != nil {
+		return -1, err
+	}
+
+	fi.pos = pos
+	return int64(fi.pos), nil
 }
 
 func (fi *filp) Read(buf []byte) (int, error) {
 	fi.m.Lock()
 	defer fi.m.Unlock()
 
-	if fi.file == nil {
+	if fi.file == nil || fi.mode&common.R_BIT == 0 {
 		return 0, common.EBADF
 	}
 
@@ -61,21 +78,60 @@ func (fi *filp) Write(buf []byte) (int, error) {
 	fi.m.Lock()
 	defer fi.m.Unlock()
 
-	if fi.file == nil {
+	if fi.file == nil || fi.mode&common.W_BIT == 0 {
 		return 0, common.EBADF
 	}
 
-	n, err := fi.file.Write(buf, fi.pos)
-	fi.pos += n
+	n, pos, err := fi.file.Write(buf, fi.pos, fi.flags)
+	fi.pos = pos + n
 
 	return n, err
 }
 
+// Read from the given offset without using or altering the current position.
+// As required by io.ReaderAt, io.EOF is returned when fewer than len(buf)
+// bytes could be read.
+func (fi *filp) ReadAt(buf []byte, off int64) (int, error) {
+	fi.m.Lock()
+	defer fi.m.Unlock()
+
+	if fi.file == nil || fi.mode&common.R_BIT == 0 {
+		return 0, common.EBADF
+	}
+	if off < 0 {
+		return 0, common.EINVAL
+	}
+
+	n, err := fi.file.Read(buf, int(off))
+	if err == nil && n < len(buf) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Write at the given offset without using or altering the current position.
+// This is not permitted when the file was opened with O_APPEND, since the
+// data would not be written where the caller asked.
+func (fi *filp) WriteAt(buf []byte, off int64) (int, error) {
+	fi.m.Lock()
+	defer fi.m.Unlock()
+
+	if fi.file == nil || fi.mode&common.W_BIT == 0 {
+		return 0, common.EBADF
+	}
+	if off < 0 || fi.flags&common.O_APPEND != 0 {
+		return 0, common.EINVAL
+	}
+
+	n, _, err := fi.file.Write(buf, int(off), fi.flags)
+	return n, err
+}
+
 func (fi *filp) Truncate(length int) error {
 	fi.m.Lock()
 	defer fi.m.Unlock()
 
-	if fi.file == nil {
+	if fi.file == nil || fi.mode&common.W_BIT == 0 {
 		return common.EBADF
 	}
 
@@ -95,17 +151,183 @@ func (fi *filp) Fstat() (*common.StatInfo, error) {
 	return fi.file.Fstat()
 }
 
+// Read up to 'count' entries from an open directory, continuing from where
+// the last call left off. When 'count' is greater than zero, io.EOF is
+// returned once there are no more entries. Otherwise all of the remaining
+// entries are returned. Unlike the other calls this goes through the file
+// system server, which is where directories are changed.
+func (fi *filp) Getdents(count int) ([]common.Dirent, error) {
+	fs := fi.proc.fs
+	fs.in <- req_FS_Getdents{fi, count}
+	result := (<-fs.out).(res_FS_Getdents)
+	return result.Arg0, result.Arg1
+}
+
+func (fi *filp) Sync() error {
+	fi.m.Lock()
+	defer fi.m.Unlock()
+
+	if fi.file == nil {
+		return common.EBADF
+	}
+
+	return fi.file.Sync()
+}
+
+// Apply an advisory lock to the whole file, shared with LOCK_SH or exclusive
+// with LOCK_EX, or remove it with LOCK_UN. The lock belongs to the open file,
+// so descriptors duplicated by Dup or inherited through Fork share it, and it
+// is released once they have all been closed. Unless LOCK_NB is given this
+// waits for conflicting locks to be released. Closing the last descriptor
+// for the open file cancels the wait, which then fails with EINTR.
+func (fi *filp) Flock(how int) error {
+	lk := common.Lock{Owner: fi, Flock: true, Start: 0, End: math.MaxInt64}
+	switch how &^ common.LOCK_NB {
+	case common.LOCK_SH:
+		lk.Type = common.F_RDLCK
+	case common.LOCK_EX:
+		lk.Type = common.F_WRLCK
+	case common.LOCK_UN:
+		lk.Type = common.F_UNLCK
+	default:
+		return common.EINVAL
+	}
+
+	fi.m.Lock()
+	var done <-chan error
+	var err error = common.EBADF
+	if fi.file != nil {
+		lk.Pid = fi.proc.pid
+		done, err = fi.file.SetLock(lk, how&common.LOCK_NB == 0)
+	}
+	fi.m.Unlock()
+
+	// The descriptor must not be held while waiting, or it could not be
+	// closed to cancel the wait.
+	if done != nil {
+		err = <-done
+	}
+	return err
+}
+
+// Get (F_GETLK), set or clear (F_SETLK) a lock on a range of the file,
+// waiting for conflicting locks to be released with F_SETLKW. These locks
+// belong to the process 'proc' making the call, which need not be the one
+// that opened the file, and are released when it closes any descriptor for
+// the file, or exits, which also cancels its waits with EINTR.
+func (fi *filp) FcntlFlock(proc *Process, cmd int, lk *common.Flock_t) error {
+	fi.m.Lock()
+	done, err := fi.fcntlFlock(proc, cmd, lk)
+	fi.m.Unlock()
+
+	if done != nil {
+		err = <-done
+	}
+	return err
+}
+
+func (fi *filp) fcntlFlock(proc *Process, cmd int, lk *common.Flock_t) (<-chan error, error) {
+	if fi.file == nil {
+		return nil, common.EBADF
+	}
+
+	start := lk.Start
+	switch lk.Whence {
+	case common.SEEK_SET:
+	case common.SEEK_CUR:
+		start += int64(fi.pos)
+	case common.SEEK_END:
+		size, err := fi.file.Seek(0, common.SEEK_END)
+		if err != nil {
+			return nil, err
+		}
+		start += int64(size)
+	default:
+		return nil, common.EINVAL
+	}
+
+	// A negative length covers the bytes before the start
+	end := int64(math.MaxInt64)
+	if lk.Len > 0 {
+		end = start + lk.Len
+	} else if lk.Len < 0 {
+		start, end = start+lk.Len, start
+	}
+	if start < 0 {
+		return nil, common.EINVAL
+	}
+
+	l := common.Lock{Owner: proc, Pid: proc.pid, Type: lk.Type, Start: start, End: end}
+	switch {
+	case cmd == common.F_GETLK && (lk.Type == common.F_RDLCK || lk.Type == common.F_WRLCK):
+		holder := fi.file.GetLock(l)
+		if holder.Type != common.F_UNLCK {
+			lk.Whence = common.SEEK_SET
+			lk.Start = holder.Start
+			lk.Len = 0
+			if holder.End != math.MaxInt64 {
+				lk.Len = holder.End - holder.Start
+			}
+			lk.Pid = holder.Pid
+		}
+		lk.Type = holder.Type
+		return nil, nil
+	case cmd != common.F_SETLK && cmd != common.F_SETLKW:
+		return nil, common.EINVAL
+	case lk.Type == common.F_RDLCK && fi.mode&common.R_BIT == 0:
+		return nil, common.EBADF
+	case lk.Type == common.F_WRLCK && fi.mode&common.W_BIT == 0:
+		return nil, common.EBADF
+	case lk.Type != common.F_RDLCK && lk.Type != common.F_WRLCK && lk.Type != common.F_UNLCK:
+		return nil, common.EINVAL
+	}
+	return fi.file.SetLock(l, cmd == common.F_SETLKW)
+}
+
 // This function is not exposed to the user, it only exists to perform the
 // cleanup part of the close() system call. Accordingly, it will only be
 // acquired when the file system is locked for that call, so it can safely
 // shut down the file server.
-func (fi *filp) Close() error {
+func (fi *filp) close() error {
 	fi.m.Lock()
 	defer fi.m.Unlock()
 
 	fi.count--
-	if fi.count == 0 {
-		return fi.file.Close()
+	if fi.count == 0 && fi.file != nil {
+		// Any further use of this filp must fail rather than talk to a
+		// file server that has shut down.
+		file := fi.file
+		fi.file = nil
+		file.ReleaseLocks(fi)
+		return file.Close()
 	}
 	return nil
 }
+
+// Release the byte-range locks 'proc' holds on the file, as happens whenever
+// a process closes any of its descriptors for the file.
+func (fi *filp) releaseLocks(proc *Process) {
+	fi.m.Lock()
+	defer fi.m.Unlock()
+
+	if fi.file != nil {
+		fi.file.ReleaseLocks(proc)
+	}
+}
+
+// Close the file underneath the descriptor, as when the device it is on is
+// forcibly unmounted. The descriptor stays open but every operation on it
+// fails, until it has been closed by each of its clients.
+func (fi *filp) revoke() error {
+	fi.m.Lock()
+	defer fi.m.Unlock()
+
+	if fi.file == nil {
+		return nil // already revoked
+	}
+	file := fi.file
+	fi.file = nil
+	fi.inode = nil
+	file.ReleaseLocks(fi)
+	return file.Close()
+}
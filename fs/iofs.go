@@ -0,0 +1,297 @@
+package fs
+
+import (
+	"errors"
+	"github.com/jnwhiteh/minixfs/common"
+	"io"
+	iofs "io/fs"
+	"path"
+	"sort"
+	"time"
+)
+
+// IOFS presents the file system as seen by a process as an io/fs.FS, so it
+// can be used with the standard library, e.g. fs.WalkDir or http.FS. All
+// operations are performed with the credentials and working directory of the
+// process, relative to the directory 'dir'.
+type IOFS struct {
+	proc *Process // the process performing the operations
+	dir  string   // the directory that names are relative to
+}
+
+var (
+	_ iofs.ReadDirFS  = (*IOFS)(nil)
+	_ iofs.StatFS     = (*IOFS)(nil)
+	_ iofs.ReadFileFS = (*IOFS)(nil)
+	_ iofs.SubFS      = (*IOFS)(nil)
+)
+
+// Create an io/fs.FS rooted at the root directory of the given process.
+func NewIOFS(proc *Process) *IOFS {
+	return &IOFS{proc, "/"}
+}
+
+// Convert a slash-separated io/fs path into a path for the process,
+// returning false if the name is not valid.
+func (fsys *IOFS) path(name string) (string, bool) {
+	if !iofs.ValidPath(name) {
+		return "", false
+	}
+	return path.Join(fsys.dir, name), true
+}
+
+func (fsys *IOFS) Open(name string) (iofs.File, error) {
+	fullpath, ok := fsys.path(name)
+	if !ok {
+		return nil, &iofs.PathError{Op: "open", Path: name, Err: iofs.ErrInvalid}
+	}
+
+	fd, err := fsys.proc.Open(fullpath, common.O_RDONLY, 0)
+	if err != nil {
+		return nil, ioError("open", name, err)
+	}
+	file, err := fsys.proc.File(fd)
+	var stat *common.StatInfo
+	if err == nil {
+		stat, err = file.Fstat()
+	}
+	if err != nil {
+		fsys.proc.Close(fd)
+		return nil, ioError("open", name, err)
+	}
+
+	return &ioFile{file, fsys, name, fileInfo{path.Base(name), stat}}, nil
+}
+
+func (fsys *IOFS) Stat(name string) (iofs.FileInfo, error) {
+	fullpath, ok := fsys.path(name)
+	if !ok {
+		return nil, &iofs.PathError{Op: "stat", Path: name, Err: iofs.ErrInvalid}
+	}
+
+	stat, err := fsys.proc.Stat(fullpath)
+	if err != nil {
+		return nil, ioError("stat", name, err)
+	}
+	return fileInfo{path.Base(name), stat}, nil
+}
+
+// Read the named directory, returning its entries sorted by name. The "."
+// and ".." entries are not included.
+func (fsys *IOFS) ReadDir(name string) ([]iofs.DirEntry, error) {
+	fullpath, ok := fsys.path(name)
+	if !ok {
+		return nil, &iofs.PathError{Op: "readdir", Path: name, Err: iofs.ErrInvalid}
+	}
+
+	entries, err := fsys.proc.ReadDir(fullpath)
+	if err != nil {
+		return nil, ioError("readdir", name, err)
+	}
+
+	dirents := fsys.dirEntries(name, entries)
+	sort.Slice(dirents, func(i, j int) bool {
+		return dirents[i].Name() < dirents[j].Name()
+	})
+	return dirents, nil
+}
+
+func (fsys *IOFS) ReadFile(name string) ([]byte, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// The file could change size after the open, so stop short at the end
+	data := make([]byte, file.(*ioFile).info.Size())
+	n, err := io.ReadFull(file, data)
+	if err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	return data[:n], err
+}
+
+// Return a file system rooted at the given subdirectory.
+func (fsys *IOFS) Sub(dir string) (iofs.FS, error) {
+	fullpath, ok := fsys.path(dir)
+	if !ok {
+		return nil, &iofs.PathError{Op: "sub", Path: dir, Err: iofs.ErrInvalid}
+	}
+	return &IOFS{fsys.proc, fullpath}, nil
+}
+
+// Convert the entries of the directory 'dir', skipping "." and "..".
+func (fsys *IOFS) dirEntries(dir string, entries []common.Dirent) []iofs.DirEntry {
+	dirents := make([]iofs.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Name == "." || entry.Name == ".." {
+			continue
+		}
+		dirents = append(dirents, dirEntry{fsys, path.Join(dir, entry.Name), entry})
+	}
+	return dirents
+}
+
+// An open file, which implements io/fs.ReadDirFile along with the io
+// interfaces provided by the underlying descriptor.
+type ioFile struct {
+	common.Fd
+	fsys *IOFS    // the file system that opened this file
+	name string   // the name the file was opened with
+	info fileInfo // the information at the time the file was opened
+}
+
+func (file *ioFile) Stat() (iofs.FileInfo, error) {
+	return file.info, nil
+}
+
+func (file *ioFile) Read(buf []byte) (int, error) {
+	n, err := file.Fd.Read(buf)
+	if err != nil && err != io.EOF {
+		err = ioError("read", file.name, err)
+	}
+	return n, err
+}
+
+func (file *ioFile) Close() error {
+	if err := file.Fd.Close(); err != nil {
+		return ioError("close", file.name, err)
+	}
+	return nil
+}
+
+func (file *ioFile) ReadDir(count int) ([]iofs.DirEntry, error) {
+	var dirents []iofs.DirEntry
+	for {
+		entries, err := file.Getdents(count - len(dirents))
+		if err != nil && err != io.EOF {
+			return dirents, ioError("readdir", file.name, err)
+		}
+		dirents = append(dirents, file.fsys.dirEntries(file.name, entries)...)
+
+		// Skipping "." and ".." can leave us short of what was asked for
+		if count <= 0 {
+			return dirents, nil
+		} else if len(dirents) == count {
+			return dirents, nil
+		} else if err == io.EOF {
+			if len(dirents) > 0 {
+				return dirents, nil
+			}
+			return nil, io.EOF
+		}
+	}
+}
+
+// A single directory entry, whose information is only fetched when asked
+// for.
+type dirEntry struct {
+	fsys  *IOFS         // the file system containing the entry
+	path  string        // the path of the entry, for Info
+	entry common.Dirent // the entry itself
+}
+
+func (d dirEntry) Name() string {
+	return d.entry.Name
+}
+
+func (d dirEntry) IsDir() bool {
+	return d.Type().IsDir()
+}
+
+// Entries read from an open directory carry no type, so it is only looked
+// up when asked for.
+func (d dirEntry) Type() iofs.FileMode {
+	if d.entry.Type != 0 {
+		return fileMode(uint16(d.entry.Type)).Type()
+	}
+	info, err := d.Info()
+	if err != nil {
+		return 0
+	}
+	return info.Mode().Type()
+}
+
+func (d dirEntry) Info() (iofs.FileInfo, error) {
+	fullpath, _ := d.fsys.path(d.path)
+	stat, err := d.fsys.proc.Lstat(fullpath)
+	if err != nil {
+		return nil, ioError("lstat", d.path, err)
+	}
+	return fileInfo{d.entry.Name, stat}, nil
+}
+
+// An implementation of io/fs.FileInfo built from the stat information of an
+// inode. Sys returns the underlying *common.StatInfo.
+type fileInfo struct {
+	name string
+	stat *common.StatInfo
+}
+
+func (fi fileInfo) Name() string {
+	return fi.name
+}
+
+func (fi fileInfo) Size() int64 {
+	return int64(fi.stat.Size)
+}
+
+func (fi fileInfo) Mode() iofs.FileMode {
+	return fileMode(fi.stat.Mode)
+}
+
+func (fi fileInfo) ModTime() time.Time {
+	return time.Unix(int64(fi.stat.Mtime), 0)
+}
+
+func (fi fileInfo) IsDir() bool {
+	return fi.stat.Mode&common.I_TYPE == common.I_DIRECTORY
+}
+
+func (fi fileInfo) Sys() interface{} {
+	return fi.stat
+}
+
+// Convert the mode of an inode to an io/fs.FileMode
+func fileMode(mode uint16) iofs.FileMode {
+	fmode := iofs.FileMode(mode & common.RWX_MODES)
+
+	switch mode & common.I_TYPE {
+	case common.I_DIRECTORY:
+		fmode |= iofs.ModeDir
+	case common.I_SYMBOLIC_LINK:
+		fmode |= iofs.ModeSymlink
+	case common.I_BLOCK_SPECIAL:
+		fmode |= iofs.ModeDevice
+	case common.I_CHAR_SPECIAL:
+		fmode |= iofs.ModeDevice | iofs.ModeCharDevice
+	case common.I_NAMED_PIPE:
+		fmode |= iofs.ModeNamedPipe
+	}
+
+	if mode&common.I_SET_UID_BIT != 0 {
+		fmode |= iofs.ModeSetuid
+	}
+	if mode&common.I_SET_GID_BIT != 0 {
+		fmode |= iofs.ModeSetgid
+	}
+	if mode&common.I_SET_STCKY_BIT != 0 {
+		fmode |= iofs.ModeSticky
+	}
+	return fmode
+}
+
+// Wrap an error from the file system in an *io/fs.PathError naming the
+// file as it is known to the io/fs caller. The error numbers already match
+// the io/fs errors using errors.Is, apart from a closed file.
+func ioError(op, name string, err error) error {
+	var perr *common.PathError
+	if errors.As(err, &perr) {
+		err = perr.Err
+	}
+	if err == common.EBADF {
+		err = iofs.ErrClosed
+	}
+	return &iofs.PathError{Op: op, Path: name, Err: err}
+}
@@ -0,0 +1,112 @@
+package fs
+
+import (
+	"errors"
+	"github.com/jnwhiteh/minixfs/common"
+	"github.com/jnwhiteh/minixfs/testutils"
+	iofs "io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+// Test the io/fs adapter using the standard library's own checks
+func TestIOFS(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	proc.Mkdir("/tmp/iofs", 0755)
+	proc.Mkdir("/tmp/iofs/dir", 0755)
+	createFile(test, proc, "/tmp/iofs/dir/hello.txt", "hello world\n")
+	createFile(test, proc, "/tmp/iofs/empty", "")
+	proc.Symlink("dir/hello.txt", "/tmp/iofs/link")
+
+	fsys, err := NewIOFS(proc).Sub("tmp/iofs")
+	if err != nil {
+		testutils.FatalHere(test, "Failed when creating sub file system: %s", err)
+	}
+	if err = fstest.TestFS(fsys, "dir/hello.txt", "empty", "link"); err != nil {
+		testutils.ErrorHere(test, "TestFS failed: %s", err)
+	}
+
+	if data, err := iofs.ReadFile(fsys, "link"); err != nil || string(data) != "hello world\n" {
+		testutils.ErrorHere(test, "ReadFile through link got %q (%v)", data, err)
+	}
+
+	var paths []string
+	iofs.WalkDir(fsys, ".", func(path string, d iofs.DirEntry, err error) error {
+		paths = append(paths, path)
+		return err
+	})
+	if len(paths) != 5 || paths[1] != "dir" || paths[2] != "dir/hello.txt" {
+		testutils.ErrorHere(test, "WalkDir visited %v", paths)
+	}
+
+	proc.Unlink("/tmp/iofs/link")
+	proc.Unlink("/tmp/iofs/empty")
+	proc.Unlink("/tmp/iofs/dir/hello.txt")
+	proc.Rmdir("/tmp/iofs/dir")
+	proc.Rmdir("/tmp/iofs")
+
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test that errors are reported using the io/fs errors
+func TestIOFSErrors(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	createFile(test, proc, "/tmp/private", "secret")
+	setAttr(test, fs, proc, "/tmp/private", 0, 0, 0600)
+	user := forkAs(test, proc, 1, 1)
+	fsys := NewIOFS(user)
+
+	var errs = []struct {
+		err      error
+		expected error
+	}{
+		{open(fsys, "tmp/nonexistent"), iofs.ErrNotExist},
+		{open(fsys, "tmp/private"), iofs.ErrPermission},
+		{open(fsys, "/tmp"), iofs.ErrInvalid},
+		{open(fsys, "tmp/../sample"), iofs.ErrInvalid},
+	}
+	for _, e := range errs {
+		var perr *iofs.PathError
+		if !errors.Is(e.err, e.expected) || !errors.As(e.err, &perr) {
+			testutils.ErrorHere(test, "Expected %v, got: %v", e.expected, e.err)
+		}
+	}
+
+	file, _ := fsys.Open("sample/europarl-en.txt")
+	file.Close()
+	if _, err := file.Read(make([]byte, 1)); !errors.Is(err, iofs.ErrClosed) {
+		testutils.ErrorHere(test, "Expected ErrClosed reading closed file, got: %v", err)
+	}
+	other, _ := fsys.Open("sample/europarl-en.txt")
+	if err := file.Close(); !errors.Is(err, iofs.ErrClosed) {
+		testutils.ErrorHere(test, "Expected ErrClosed closing twice, got: %v", err)
+	}
+	if _, err := other.Read(make([]byte, 1)); err != nil {
+		testutils.ErrorHere(test, "Closing twice closed another file: %s", err)
+	}
+	other.Close()
+
+	proc.Unlink("/tmp/private")
+	fs.Exit(user)
+	fs.Exit(proc)
+	if err := fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Open and close a file, returning any error from the open
+func open(fsys iofs.FS, name string) error {
+	file, err := fsys.Open(name)
+	if err == nil {
+		file.Close()
+	}
+	return err
+}
+
+var _ iofs.ReadDirFile = (*ioFile)(nil)
+var _ common.Fd = (*ioFile)(nil)
@@ -0,0 +1,225 @@
+package fs
+
+import (
+	"errors"
+	"github.com/jnwhiteh/minixfs/common"
+	"github.com/jnwhiteh/minixfs/testutils"
+	"testing"
+	"time"
+)
+
+// Take a lock in the background, so a test can check whether it waits
+func lockAsync(lock func() error) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- lock()
+	}()
+	return done
+}
+
+func expectWaiting(test *testing.T, done <-chan error) {
+	select {
+	case err := <-done:
+		testutils.ErrorLevel(test, 2, "Expected lock to wait, got: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func expectDone(test *testing.T, done <-chan error, expected error) {
+	select {
+	case err := <-done:
+		if !errors.Is(err, expected) {
+			testutils.ErrorLevel(test, 2, "Expected %v from lock, got: %v", expected, err)
+		}
+	case <-time.After(5 * time.Second):
+		testutils.FatalLevel(test, 2, "Lock is still waiting")
+	}
+}
+
+func openFile(test *testing.T, proc *Process, path string, flags int) int {
+	file, err := proc.Open(path, flags, 0)
+	if err != nil {
+		testutils.FatalLevel(test, 2, "Failed when opening %s: %s", path, err)
+	}
+	return file
+}
+
+// Test whole-file locks taken through separate opens of the same file
+func TestFlock(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+	createFile(test, proc, "/tmp/flock", "data")
+
+	fd1 := openFile(test, proc, "/tmp/flock", common.O_RDONLY)
+	fd2 := openFile(test, proc, "/tmp/flock", common.O_RDONLY)
+
+	if err := proc.Flock(fd1, common.LOCK_SH); err != nil {
+		testutils.ErrorHere(test, "Failed when taking shared lock: %s", err)
+	}
+	if err := proc.Flock(fd2, common.LOCK_SH|common.LOCK_NB); err != nil {
+		testutils.ErrorHere(test, "Failed when taking second shared lock: %s", err)
+	}
+	if err := proc.Flock(fd2, common.LOCK_EX|common.LOCK_NB); !errors.Is(err, common.EAGAIN) {
+		testutils.ErrorHere(test, "Expected EAGAIN, got: %v", err)
+	}
+	if err := proc.Flock(fd1, common.LOCK_UN); err != nil {
+		testutils.ErrorHere(test, "Failed when unlocking: %s", err)
+	}
+	if err := proc.Flock(fd2, common.LOCK_EX|common.LOCK_NB); err != nil {
+		testutils.ErrorHere(test, "Failed when upgrading lock: %s", err)
+	}
+	if err := proc.Flock(fd1, 0); !errors.Is(err, common.EINVAL) {
+		testutils.ErrorHere(test, "Expected EINVAL, got: %v", err)
+	}
+
+	// Closing the file releases its lock to a waiter
+	done := lockAsync(func() error { return proc.Flock(fd1, common.LOCK_EX) })
+	expectWaiting(test, done)
+	proc.Close(fd2)
+	expectDone(test, done, nil)
+
+	// Closing a waiting descriptor cancels the wait
+	fd3 := openFile(test, proc, "/tmp/flock", common.O_RDONLY)
+	done = lockAsync(func() error { return proc.Flock(fd3, common.LOCK_SH) })
+	expectWaiting(test, done)
+	proc.Close(fd3)
+	expectDone(test, done, common.EINTR)
+	if err := proc.Flock(fd3, common.LOCK_SH); !errors.Is(err, common.EBADF) {
+		testutils.ErrorHere(test, "Expected EBADF, got: %v", err)
+	}
+
+	proc.Close(fd1)
+	proc.Unlink("/tmp/flock")
+	fs.Exit(proc)
+	if err := fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+func setLock(proc *Process, fd, cmd, ltype int, start, length int64) error {
+	return proc.FcntlFlock(fd, cmd, &common.Flock_t{Type: ltype, Whence: common.SEEK_SET, Start: start, Len: length})
+}
+
+// Test byte-range locks held by two processes
+func TestFcntlLock(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+	createFile(test, proc, "/tmp/fcntl", "data")
+	child, _ := proc.Fork()
+
+	pfd := openFile(test, proc, "/tmp/fcntl", common.O_RDWR)
+	cfd := openFile(test, child, "/tmp/fcntl", common.O_RDWR)
+
+	if err := setLock(proc, pfd, common.F_SETLK, common.F_WRLCK, 0, 10); err != nil {
+		testutils.FatalHere(test, "Failed when locking: %s", err)
+	}
+
+	lk := &common.Flock_t{Type: common.F_WRLCK, Whence: common.SEEK_SET, Start: 5, Len: 10}
+	if err := child.FcntlFlock(cfd, common.F_GETLK, lk); err != nil {
+		testutils.ErrorHere(test, "Failed when getting lock: %s", err)
+	}
+	expected := common.Flock_t{Type: common.F_WRLCK, Whence: common.SEEK_SET, Start: 0, Len: 10, Pid: proc.pid}
+	if *lk != expected {
+		testutils.ErrorHere(test, "Expected conflicting lock %+v, got %+v", expected, *lk)
+	}
+
+	if err := setLock(child, cfd, common.F_SETLK, common.F_RDLCK, 10, 10); err != nil {
+		testutils.ErrorHere(test, "Failed when taking lock after another: %s", err)
+	}
+	if err := setLock(child, cfd, common.F_SETLK, common.F_WRLCK, 0, 5); !errors.Is(err, common.EAGAIN) {
+		testutils.ErrorHere(test, "Expected EAGAIN, got: %v", err)
+	}
+
+	// Unlocking part of a lock leaves the rest of it held
+	if err := setLock(proc, pfd, common.F_SETLK, common.F_UNLCK, 0, 5); err != nil {
+		testutils.ErrorHere(test, "Failed when unlocking: %s", err)
+	}
+	if err := setLock(child, cfd, common.F_SETLK, common.F_WRLCK, 0, 5); err != nil {
+		testutils.ErrorHere(test, "Failed when locking released range: %s", err)
+	}
+	if err := setLock(child, cfd, common.F_SETLK, common.F_WRLCK, 5, 1); !errors.Is(err, common.EAGAIN) {
+		testutils.ErrorHere(test, "Expected EAGAIN, got: %v", err)
+	}
+
+	// Closing any descriptor for the file releases the process's locks
+	done := lockAsync(func() error { return setLock(child, cfd, common.F_SETLKW, common.F_WRLCK, 5, 1) })
+	expectWaiting(test, done)
+	other := openFile(test, proc, "/tmp/fcntl", common.O_RDONLY)
+	proc.Close(other)
+	expectDone(test, done, nil)
+
+	// Waiting on each other is refused rather than deadlocking
+	if err := setLock(proc, pfd, common.F_SETLK, common.F_WRLCK, 20, 10); err != nil {
+		testutils.ErrorHere(test, "Failed when locking: %s", err)
+	}
+	done = lockAsync(func() error { return setLock(child, cfd, common.F_SETLKW, common.F_WRLCK, 25, 0) })
+	expectWaiting(test, done)
+	if err := setLock(proc, pfd, common.F_SETLKW, common.F_RDLCK, 0, 1); !errors.Is(err, common.EDEADLK) {
+		testutils.ErrorHere(test, "Expected EDEADLK, got: %v", err)
+	}
+
+	// Exiting cancels the waits of a process and releases its locks
+	fs.Exit(child)
+	expectDone(test, done, common.EINTR)
+	if err := setLock(proc, pfd, common.F_SETLK, common.F_WRLCK, 0, 0); err != nil {
+		testutils.ErrorHere(test, "Failed when locking whole file: %s", err)
+	}
+
+	rdonly := openFile(test, proc, "/tmp/fcntl", common.O_RDONLY)
+	if err := setLock(proc, rdonly, common.F_SETLK, common.F_WRLCK, 0, 1); !errors.Is(err, common.EBADF) {
+		testutils.ErrorHere(test, "Expected EBADF, got: %v", err)
+	}
+	proc.Close(rdonly)
+
+	proc.Close(pfd)
+	proc.Unlink("/tmp/fcntl")
+	fs.Exit(proc)
+	if err := fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test that byte-range locks taken through an inherited descriptor belong to
+// the process using it rather than the one that opened the file
+func TestFcntlLockInherited(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+	createFile(test, proc, "/tmp/fcntl", "data")
+
+	fd := openFile(test, proc, "/tmp/fcntl", common.O_RDWR)
+	child, _ := proc.Fork()
+	if err := setLock(proc, fd, common.F_SETLK, common.F_WRLCK, 0, 10); err != nil {
+		testutils.FatalHere(test, "Failed when locking: %s", err)
+	}
+
+	if err := setLock(child, fd, common.F_SETLK, common.F_WRLCK, 0, 5); !errors.Is(err, common.EAGAIN) {
+		testutils.ErrorHere(test, "Expected EAGAIN, got: %v", err)
+	}
+	lk := &common.Flock_t{Type: common.F_RDLCK, Whence: common.SEEK_SET, Start: 5, Len: 1}
+	if err := child.FcntlFlock(fd, common.F_GETLK, lk); err != nil || lk.Pid != proc.pid {
+		testutils.ErrorHere(test, "Expected lock held by %d, got %+v (%v)", proc.pid, *lk, err)
+	}
+	file, _ := child.File(fd)
+	lk = &common.Flock_t{Type: common.F_RDLCK, Whence: common.SEEK_SET, Start: 0, Len: 1}
+	if err := file.FcntlFlock(common.F_SETLK, lk); !errors.Is(err, common.EAGAIN) {
+		testutils.ErrorHere(test, "Expected EAGAIN through file, got: %v", err)
+	}
+
+	// The child's own lock goes when it exits, leaving the parent's
+	if err := setLock(child, fd, common.F_SETLK, common.F_WRLCK, 20, 10); err != nil {
+		testutils.ErrorHere(test, "Failed when locking: %s", err)
+	}
+	fs.Exit(child)
+	other, _ := proc.Fork()
+	if err := setLock(other, fd, common.F_SETLK, common.F_WRLCK, 0, 1); !errors.Is(err, common.EAGAIN) {
+		testutils.ErrorHere(test, "Expected EAGAIN, got: %v", err)
+	}
+	if err := setLock(other, fd, common.F_SETLK, common.F_WRLCK, 20, 10); err != nil {
+		testutils.ErrorHere(test, "Failed when locking range released by exit: %s", err)
+	}
+	fs.Exit(other)
+
+	proc.Close(fd)
+	proc.Unlink("/tmp/fcntl")
+	fs.Exit(proc)
+	if err := fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
@@ -2,85 +2,248 @@ package fs
 
 import (
 	"github.com/jnwhiteh/minixfs/common"
-	"path/filepath"
 	"strings"
 )
 
+// Resolve a path to an inode, following a symbolic link in the final
+// component of the path.
 func (fs *FileSystem) eatPath(proc *Process, path string) (*common.Inode, error) {
-	ldip, rest, err := fs.lastDir(proc, path)
+	return fs.eatPathFrom(proc, nil, path, true)
+}
+
+// Resolve a path to an inode without following a symbolic link in the final
+// component of the path, for calls such as lstat and readlink that operate
+// on the link itself.
+func (fs *FileSystem) eatPathNoFollow(proc *Process, path string) (*common.Inode, error) {
+	return fs.eatPathFrom(proc, nil, path, false)
+}
+
+// As eatPath, or eatPathNoFollow when 'follow' is false, with relative paths
+// starting from the open directory 'start' rather than the working directory
+// if it is not nil.
+func (fs *FileSystem) eatPathFrom(proc *Process, start *filp, path string, follow bool) (*common.Inode, error) {
+	rip, _, err := fs.eatPathTrail(proc, start, path, follow)
+	return rip, err
+}
+
+// As eatPathFrom, also returning the trail of mounts crossed to reach the
+// inode.
+func (fs *FileSystem) eatPathTrail(proc *Process, start *filp, path string, follow bool) (*common.Inode, trail, error) {
+	loops := 0
+	dirp, via := start.lookupDir()
+	return fs.resolve(proc, dirp, via, path, follow, &loops)
+}
+
+// Return the directory that relative lookups from the open directory 'start'
+// begin at, along with the trail it was reached along, or nil for the
+// working directory if 'start' is nil.
+func (start *filp) lookupDir() (*common.Inode, trail) {
+	if start == nil {
+		return nil, nil
+	}
+	return start.inode, start.trail
+}
+
+// Resolve a path to an inode, starting at 'start' if the path is relative and
+// 'start' is not nil, having reached it along the trail 'via'. The number of
+// symbolic links followed so far is tracked in 'loops' so that cycles can be
+// detected. A path with a trailing slash must name a directory, so a symbolic
+// link in the final component is always followed.
+func (fs *FileSystem) resolve(proc *Process, start *common.Inode, via trail, path string, follow bool, loops *int) (*common.Inode, trail, error) {
+	ldip, via, rest, err := fs.lastDirFrom(proc, start, via, path, loops)
 	if err != nil {
-		return nil, err // could not open final directory
+		return nil, nil, err // could not open final directory
 	}
 
 	// If there is no more path to go, return
 	if len(rest) == 0 {
-		return ldip, nil
+		return ldip, via, nil
 	}
 
+	dir := strings.HasSuffix(path, "/")
+
 	// Get final component of the path
-	rip, err := fs.advance(proc, ldip, rest)
+	rip, rvia, err := fs.advanceFrom(proc, ldip, via, rest)
+	if err == nil && (follow || dir) {
+		rip, rvia, err = fs.followLink(proc, ldip, via, rip, rvia, loops)
+	}
 	fs.itable.PutInode(ldip)
-	return rip, err
+
+	if err == nil && dir && rip.Type() != common.I_DIRECTORY {
+		fs.itable.PutInode(rip)
+		return nil, nil, common.ENOTDIR
+	}
+	return rip, rvia, err
 }
 
-func (fs *FileSystem) lastDir(proc *Process, path string) (*common.Inode, string, error) {
+func (fs *FileSystem) lastDir(proc *Process, start *filp, path string) (*common.Inode, string, error) {
+	loops := 0
+	dirp, via := start.lookupDir()
+	rip, _, rest, err := fs.lastDirFrom(proc, dirp, via, path, &loops)
+	return rip, rest, err
+}
+
+// Return the directory containing the final component of 'path', along with
+// the trail of mounts crossed to reach it and the final component itself.
+// Relative paths start from 'start', reached along 'via', if it is not nil.
+func (fs *FileSystem) lastDirFrom(proc *Process, start *common.Inode, via trail, path string, loops *int) (*common.Inode, trail, string, error) {
 	var rip *common.Inode
-	if filepath.IsAbs(path) {
-		rip = proc.rootdir
+	if strings.HasPrefix(path, "/") {
+		// Lookups never go above the root directory, so the way it was
+		// reached does not matter
+		rip, via = proc.rootdir, nil
+	} else if start != nil {
+		rip = start
 	} else {
-		rip = proc.workdir
+		rip, via = proc.workdir, proc.workvia
 	}
 
-	// If directory has been removed or path is empty, return ENOENT
-	if rip.Nlinks == 0 || len(path) == 0 {
-		return nil, "", common.ENOENT
+	// If directory has been removed or revoked by a forced unmount, or path
+	// is empty, return ENOENT
+	if rip == nil || rip.Nlinks == 0 || len(path) == 0 {
+		return nil, nil, "", common.ENOENT
 	}
 
-	// We're going to use this inode, so make a copy of it
+	// We're going to use this inode, so make a copy of it. A directory may
+	// have been mounted on the starting directory.
+	rip, via = proc.ns.crossFrom(rip, via)
 	rip = fs.itable.DupInode(rip)
 
-	pathlist := strings.Split(path, string(filepath.Separator))
-	if filepath.IsAbs(path) {
-		pathlist = pathlist[1:]
+	pathlist, err := splitPath(path)
+	if err != nil {
+		fs.itable.PutInode(rip)
+		return nil, nil, "", err
+	}
+	if len(pathlist) == 0 {
+		return rip, via, "", nil // the path only names the starting directory
 	}
 
 	// Scan the path component by component
 	for i := 0; i < len(pathlist)-1; i++ {
 		// Fetch the next component in the path
-		newrip, err := fs.advance(proc, rip, pathlist[i])
+		newrip, newvia, err := fs.advanceFrom(proc, rip, via, pathlist[i])
+		if newrip == nil || err != nil {
+			fs.itable.PutInode(rip)
+			if err == nil {
+				err = common.ENOENT
+			}
+			return nil, nil, "", err
+		}
+
+		// Intermediate symbolic links are always followed
+		newrip, newvia, err = fs.followLink(proc, rip, via, newrip, newvia, loops)
 
 		// Current inode obsolete or irrelevant
 		fs.itable.PutInode(rip)
-		if newrip == nil || err != nil {
-			return nil, "", common.ENOENT
+		if err != nil {
+			return nil, nil, "", err
 		}
 		// Continue to the next component
-		rip = newrip
+		rip, via = newrip, newvia
 	}
 
 	if rip.Type() != common.I_DIRECTORY {
 		// The penultimate path entry was not a directory, so return nil
 		fs.itable.PutInode(rip)
-		return nil, "", common.ENOTDIR
+		return nil, nil, "", common.ENOTDIR
 	}
 
-	return rip, pathlist[len(pathlist)-1], nil
+	return rip, via, pathlist[len(pathlist)-1], nil
+}
+
+// Split a path into its components. Empty components are dropped, so that
+// repeated, leading and trailing separators have no effect. Returns
+// ENAMETOOLONG if any component is longer than NAME_MAX, rather than letting
+// it be truncated when it is entered in a directory.
+func splitPath(path string) ([]string, error) {
+	var pathlist []string
+	for _, name := range strings.Split(path, "/") {
+		if len(name) > common.NAME_MAX {
+			return nil, common.ENAMETOOLONG
+		}
+		if name != "" {
+			pathlist = append(pathlist, name)
+		}
+	}
+	return pathlist, nil
 }
 
+// If 'rip', reached along 'rvia', is a symbolic link, release it and return
+// the inode it refers to instead. Relative link targets are resolved from
+// 'dirp', the directory that contains the link, reached along 'dvia'. Returns
+// ELOOP when too many links have been followed.
+func (fs *FileSystem) followLink(proc *Process, dirp *common.Inode, dvia trail, rip *common.Inode, rvia trail, loops *int) (*common.Inode, trail, error) {
+	if rip.Type() != common.I_SYMBOLIC_LINK {
+		return rip, rvia, nil
+	}
+
+	*loops++
+	if *loops > common.SYMLOOP {
+		fs.itable.PutInode(rip)
+		return nil, nil, common.ELOOP
+	}
+
+	target, err := readLink(rip)
+	fs.itable.PutInode(rip)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fs.resolve(proc, dirp, dvia, target, true, loops)
+}
+
+// Read the target of a symbolic link, which is stored as the contents of the
+// link's first data zone.
+func readLink(rip *common.Inode) (string, error) {
+	if rip.Size == 0 {
+		return "", common.ENOENT
+	}
+
+	buf := make([]byte, rip.Size)
+	n, err := common.Read(rip, buf, 0)
+	if err != nil {
+		return "", err
+	}
+	return string(buf[:n]), nil
+}
+
+// Look up the single component 'path' in the directory 'dirp', without
+// knowing the mounts crossed to reach it. This is used for the final
+// component of a path that is being created, removed or renamed.
 func (fs *FileSystem) advance(proc *Process, dirp *common.Inode, path string) (*common.Inode, error) {
+	rip, _, err := fs.advanceFrom(proc, dirp, nil, path)
+	return rip, err
+}
+
+// As advance, for the directory 'dirp' reached along the trail 'via'. Returns
+// the trail that the inode found was reached along.
+func (fs *FileSystem) advanceFrom(proc *Process, dirp *common.Inode, via trail, path string) (*common.Inode, trail, error) {
 	// if there is no path, just return this inode
 	if len(path) == 0 {
-		return fs.itable.DupInode(dirp), nil
+		return fs.itable.DupInode(dirp), via, nil
 	}
 
 	// check for a nil inode
 	if dirp == nil {
-		return nil, common.ENOENT
+		return nil, nil, common.ENOENT
 	}
 
 	// don't go beyond the current root directory, ever
 	if dirp == proc.rootdir && path == ".." {
-		return fs.itable.DupInode(dirp), nil
+		rip, via := proc.ns.crossFrom(dirp, via)
+		return fs.itable.DupInode(rip), via, nil
+	}
+
+	// The directory must be searchable
+	if err := forbidden(proc, dirp, common.X_BIT); err != nil {
+		return nil, nil, err
+	}
+
+	// The parent of a directory reached through a mount is the parent of
+	// the directory it is mounted on.
+	if path == ".." {
+		if m, mvia := proc.ns.mountOf(dirp, via); m != nil {
+			return fs.advanceFrom(proc, m.point, mvia, path)
+		}
 	}
 
 	// If 'path' is not present in the directory, signal error
@@ -94,44 +257,86 @@ func (fs *FileSystem) advance(proc *Process, dirp *common.Inode, path string) (*
 	}
 
 	if err != nil {
-		return nil, common.ENOENT
-	}
-
-	//	if rip.Inum == common.ROOT_INODE {
-	//		if dirp.Inum == common.ROOT_INODE {
-	//			// TODO: What does this do?
-	//			if path[1] == '.' {
-	//				panic("weird case in lookup, what is this?")
-	//				if fs.devices[devnum] != nil {
-	//					// we can skip the superblock search here since we know
-	//					// that 'i' is the device that we're looking at.
-	//					mountinfo := fs.mountinfo[devnum]
-	//					fs.itable.PutInode(rip)
-	//					mnt_dev := mountinfo.imount.Devnum()
-	//					inumb := mountinfo.imount.Inum()
-	//					rip2, _ := fs.itable.GetInode(mnt_dev, inumb) // TODO: ignore error
-	//					rip, _ = fs.advance(proc, rip2, path)
-	//					fs.itable.PutInode(rip2)
-	//				}
-	//			}
-	//		}
-	//	}
+		return nil, nil, common.ENOENT
+	}
 
 	if rip == nil {
-		return nil, nil // TODO: Error here?
-	}
-
-	// See if the inode is mounted on. If so, switch to the root directory of
-	// the mounted file system. The super_block provides the linkage between
-	// the inode mounted on and the root directory of the mounted file system.
-	// TODO: MOUNTING RIGHT NOW IS NOT VERY ROBUST
-	if rip != nil && rip.Mounted != nil {
-		// The inode is indeed mounted on
-		// Release the inode that is mounted on and replace it with the root
-		// inode of the mounted device
-		minfo := rip.Mounted
+		return nil, nil, nil // TODO: Error here?
+	}
+
+	// See if the inode is mounted on in the namespace of the process. If so,
+	// release it and switch to the directory mounted on it, which is the root
+	// directory of a mounted file system or a directory bound there.
+	if top, tvia := proc.ns.crossFrom(rip, via); top != rip {
+		fs.itable.PutInode(rip)
+		rip, via = fs.itable.DupInode(top), tvia
+	}
+	return rip, via, nil
+}
+
+// Find the path of the directory 'dirp' from the directory 'root', or from
+// the root of the file system if 'root' is nil, by following ".." and
+// searching each parent for the entry naming the child. Mount points are
+// crossed on the way up, as they are by advance, using the trail 'via' that
+// 'dirp' was reached along in 'ns', or the mounted devices if 'ns' is nil.
+// Returns ENOENT if 'dirp' is not below 'root', or has been removed.
+func (fs *FileSystem) dirPath(ns *namespace, root, dirp *common.Inode, via trail) (string, error) {
+	var names []string
+
+	rip := fs.itable.DupInode(dirp)
+	for rip != root {
+		// Move from a mounted directory to its mount point
+		var mp *common.Inode
+		if ns != nil {
+			var m *mount
+			if m, via = ns.mountOf(rip, via); m != nil {
+				mp = m.point
+			}
+		} else if rip.Mounted != nil && rip == rip.Mounted.MountTarget {
+			mp = rip.Mounted.MountPoint
+			if mp == nil {
+				fs.itable.PutInode(rip)
+				return "", common.ENOENT // detached from the hierarchy
+			}
+		}
+		if mp != nil {
+			fs.itable.PutInode(rip)
+			rip = fs.itable.DupInode(mp)
+			continue
+		}
+		if rip.Devinfo.Devnum == common.ROOT_DEVICE && rip.Inum == common.ROOT_INODE {
+			if root != nil {
+				fs.itable.PutInode(rip)
+				return "", common.ENOENT
+			}
+			break
+		}
+
+		ok, dnum, inum := Lookup(rip, "..")
+		if !ok {
+			fs.itable.PutInode(rip)
+			return "", common.ENOENT
+		}
+		parent, err := fs.itable.GetInode(dnum, inum)
+		if err != nil {
+			fs.itable.PutInode(rip)
+			return "", err
+		}
+
+		ok, name := LookupName(parent, rip.Inum)
 		fs.itable.PutInode(rip)
-		rip = fs.itable.DupInode(minfo.MountTarget)
+		if !ok {
+			fs.itable.PutInode(parent)
+			return "", common.ENOENT
+		}
+		names = append(names, name)
+		rip = parent
+	}
+	fs.itable.PutInode(rip)
+
+	// The names were collected from the bottom up
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
 	}
-	return rip, nil
+	return "/" + strings.Join(names, "/"), nil
 }
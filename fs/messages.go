@@ -5,28 +5,46 @@ import (
 )
 
 type req_FS_Mount struct {
-	proc *Process
-	dev  common.BlockDevice
-	path string
+	proc  *Process
+	dev   common.BlockDevice
+	path  string
+	flags int
 }
 type res_FS_Mount struct {
 	Arg0 error
 }
 type req_FS_Unmount struct {
-	proc *Process
-	path string
+	proc  *Process
+	path  string
+	flags int
 }
 type res_FS_Unmount struct {
 	Arg0 error
 }
+type req_FS_Bind struct {
+	proc *Process
+	src  string
+	dst  string
+}
+type res_FS_Bind struct {
+	Arg0 error
+}
 type req_FS_Sync struct {
 }
-type res_FS_Sync struct{}
+type res_FS_Sync struct {
+	Arg0 error
+}
 type req_FS_Shutdown struct {
 }
 type res_FS_Shutdown struct {
 	Arg0 error
 }
+type req_FS_Mounts struct {
+}
+type res_FS_Mounts struct {
+	Arg0 []common.MountStat
+	Arg1 error
+}
 type req_FS_Fork struct {
 	proc *Process
 }
@@ -34,6 +52,13 @@ type res_FS_Fork struct {
 	Arg0 *Process
 	Arg1 error
 }
+type req_FS_ForkNamespace struct {
+	proc *Process
+}
+type res_FS_ForkNamespace struct {
+	Arg0 *Process
+	Arg1 error
+}
 type req_FS_Exit struct {
 	proc *Process
 }
@@ -45,16 +70,70 @@ type req_FS_OpenCreat struct {
 	mode  uint16
 }
 type res_FS_OpenCreat struct {
-	Arg0 common.Fd
+	Arg0 int
 	Arg1 error
 }
 type req_FS_Close struct {
 	proc *Process
-	fd   common.Fd
+	fd   int
 }
 type res_FS_Close struct {
 	Arg0 error
 }
+type req_FS_OpenAt struct {
+	proc  *Process
+	dirfd int
+	path  string
+	flags int
+	mode  uint16
+}
+type res_FS_OpenAt struct {
+	Arg0 int
+	Arg1 error
+}
+type req_FS_Dup struct {
+	proc *Process
+	fd   int
+}
+type res_FS_Dup struct {
+	Arg0 int
+	Arg1 error
+}
+type req_FS_Dup2 struct {
+	proc  *Process
+	oldfd int
+	newfd int
+}
+type res_FS_Dup2 struct {
+	Arg0 int
+	Arg1 error
+}
+type req_FS_Fcntl struct {
+	proc *Process
+	fd   int
+	cmd  int
+	arg  int
+}
+type res_FS_Fcntl struct {
+	Arg0 int
+	Arg1 error
+}
+type req_FS_Getfd struct {
+	proc *Process
+	fd   int
+}
+type res_FS_Getfd struct {
+	Arg0 *filp
+	Arg1 error
+}
+type req_FS_CloseFile struct {
+	proc *Process
+	fd   int
+	filp *filp
+}
+type res_FS_CloseFile struct {
+	Arg0 error
+}
 type req_FS_Stat struct {
 	proc *Process
 	path string
@@ -63,6 +142,58 @@ type res_FS_Stat struct {
 	Arg0 *common.StatInfo
 	Arg1 error
 }
+type req_FS_FstatAt struct {
+	proc  *Process
+	dirfd int
+	path  string
+	flags int
+}
+type res_FS_FstatAt struct {
+	Arg0 *common.StatInfo
+	Arg1 error
+}
+type req_FS_Statfs struct {
+	proc *Process
+	path string
+}
+type res_FS_Statfs struct {
+	Arg0 *common.StatfsInfo
+	Arg1 error
+}
+type req_FS_Watch struct {
+	proc *Process
+	path string
+	mask int
+}
+type res_FS_Watch struct {
+	Arg0 <-chan common.Event
+	Arg1 func()
+	Arg2 error
+}
+type req_FS_ReadDir struct {
+	proc *Process
+	path string
+}
+type res_FS_ReadDir struct {
+	Arg0 []common.Dirent
+	Arg1 error
+}
+type req_FS_Getdents struct {
+	filp  *filp
+	count int
+}
+type res_FS_Getdents struct {
+	Arg0 []common.Dirent
+	Arg1 error
+}
+type req_FS_Lstat struct {
+	proc *Process
+	path string
+}
+type res_FS_Lstat struct {
+	Arg0 *common.StatInfo
+	Arg1 error
+}
 type req_FS_Chmod struct {
 	proc *Process
 	path string
@@ -71,6 +202,41 @@ type req_FS_Chmod struct {
 type res_FS_Chmod struct {
 	Arg0 error
 }
+type req_FS_Chown struct {
+	proc *Process
+	path string
+	uid  int
+	gid  int
+}
+type res_FS_Chown struct {
+	Arg0 error
+}
+type req_FS_Fchmod struct {
+	proc *Process
+	fd   int
+	mode uint16
+}
+type res_FS_Fchmod struct {
+	Arg0 error
+}
+type req_FS_Fchown struct {
+	proc *Process
+	fd   int
+	uid  int
+	gid  int
+}
+type res_FS_Fchown struct {
+	Arg0 error
+}
+type req_FS_Utime struct {
+	proc  *Process
+	path  string
+	atime int32
+	mtime int32
+}
+type res_FS_Utime struct {
+	Arg0 error
+}
 type req_FS_Link struct {
 	proc             *Process
 	oldpath, newpath string
@@ -85,6 +251,57 @@ type req_FS_Unlink struct {
 type res_FS_Unlink struct {
 	Arg0 error
 }
+type req_FS_UnlinkAt struct {
+	proc  *Process
+	dirfd int
+	path  string
+	flags int
+}
+type res_FS_UnlinkAt struct {
+	Arg0 error
+}
+type req_FS_Rename struct {
+	proc             *Process
+	oldpath, newpath string
+}
+type res_FS_Rename struct {
+	Arg0 error
+}
+type req_FS_RenameAt struct {
+	proc     *Process
+	olddirfd int
+	oldpath  string
+	newdirfd int
+	newpath  string
+}
+type res_FS_RenameAt struct {
+	Arg0 error
+}
+type req_FS_Symlink struct {
+	proc   *Process
+	target string
+	path   string
+}
+type res_FS_Symlink struct {
+	Arg0 error
+}
+type req_FS_Readlink struct {
+	proc *Process
+	path string
+}
+type res_FS_Readlink struct {
+	Arg0 string
+	Arg1 error
+}
+type req_FS_ReadlinkAt struct {
+	proc  *Process
+	dirfd int
+	path  string
+}
+type res_FS_ReadlinkAt struct {
+	Arg0 string
+	Arg1 error
+}
 type req_FS_Mkdir struct {
 	proc *Process
 	path string
@@ -93,6 +310,15 @@ type req_FS_Mkdir struct {
 type res_FS_Mkdir struct {
 	Arg0 error
 }
+type req_FS_MkdirAt struct {
+	proc  *Process
+	dirfd int
+	path  string
+	mode  uint16
+}
+type res_FS_MkdirAt struct {
+	Arg0 error
+}
 type req_FS_Rmdir struct {
 	proc *Process
 	path string
@@ -107,6 +333,55 @@ type req_FS_Chdir struct {
 type res_FS_Chdir struct {
 	Arg0 error
 }
+type req_FS_Chroot struct {
+	proc *Process
+	path string
+}
+type res_FS_Chroot struct {
+	Arg0 error
+}
+type req_FS_Fchdir struct {
+	proc *Process
+	fd   int
+}
+type res_FS_Fchdir struct {
+	Arg0 error
+}
+type req_FS_Getcwd struct {
+	proc *Process
+}
+type res_FS_Getcwd struct {
+	Arg0 string
+	Arg1 error
+}
+type req_FS_Setuid struct {
+	proc *Process
+	uid  int
+}
+type res_FS_Setuid struct {
+	Arg0 error
+}
+type req_FS_Setgid struct {
+	proc *Process
+	gid  int
+}
+type res_FS_Setgid struct {
+	Arg0 error
+}
+type req_FS_Setgroups struct {
+	proc   *Process
+	groups []int
+}
+type res_FS_Setgroups struct {
+	Arg0 error
+}
+type req_FS_SetOpenMax struct {
+	proc *Process
+	max  int
+}
+type res_FS_SetOpenMax struct {
+	Arg0 error
+}
 
 // Interface types and implementations
 type reqFS interface {
@@ -116,65 +391,197 @@ type resFS interface {
 	is_resFS()
 }
 
-func (r req_FS_Mount) is_reqFS()     {}
-func (r res_FS_Mount) is_resFS()     {}
-func (r req_FS_Unmount) is_reqFS()   {}
-func (r res_FS_Unmount) is_resFS()   {}
-func (r req_FS_Sync) is_reqFS()      {}
-func (r res_FS_Sync) is_resFS()      {}
-func (r req_FS_Shutdown) is_reqFS()  {}
-func (r res_FS_Shutdown) is_resFS()  {}
-func (r req_FS_Fork) is_reqFS()      {}
-func (r res_FS_Fork) is_resFS()      {}
-func (r req_FS_Exit) is_reqFS()      {}
-func (r res_FS_Exit) is_resFS()      {}
-func (r req_FS_OpenCreat) is_reqFS() {}
-func (r res_FS_OpenCreat) is_resFS() {}
-func (r req_FS_Close) is_reqFS()     {}
-func (r res_FS_Close) is_resFS()     {}
-func (r req_FS_Stat) is_reqFS()      {}
-func (r res_FS_Stat) is_resFS()      {}
-func (r req_FS_Chmod) is_reqFS()     {}
-func (r res_FS_Chmod) is_resFS()     {}
-func (r req_FS_Link) is_reqFS()      {}
-func (r res_FS_Link) is_resFS()      {}
-func (r req_FS_Unlink) is_reqFS()    {}
-func (r res_FS_Unlink) is_resFS()    {}
-func (r req_FS_Mkdir) is_reqFS()     {}
-func (r res_FS_Mkdir) is_resFS()     {}
-func (r req_FS_Rmdir) is_reqFS()     {}
-func (r res_FS_Rmdir) is_resFS()     {}
-func (r req_FS_Chdir) is_reqFS()     {}
-func (r res_FS_Chdir) is_resFS()     {}
+func (r req_FS_Mount) is_reqFS()         {}
+func (r res_FS_Mount) is_resFS()         {}
+func (r req_FS_Unmount) is_reqFS()       {}
+func (r res_FS_Unmount) is_resFS()       {}
+func (r req_FS_Bind) is_reqFS()          {}
+func (r res_FS_Bind) is_resFS()          {}
+func (r req_FS_Sync) is_reqFS()          {}
+func (r res_FS_Sync) is_resFS()          {}
+func (r req_FS_Shutdown) is_reqFS()      {}
+func (r res_FS_Shutdown) is_resFS()      {}
+func (r req_FS_Mounts) is_reqFS()        {}
+func (r res_FS_Mounts) is_resFS()        {}
+func (r req_FS_Fork) is_reqFS()          {}
+func (r res_FS_Fork) is_resFS()          {}
+func (r req_FS_ForkNamespace) is_reqFS() {}
+func (r res_FS_ForkNamespace) is_resFS() {}
+func (r req_FS_Exit) is_reqFS()          {}
+func (r res_FS_Exit) is_resFS()          {}
+func (r req_FS_OpenCreat) is_reqFS()     {}
+func (r res_FS_OpenCreat) is_resFS()     {}
+func (r req_FS_Close) is_reqFS()         {}
+func (r res_FS_Close) is_resFS()         {}
+func (r req_FS_OpenAt) is_reqFS()        {}
+func (r res_FS_OpenAt) is_resFS()        {}
+func (r req_FS_Dup) is_reqFS()           {}
+func (r res_FS_Dup) is_resFS()           {}
+func (r req_FS_Dup2) is_reqFS()          {}
+func (r res_FS_Dup2) is_resFS()          {}
+func (r req_FS_Fcntl) is_reqFS()         {}
+func (r res_FS_Fcntl) is_resFS()         {}
+func (r req_FS_Getfd) is_reqFS()         {}
+func (r res_FS_Getfd) is_resFS()         {}
+func (r req_FS_CloseFile) is_reqFS()     {}
+func (r res_FS_CloseFile) is_resFS()     {}
+func (r req_FS_Stat) is_reqFS()          {}
+func (r res_FS_Stat) is_resFS()          {}
+func (r req_FS_FstatAt) is_reqFS()       {}
+func (r res_FS_FstatAt) is_resFS()       {}
+func (r req_FS_Statfs) is_reqFS()        {}
+func (r res_FS_Statfs) is_resFS()        {}
+func (r req_FS_Watch) is_reqFS()         {}
+func (r res_FS_Watch) is_resFS()         {}
+func (r req_FS_ReadDir) is_reqFS()       {}
+func (r res_FS_ReadDir) is_resFS()       {}
+func (r req_FS_Getdents) is_reqFS()      {}
+func (r res_FS_Getdents) is_resFS()      {}
+func (r req_FS_Lstat) is_reqFS()         {}
+func (r res_FS_Lstat) is_resFS()         {}
+func (r req_FS_Chmod) is_reqFS()         {}
+func (r res_FS_Chmod) is_resFS()         {}
+func (r req_FS_Chown) is_reqFS()         {}
+func (r res_FS_Chown) is_resFS()         {}
+func (r req_FS_Fchmod) is_reqFS()        {}
+func (r res_FS_Fchmod) is_resFS()        {}
+func (r req_FS_Fchown) is_reqFS()        {}
+func (r res_FS_Fchown) is_resFS()        {}
+func (r req_FS_Utime) is_reqFS()         {}
+func (r res_FS_Utime) is_resFS()         {}
+func (r req_FS_Link) is_reqFS()          {}
+func (r res_FS_Link) is_resFS()          {}
+func (r req_FS_Unlink) is_reqFS()        {}
+func (r res_FS_Unlink) is_resFS()        {}
+func (r req_FS_UnlinkAt) is_reqFS()      {}
+func (r res_FS_UnlinkAt) is_resFS()      {}
+func (r req_FS_Rename) is_reqFS()        {}
+func (r res_FS_Rename) is_resFS()        {}
+func (r req_FS_RenameAt) is_reqFS()      {}
+func (r res_FS_RenameAt) is_resFS()      {}
+func (r req_FS_Symlink) is_reqFS()       {}
+func (r res_FS_Symlink) is_resFS()       {}
+func (r req_FS_Readlink) is_reqFS()      {}
+func (r res_FS_Readlink) is_resFS()      {}
+func (r req_FS_ReadlinkAt) is_reqFS()    {}
+func (r res_FS_ReadlinkAt) is_resFS()    {}
+func (r req_FS_Mkdir) is_reqFS()         {}
+func (r res_FS_Mkdir) is_resFS()         {}
+func (r req_FS_MkdirAt) is_reqFS()       {}
+func (r res_FS_MkdirAt) is_resFS()       {}
+func (r req_FS_Rmdir) is_reqFS()         {}
+func (r res_FS_Rmdir) is_resFS()         {}
+func (r req_FS_Chdir) is_reqFS()         {}
+func (r res_FS_Chdir) is_resFS()         {}
+func (r req_FS_Chroot) is_reqFS()        {}
+func (r res_FS_Chroot) is_resFS()        {}
+func (r req_FS_Fchdir) is_reqFS()        {}
+func (r res_FS_Fchdir) is_resFS()        {}
+func (r req_FS_Getcwd) is_reqFS()        {}
+func (r res_FS_Getcwd) is_resFS()        {}
+func (r req_FS_Setuid) is_reqFS()        {}
+func (r res_FS_Setuid) is_resFS()        {}
+func (r req_FS_Setgid) is_reqFS()        {}
+func (r res_FS_Setgid) is_resFS()        {}
+func (r req_FS_Setgroups) is_reqFS()     {}
+func (r res_FS_Setgroups) is_resFS()     {}
+func (r req_FS_SetOpenMax) is_reqFS()    {}
+func (r res_FS_SetOpenMax) is_resFS()    {}
 
 // Type check request/response types
 var _ reqFS = req_FS_Mount{}
 var _ resFS = res_FS_Mount{}
 var _ reqFS = req_FS_Unmount{}
 var _ resFS = res_FS_Unmount{}
+var _ reqFS = req_FS_Bind{}
+var _ resFS = res_FS_Bind{}
 var _ reqFS = req_FS_Sync{}
 var _ resFS = res_FS_Sync{}
 var _ reqFS = req_FS_Shutdown{}
 var _ resFS = res_FS_Shutdown{}
+var _ reqFS = req_FS_Mounts{}
+var _ resFS = res_FS_Mounts{}
 var _ reqFS = req_FS_Fork{}
 var _ resFS = res_FS_Fork{}
+var _ reqFS = req_FS_ForkNamespace{}
+var _ resFS = res_FS_ForkNamespace{}
 var _ reqFS = req_FS_Exit{}
 var _ resFS = res_FS_Exit{}
 var _ reqFS = req_FS_OpenCreat{}
 var _ resFS = res_FS_OpenCreat{}
 var _ reqFS = req_FS_Close{}
 var _ resFS = res_FS_Close{}
+var _ reqFS = req_FS_OpenAt{}
+var _ resFS = res_FS_OpenAt{}
+var _ reqFS = req_FS_Dup{}
+var _ resFS = res_FS_Dup{}
+var _ reqFS = req_FS_Dup2{}
+var _ resFS = res_FS_Dup2{}
+var _ reqFS = req_FS_Fcntl{}
+var _ resFS = res_FS_Fcntl{}
+var _ reqFS = req_FS_Getfd{}
+var _ resFS = res_FS_Getfd{}
+var _ reqFS = req_FS_CloseFile{}
+var _ resFS = res_FS_CloseFile{}
 var _ reqFS = req_FS_Stat{}
 var _ resFS = res_FS_Stat{}
+var _ reqFS = req_FS_FstatAt{}
+var _ resFS = res_FS_FstatAt{}
+var _ reqFS = req_FS_Statfs{}
+var _ resFS = res_FS_Statfs{}
+var _ reqFS = req_FS_Watch{}
+var _ resFS = res_FS_Watch{}
+var _ reqFS = req_FS_ReadDir{}
+var _ resFS = res_FS_ReadDir{}
+var _ reqFS = req_FS_Getdents{}
+var _ resFS = res_FS_Getdents{}
+var _ reqFS = req_FS_Lstat{}
+var _ resFS = res_FS_Lstat{}
 var _ reqFS = req_FS_Chmod{}
 var _ resFS = res_FS_Chmod{}
+var _ reqFS = req_FS_Chown{}
+var _ resFS = res_FS_Chown{}
+var _ reqFS = req_FS_Fchmod{}
+var _ resFS = res_FS_Fchmod{}
+var _ reqFS = req_FS_Fchown{}
+var _ resFS = res_FS_Fchown{}
+var _ reqFS = req_FS_Utime{}
+var _ resFS = res_FS_Utime{}
 var _ reqFS = req_FS_Link{}
 var _ resFS = res_FS_Link{}
 var _ reqFS = req_FS_Unlink{}
 var _ resFS = res_FS_Unlink{}
+var _ reqFS = req_FS_UnlinkAt{}
+var _ resFS = res_FS_UnlinkAt{}
+var _ reqFS = req_FS_Rename{}
+var _ resFS = res_FS_Rename{}
+var _ reqFS = req_FS_RenameAt{}
+var _ resFS = res_FS_RenameAt{}
+var _ reqFS = req_FS_Symlink{}
+var _ resFS = res_FS_Symlink{}
+var _ reqFS = req_FS_Readlink{}
+var _ resFS = res_FS_Readlink{}
+var _ reqFS = req_FS_ReadlinkAt{}
+var _ resFS = res_FS_ReadlinkAt{}
 var _ reqFS = req_FS_Mkdir{}
 var _ resFS = res_FS_Mkdir{}
+var _ reqFS = req_FS_MkdirAt{}
+var _ resFS = res_FS_MkdirAt{}
 var _ reqFS = req_FS_Rmdir{}
 var _ resFS = res_FS_Rmdir{}
 var _ reqFS = req_FS_Chdir{}
 var _ resFS = res_FS_Chdir{}
+var _ reqFS = req_FS_Chroot{}
+var _ resFS = res_FS_Chroot{}
+var _ reqFS = req_FS_Fchdir{}
+var _ resFS = res_FS_Fchdir{}
+var _ reqFS = req_FS_Getcwd{}
+var _ resFS = res_FS_Getcwd{}
+var _ reqFS = req_FS_Setuid{}
+var _ resFS = res_FS_Setuid{}
+var _ reqFS = req_FS_Setgid{}
+var _ resFS = res_FS_Setgid{}
+var _ reqFS = req_FS_Setgroups{}
+var _ resFS = res_FS_Setgroups{}
+var _ reqFS = req_FS_SetOpenMax{}
+var _ resFS = res_FS_SetOpenMax{}
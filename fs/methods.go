@@ -4,61 +4,141 @@ import (
 	"github.com/jnwhiteh/minixfs/common"
 )
 
-func (s *FileSystem) Mount(proc *Process, dev common.BlockDevice, path string) error {
-	s.in <- req_FS_Mount{proc, dev, path}
+func (s *FileSystem) Mount(proc *Process, dev common.BlockDevice, path string, flags int) error {
+	s.in <- req_FS_Mount{proc, dev, path, flags}
 	result := (<-s.out).(res_FS_Mount)
 	return result.Arg0
 }
-func (s *FileSystem) Unmount(proc *Process, path string) error {
-	s.in <- req_FS_Unmount{proc, path}
+func (s *FileSystem) Unmount(proc *Process, path string, flags int) error {
+	s.in <- req_FS_Unmount{proc, path, flags}
 	result := (<-s.out).(res_FS_Unmount)
 	return result.Arg0
 }
-func (s *FileSystem) Sync() {
+func (s *FileSystem) Bind(proc *Process, src string, dst string) error {
+	s.in <- req_FS_Bind{proc, src, dst}
+	result := (<-s.out).(res_FS_Bind)
+	return result.Arg0
+}
+func (s *FileSystem) Sync() error {
 	s.in <- req_FS_Sync{}
-	<-s.out
-	return
+	result := (<-s.out).(res_FS_Sync)
+	return result.Arg0
 }
 func (s *FileSystem) Shutdown() error {
 	s.in <- req_FS_Shutdown{}
 	result := (<-s.out).(res_FS_Shutdown)
 	return result.Arg0
 }
+func (s *FileSystem) Mounts() ([]common.MountStat, error) {
+	s.in <- req_FS_Mounts{}
+	result := (<-s.out).(res_FS_Mounts)
+	return result.Arg0, result.Arg1
+}
 func (s *FileSystem) Fork(proc *Process) (*Process, error) {
 	s.in <- req_FS_Fork{proc}
 	result := (<-s.out).(res_FS_Fork)
 	return result.Arg0, result.Arg1
 }
+func (s *FileSystem) ForkNamespace(proc *Process) (*Process, error) {
+	s.in <- req_FS_ForkNamespace{proc}
+	result := (<-s.out).(res_FS_ForkNamespace)
+	return result.Arg0, result.Arg1
+}
 func (s *FileSystem) Exit(proc *Process) {
 	s.in <- req_FS_Exit{proc}
 	<-s.out
 	return
 }
-func (s *FileSystem) Open(proc *Process, path string, flags int, mode uint16) (common.Fd, error) {
+func (s *FileSystem) Open(proc *Process, path string, flags int, mode uint16) (int, error) {
 	s.in <- req_FS_OpenCreat{proc, path, flags, mode}
 	result := (<-s.out).(res_FS_OpenCreat)
 	return result.Arg0, result.Arg1
 }
-func (s *FileSystem) Creat(proc *Process, path string, flags int, mode uint16) (common.Fd, error) {
+func (s *FileSystem) Creat(proc *Process, path string, flags int, mode uint16) (int, error) {
 	s.in <- req_FS_OpenCreat{proc, path, flags, mode}
 	result := (<-s.out).(res_FS_OpenCreat)
 	return result.Arg0, result.Arg1
 }
-func (s *FileSystem) Close(proc *Process, fd common.Fd) error {
+func (s *FileSystem) Close(proc *Process, fd int) error {
 	proc.fs.in <- req_FS_Close{proc, fd}
 	result := (<-proc.fs.out).(res_FS_Close)
 	return result.Arg0
 }
+func (s *FileSystem) OpenAt(proc *Process, dirfd int, path string, flags int, mode uint16) (int, error) {
+	s.in <- req_FS_OpenAt{proc, dirfd, path, flags, mode}
+	result := (<-s.out).(res_FS_OpenAt)
+	return result.Arg0, result.Arg1
+}
+func (s *FileSystem) Dup(proc *Process, fd int) (int, error) {
+	s.in <- req_FS_Dup{proc, fd}
+	result := (<-s.out).(res_FS_Dup)
+	return result.Arg0, result.Arg1
+}
+func (s *FileSystem) Dup2(proc *Process, oldfd int, newfd int) (int, error) {
+	s.in <- req_FS_Dup2{proc, oldfd, newfd}
+	result := (<-s.out).(res_FS_Dup2)
+	return result.Arg0, result.Arg1
+}
+func (s *FileSystem) Fcntl(proc *Process, fd int, cmd int, arg int) (int, error) {
+	s.in <- req_FS_Fcntl{proc, fd, cmd, arg}
+	result := (<-s.out).(res_FS_Fcntl)
+	return result.Arg0, result.Arg1
+}
 func (s *FileSystem) Stat(proc *Process, path string) (*common.StatInfo, error) {
 	s.in <- req_FS_Stat{proc, path}
 	result := (<-s.out).(res_FS_Stat)
 	return result.Arg0, result.Arg1
 }
+func (s *FileSystem) FstatAt(proc *Process, dirfd int, path string, flags int) (*common.StatInfo, error) {
+	s.in <- req_FS_FstatAt{proc, dirfd, path, flags}
+	result := (<-s.out).(res_FS_FstatAt)
+	return result.Arg0, result.Arg1
+}
+func (s *FileSystem) Statfs(proc *Process, path string) (*common.StatfsInfo, error) {
+	s.in <- req_FS_Statfs{proc, path}
+	result := (<-s.out).(res_FS_Statfs)
+	return result.Arg0, result.Arg1
+}
+func (s *FileSystem) Watch(proc *Process, path string, mask int) (<-chan common.Event, func(), error) {
+	s.in <- req_FS_Watch{proc, path, mask}
+	result := (<-s.out).(res_FS_Watch)
+	return result.Arg0, result.Arg1, result.Arg2
+}
+func (s *FileSystem) ReadDir(proc *Process, path string) ([]common.Dirent, error) {
+	s.in <- req_FS_ReadDir{proc, path}
+	result := (<-s.out).(res_FS_ReadDir)
+	return result.Arg0, result.Arg1
+}
+func (s *FileSystem) Lstat(proc *Process, path string) (*common.StatInfo, error) {
+	s.in <- req_FS_Lstat{proc, path}
+	result := (<-s.out).(res_FS_Lstat)
+	return result.Arg0, result.Arg1
+}
 func (s *FileSystem) Chmod(proc *Process, path string, mode uint16) error {
 	s.in <- req_FS_Chmod{proc, path, mode}
 	result := (<-s.out).(res_FS_Chmod)
 	return result.Arg0
 }
+func (s *FileSystem) Chown(proc *Process, path string, uid, gid int) error {
+	s.in <- req_FS_Chown{proc, path, uid, gid}
+	result := (<-s.out).(res_FS_Chown)
+	return result.Arg0
+}
+func (s *FileSystem) Fchmod(proc *Process, fd int, mode uint16) error {
+	s.in <- req_FS_Fchmod{proc, fd, mode}
+	result := (<-s.out).(res_FS_Fchmod)
+	return result.Arg0
+}
+func (s *FileSystem) Fchown(proc *Process, fd int, uid, gid int) error {
+	s.in <- req_FS_Fchown{proc, fd, uid, gid}
+	result := (<-s.out).(res_FS_Fchown)
+	return result.Arg0
+}
+func (s *FileSystem) Utime(proc *Process, path string, atime, mtime int32) error {
+	s.in <- req_FS_Utime{proc, path, atime, mtime}
+	result := (<-s.out).(res_FS_Utime)
+	return result.Arg0
+}
 func (s *FileSystem) Link(proc *Process, oldpath, newpath string) error {
 	s.in <- req_FS_Link{proc, oldpath, newpath}
 	result := (<-s.out).(res_FS_Link)
@@ -69,11 +149,46 @@ func (s *FileSystem) Unlink(proc *Process, path string) error {
 	result := (<-s.out).(res_FS_Unlink)
 	return result.Arg0
 }
+func (s *FileSystem) UnlinkAt(proc *Process, dirfd int, path string, flags int) error {
+	s.in <- req_FS_UnlinkAt{proc, dirfd, path, flags}
+	result := (<-s.out).(res_FS_UnlinkAt)
+	return result.Arg0
+}
+func (s *FileSystem) Rename(proc *Process, oldpath, newpath string) error {
+	s.in <- req_FS_Rename{proc, oldpath, newpath}
+	result := (<-s.out).(res_FS_Rename)
+	return result.Arg0
+}
+func (s *FileSystem) RenameAt(proc *Process, olddirfd int, oldpath string, newdirfd int, newpath string) error {
+	s.in <- req_FS_RenameAt{proc, olddirfd, oldpath, newdirfd, newpath}
+	result := (<-s.out).(res_FS_RenameAt)
+	return result.Arg0
+}
+func (s *FileSystem) Symlink(proc *Process, target, path string) error {
+	s.in <- req_FS_Symlink{proc, target, path}
+	result := (<-s.out).(res_FS_Symlink)
+	return result.Arg0
+}
+func (s *FileSystem) Readlink(proc *Process, path string) (string, error) {
+	s.in <- req_FS_Readlink{proc, path}
+	result := (<-s.out).(res_FS_Readlink)
+	return result.Arg0, result.Arg1
+}
+func (s *FileSystem) ReadlinkAt(proc *Process, dirfd int, path string) (string, error) {
+	s.in <- req_FS_ReadlinkAt{proc, dirfd, path}
+	result := (<-s.out).(res_FS_ReadlinkAt)
+	return result.Arg0, result.Arg1
+}
 func (s *FileSystem) Mkdir(proc *Process, path string, mode uint16) error {
 	s.in <- req_FS_Mkdir{proc, path, mode}
 	result := (<-s.out).(res_FS_Mkdir)
 	return result.Arg0
 }
+func (s *FileSystem) MkdirAt(proc *Process, dirfd int, path string, mode uint16) error {
+	s.in <- req_FS_MkdirAt{proc, dirfd, path, mode}
+	result := (<-s.out).(res_FS_MkdirAt)
+	return result.Arg0
+}
 func (s *FileSystem) Rmdir(proc *Process, path string) error {
 	s.in <- req_FS_Rmdir{proc, path}
 	result := (<-s.out).(res_FS_Rmdir)
@@ -84,3 +199,38 @@ func (s *FileSystem) Chdir(proc *Process, path string) error {
 	result := (<-s.out).(res_FS_Chdir)
 	return result.Arg0
 }
+func (s *FileSystem) Chroot(proc *Process, path string) error {
+	s.in <- req_FS_Chroot{proc, path}
+	result := (<-s.out).(res_FS_Chroot)
+	return result.Arg0
+}
+func (s *FileSystem) Fchdir(proc *Process, fd int) error {
+	s.in <- req_FS_Fchdir{proc, fd}
+	result := (<-s.out).(res_FS_Fchdir)
+	return result.Arg0
+}
+func (s *FileSystem) Getcwd(proc *Process) (string, error) {
+	s.in <- req_FS_Getcwd{proc}
+	result := (<-s.out).(res_FS_Getcwd)
+	return result.Arg0, result.Arg1
+}
+func (s *FileSystem) Setuid(proc *Process, uid int) error {
+	s.in <- req_FS_Setuid{proc, uid}
+	result := (<-s.out).(res_FS_Setuid)
+	return result.Arg0
+}
+func (s *FileSystem) Setgid(proc *Process, gid int) error {
+	s.in <- req_FS_Setgid{proc, gid}
+	result := (<-s.out).(res_FS_Setgid)
+	return result.Arg0
+}
+func (s *FileSystem) Setgroups(proc *Process, groups []int) error {
+	s.in <- req_FS_Setgroups{proc, groups}
+	result := (<-s.out).(res_FS_Setgroups)
+	return result.Arg0
+}
+func (s *FileSystem) SetOpenMax(proc *Process, max int) error {
+	s.in <- req_FS_SetOpenMax{proc, max}
+	result := (<-s.out).(res_FS_SetOpenMax)
+	return result.Arg0
+}
@@ -2,6 +2,8 @@ package fs
 
 import (
 	"encoding/binary"
+	"errors"
+	"github.com/jnwhiteh/minixfs/common"
 	"github.com/jnwhiteh/minixfs/device"
 	. "github.com/jnwhiteh/minixfs/testutils"
 	"testing"
@@ -12,14 +14,14 @@ func TestMount(test *testing.T) {
 	fs, proc := OpenMinixImage(test)
 
 	// Create a secondary device to mount
-	imageFilename := getExtraFilename("minix3root.img")
+	imageFilename := minixImage(test)
 	dev, err := device.NewFileDevice(imageFilename, binary.LittleEndian)
 	if err != nil {
 		FatalHere(test, "Failed when creating new device: %s", err)
 	}
 
 	// Mount it on /mnt, so that is a mirror of the root filesystem
-	err = fs.Mount(proc, dev, "/mnt")
+	err = fs.Mount(proc, dev, "/mnt", 0)
 	if err != nil {
 		FatalHere(test, "Failed when mounting: %s", err)
 	}
@@ -52,18 +54,18 @@ func TestUnmount(test *testing.T) {
 	fs, proc := OpenMinixImage(test)
 
 	// Create a secondary device to mount
-	imageFilename := getExtraFilename("minix3root.img")
+	imageFilename := minixImage(test)
 	dev, err := device.NewFileDevice(imageFilename, binary.LittleEndian)
 	if err != nil {
 		FatalHere(test, "Failed when creating new device: %s", err)
 	}
 
 	// Mount it on /mnt, so that is a mirror of the root filesystem
-	err = fs.Mount(proc, dev, "/mnt")
+	err = fs.Mount(proc, dev, "/mnt", 0)
 	if err != nil {
 		FatalHere(test, "Failed when mounting: %s", err)
 	}
-	err = fs.Unmount(proc, "/mnt")
+	err = fs.Unmount(proc, "/mnt", 0)
 	if err != nil {
 		FatalHere(test, "Failed when unmounting: %s", err)
 	}
@@ -91,3 +93,246 @@ func TestUnmount(test *testing.T) {
 		FatalHere(test, "Failed when shutting down filesystem: %s", err)
 	}
 }
+
+// Test that ".." from the root of a mounted file system leads to the parent
+// of the directory it is mounted on.
+func TestMountDotDot(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	dev, err := device.NewFileDevice(minixImage(test), binary.LittleEndian)
+	if err != nil {
+		FatalHere(test, "Failed when creating new device: %s", err)
+	}
+	if err = fs.Mount(proc, dev, "/mnt", 0); err != nil {
+		FatalHere(test, "Failed when mounting: %s", err)
+	}
+
+	for _, path := range []string{"/mnt/..", "/mnt/sample/../..", "/mnt/../mnt/.."} {
+		stat, err := proc.Stat(path)
+		if err != nil || stat.Dev != 0 || stat.Inum != 1 {
+			ErrorHere(test, "Stat(%s) expected root device inode 1, got %+v (%v)", path, stat, err)
+		}
+	}
+
+	proc.Chdir("/mnt/sample")
+	if stat, err := proc.Stat("../../sample"); err != nil || stat.Dev != 0 || stat.Inum != 541 {
+		ErrorHere(test, "Relative lookup across mount got %+v (%v)", stat, err)
+	}
+	proc.Chdir("/")
+
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test that the mount table reports each device along with its mount point
+func TestMounts(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	imageFilename := minixImage(test)
+	for _, path := range []string{"/mnt", "/mnt/sample"} {
+		dev, err := device.NewFileDeviceReadOnly(imageFilename, binary.LittleEndian)
+		if err != nil {
+			FatalHere(test, "Failed when creating new device: %s", err)
+		}
+		if err = fs.Mount(proc, dev, path, common.MS_RDONLY); err != nil {
+			FatalHere(test, "Failed when mounting %s: %s", path, err)
+		}
+	}
+
+	mounts, err := fs.Mounts()
+	if err != nil || len(mounts) != 3 {
+		FatalHere(test, "Expected three mounts, got %+v (%v)", mounts, err)
+	}
+	for i, path := range []string{"/", "/mnt", "/mnt/sample"} {
+		if mounts[i].Path != path || mounts[i].Blocksize != 4096 {
+			ErrorHere(test, "Mount %d expected %s, got %+v", i, path, mounts[i])
+		}
+	}
+	if mounts[0].Devnum != common.ROOT_DEVICE || mounts[0].Flags != 0 || mounts[2].Flags != common.MS_RDONLY {
+		ErrorHere(test, "Unexpected mount flags: %+v", mounts)
+	}
+	if mounts[1].FreeInodes != mounts[0].FreeInodes || mounts[1].FreeZones != mounts[0].FreeZones {
+		ErrorHere(test, "Mirrored devices have different free counts: %+v", mounts)
+	}
+
+	// Allocating a file and a zone on the root device shows up in its counts
+	createFile(test, proc, "/tmp/mounts", "data")
+	after, _ := fs.Mounts()
+	if after[0].FreeInodes != mounts[0].FreeInodes-1 || after[0].FreeZones != mounts[0].FreeZones-1 {
+		ErrorHere(test, "Free counts went from %+v to %+v", mounts[0], after[0])
+	}
+	proc.Unlink("/tmp/mounts")
+
+	// Unmount everything, deepest first
+	for i := len(mounts) - 1; i > 0; i-- {
+		if err = fs.Unmount(proc, mounts[i].Path, 0); err != nil {
+			ErrorHere(test, "Failed when unmounting %s: %s", mounts[i].Path, err)
+		}
+	}
+	if mounts, _ = fs.Mounts(); len(mounts) != 1 {
+		ErrorHere(test, "Expected only the root device, got %+v", mounts)
+	}
+
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Mount a mirror of the root file system on /mnt, returning the number of
+// the device slot it was mounted in.
+func mountMirror(test *testing.T, fs *FileSystem, proc *Process) int {
+	dev, err := device.NewFileDevice(minixImage(test), binary.LittleEndian)
+	if err != nil {
+		FatalLevel(test, 2, "Failed when creating new device: %s", err)
+	}
+	if err = fs.Mount(proc, dev, "/mnt", 0); err != nil {
+		FatalLevel(test, 2, "Failed when mounting: %s", err)
+	}
+	stat, err := proc.Stat("/mnt")
+	if err != nil {
+		FatalLevel(test, 2, "Failed when calling stat: %s", err)
+	}
+	return stat.Dev
+}
+
+// Test that a forced unmount revokes open files and moves working
+// directories off the device.
+func TestUnmountForce(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+	mountMirror(test, fs, proc)
+
+	file, err := proc.Open("/mnt/sample/europarl-en.txt", common.O_RDONLY, 0)
+	if err != nil {
+		FatalHere(test, "Failed when opening file: %s", err)
+	}
+	child := forkAs(test, proc, 0, 0)
+	if err = child.Chdir("/mnt/sample"); err != nil {
+		FatalHere(test, "Failed when changing directory: %s", err)
+	}
+
+	if err = proc.Unmount("/mnt", 0); !errors.Is(err, common.EBUSY) {
+		ErrorHere(test, "Expected EBUSY, got: %v", err)
+	}
+	if err = proc.Unmount("/mnt", common.MNT_FORCE); err != nil {
+		FatalHere(test, "Failed when forcing unmount: %s", err)
+	}
+	if mounts, _ := fs.Mounts(); len(mounts) != 1 {
+		ErrorHere(test, "Device is still mounted: %+v", mounts)
+	}
+
+	// Both the parent's and the child's copy of the descriptor are revoked
+	if _, err = proc.Read(file, make([]byte, 10)); !errors.Is(err, common.EBADF) {
+		ErrorHere(test, "Expected EBADF reading revoked file, got: %v", err)
+	}
+	if err = proc.Fchmod(file, 0600); !errors.Is(err, common.EBADF) {
+		ErrorHere(test, "Expected EBADF for fchmod of revoked file, got: %v", err)
+	}
+	if stat, err := child.Stat("."); err != nil || stat.Dev != common.ROOT_DEVICE || stat.Inum != 518 {
+		ErrorHere(test, "Working directory was not moved to /mnt, got %+v (%v)", stat, err)
+	}
+	if err = proc.Close(file); err != nil {
+		ErrorHere(test, "Failed when closing revoked file: %s", err)
+	}
+
+	fs.Exit(child)
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test that a process confined to a device that is forcibly unmounted is
+// not let out into the rest of the hierarchy.
+func TestUnmountForceChroot(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+	mountMirror(test, fs, proc)
+
+	jailed, _ := proc.Fork()
+	if err := jailed.Chroot("/mnt/sample"); err != nil {
+		FatalHere(test, "Failed when calling chroot: %s", err)
+	}
+	jailed.Chdir("/")
+	if err := proc.Unmount("/mnt", common.MNT_FORCE); err != nil {
+		FatalHere(test, "Failed when forcing unmount: %s", err)
+	}
+
+	for _, path := range []string{"/", "/mnt", "..", "."} {
+		if _, err := jailed.Stat(path); !errors.Is(err, common.ENOENT) {
+			ErrorHere(test, "Expected ENOENT for %s, got: %v", path, err)
+		}
+	}
+	if _, err := jailed.Getcwd(); !errors.Is(err, common.ENOENT) {
+		ErrorHere(test, "Expected ENOENT, got: %v", err)
+	}
+	child, err := jailed.Fork()
+	if err != nil {
+		FatalHere(test, "Failed when forking: %s", err)
+	}
+	if _, err := child.Stat("/sample"); !errors.Is(err, common.ENOENT) {
+		ErrorHere(test, "Expected ENOENT in child, got: %v", err)
+	}
+
+	fs.Exit(child)
+	fs.Exit(jailed)
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test that a detached device disappears from the hierarchy at once, but
+// stays usable until the last reference to it has gone.
+func TestUnmountDetach(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+	devnum := mountMirror(test, fs, proc)
+
+	file, err := proc.Open("/mnt/sample/europarl-en.txt", common.O_RDONLY, 0)
+	if err != nil {
+		FatalHere(test, "Failed when opening file: %s", err)
+	}
+	child := forkAs(test, proc, 0, 0)
+	if err = child.Chdir("/mnt/sample"); err != nil {
+		FatalHere(test, "Failed when changing directory: %s", err)
+	}
+
+	if err = proc.Unmount("/mnt", common.MNT_DETACH); err != nil {
+		FatalHere(test, "Failed when detaching: %s", err)
+	}
+	if _, err = proc.Stat("/mnt/sample"); !errors.Is(err, common.ENOENT) {
+		ErrorHere(test, "Expected ENOENT for detached path, got: %v", err)
+	}
+	if mounts, _ := fs.Mounts(); len(mounts) != 1 {
+		ErrorHere(test, "Detached device is still listed: %+v", mounts)
+	}
+
+	// Existing references still work
+	if n, err := proc.Read(file, make([]byte, 10)); n != 10 || err != nil {
+		ErrorHere(test, "Failed reading detached file: %d, %v", n, err)
+	}
+	if stat, err := child.Stat("europarl-en.txt"); err != nil || stat.Dev != devnum {
+		ErrorHere(test, "Relative lookup on detached device got %+v (%v)", stat, err)
+	}
+
+	// A device that has been released frees its device number, which the
+	// next mount then takes.
+	proc.Close(file)
+	child.Close(file)
+	if other := mountMirror(test, fs, proc); other == devnum {
+		ErrorHere(test, "Device was released while still in use")
+	}
+	proc.Unmount("/mnt", 0)
+	child.Chdir("/")
+	if other := mountMirror(test, fs, proc); other != devnum {
+		ErrorHere(test, "Device was not released once unused")
+	}
+	proc.Unmount("/mnt", 0)
+
+	fs.Exit(child)
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
@@ -0,0 +1,181 @@
+package fs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"github.com/jnwhiteh/minixfs/common"
+	"github.com/jnwhiteh/minixfs/device"
+	"github.com/jnwhiteh/minixfs/testutils"
+	"io/ioutil"
+	"testing"
+)
+
+func hashFile(test *testing.T, filename string) [sha256.Size]byte {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		testutils.FatalLevel(test, 2, "Failed when reading %s: %s", filename, err)
+	}
+	return sha256.Sum256(data)
+}
+
+// Test that nothing can change a file system mounted read-only, and that the
+// image is left untouched.
+func TestReadOnly(test *testing.T) {
+	imageFilename := minixImage(test)
+	before := hashFile(test, imageFilename)
+
+	fs, proc, err := OpenFileSystemFile(imageFilename, common.MS_RDONLY)
+	if err != nil {
+		testutils.FatalHere(test, "Failed opening file system: %s", err)
+	}
+
+	// Reading works, but does not update the access time
+	stat, _ := proc.Stat("/sample/europarl-en.txt")
+	readWholeFile(test, proc, "/sample/europarl-en.txt", 100)
+	if after, _ := proc.Stat("/sample/europarl-en.txt"); after.Atime != stat.Atime {
+		testutils.ErrorHere(test, "Access time changed on read-only file system")
+	}
+
+	file, err := proc.Open("/sample/europarl-en.txt", common.O_RDONLY, 0)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when opening file: %s", err)
+	}
+
+	var errs = []error{
+		proc.Mkdir("/tmp/new", 0755),
+		proc.Rmdir("/tmp"),
+		proc.Unlink("/sample/europarl-en.txt"),
+		proc.Rename("/sample/europarl-en.txt", "/sample/moved"),
+		proc.Link("/sample/europarl-en.txt", "/sample/link"),
+		proc.Symlink("/sample", "/tmp/link"),
+		proc.Chmod("/sample/europarl-en.txt", 0777),
+		proc.Chown("/sample/europarl-en.txt", 1, 1),
+		proc.Utime("/sample/europarl-en.txt", 0, 0),
+		proc.Fchmod(file, 0777),
+	}
+	for _, mode := range []int{common.O_WRONLY, common.O_RDWR, common.O_RDONLY | common.O_TRUNC, common.O_CREAT | common.O_RDWR} {
+		_, err := proc.Open("/sample/europarl-en.txt", mode, 0666)
+		errs = append(errs, err)
+	}
+	_, err = proc.Open("/tmp/new", common.O_CREAT|common.O_RDWR, 0666)
+	errs = append(errs, err)
+
+	for i, err := range errs {
+		if !errors.Is(err, common.EROFS) {
+			testutils.ErrorHere(test, "Operation %d expected EROFS, got: %v", i, err)
+		}
+	}
+
+	proc.Close(file)
+	if err = proc.Sync(); err != nil {
+		testutils.ErrorHere(test, "Failed when syncing: %s", err)
+	}
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+
+	if hashFile(test, imageFilename) != before {
+		testutils.ErrorHere(test, "Image was modified by read-only file system")
+	}
+}
+
+// Test that a read-only mount does not affect the rest of the file system
+func TestMountReadOnly(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	dev, err := device.NewFileDeviceReadOnly(minixImage(test), binary.LittleEndian)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when creating new device: %s", err)
+	}
+	if err = proc.Mount(dev, "/mnt", common.MS_RDONLY); err != nil {
+		testutils.FatalHere(test, "Failed when mounting: %s", err)
+	}
+
+	if err = proc.Mkdir("/mnt/tmp/dir", 0755); !errors.Is(err, common.EROFS) {
+		testutils.ErrorHere(test, "Expected EROFS on read-only mount, got: %v", err)
+	}
+	if err = proc.Mkdir("/tmp/dir", 0755); err != nil {
+		testutils.ErrorHere(test, "Failed when creating directory: %s", err)
+	}
+	proc.Rmdir("/tmp/dir")
+
+	if err = proc.Unmount("/mnt", 0); err != nil {
+		testutils.ErrorHere(test, "Failed when unmounting: %s", err)
+	}
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test that access times are not updated with MS_NOATIME
+func TestNoatime(test *testing.T) {
+	clock := &testClock{1000}
+	dev, err := device.NewFileDevice(minixImage(test), binary.LittleEndian)
+	if err != nil {
+		testutils.FatalHere(test, "Failed opening device: %s", err)
+	}
+	fs, proc, err := NewFileSystem(dev, clock, common.MS_NOATIME)
+	if err != nil {
+		testutils.FatalHere(test, "Failed opening file system: %s", err)
+	}
+
+	createFile(test, proc, "/tmp/noatime", "data")
+	clock.now = 2000
+	readWholeFile(test, proc, "/tmp/noatime", 4)
+	checkTimes(test, proc, "/tmp/noatime", 1000, 1000, 1000)
+
+	proc.Unlink("/tmp/noatime")
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test that changes on a file system mounted with MS_SYNC reach the device
+// without an explicit sync.
+func TestMountSync(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	dev, err := device.NewFileDevice(minixImage(test), binary.LittleEndian)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when creating new device: %s", err)
+	}
+	if err = proc.Mount(dev, "/mnt", common.MS_SYNC); err != nil {
+		testutils.FatalHere(test, "Failed when mounting: %s", err)
+	}
+
+	data := bytes.Repeat([]byte("sync mount\n"), 1000)
+	file, err := proc.Open("/mnt/tmp/msync.txt", common.O_CREAT|common.O_RDWR, 0666)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when creating file: %s", err)
+	}
+	if _, err = proc.Write(file, data); err != nil {
+		testutils.FatalHere(test, "Failed when writing file: %s", err)
+	}
+
+	// A second file system on the same image only sees what is on the device
+	fs2, proc2, err := OpenFileSystemFile(minixImage(test), common.MS_RDONLY)
+	if err != nil {
+		testutils.FatalHere(test, "Failed opening file system: %s", err)
+	}
+	ondisk := readWholeFile(test, proc2, "/tmp/msync.txt", len(data)+1)
+	if bytes.Compare(ondisk, data) != 0 {
+		testutils.ErrorHere(test, "Data on device does not match data written (%d bytes, expected %d)", len(ondisk), len(data))
+	}
+	fs2.Exit(proc2)
+	fs2.Shutdown()
+
+	proc.Close(file)
+	proc.Unlink("/mnt/tmp/msync.txt")
+	if err = proc.Unmount("/mnt", 0); err != nil {
+		testutils.ErrorHere(test, "Failed when unmounting: %s", err)
+	}
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
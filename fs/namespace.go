@@ -0,0 +1,270 @@
+package fs
+
+import (
+	"github.com/jnwhiteh/minixfs/common"
+)
+
+// A namespace is the mount table seen by the processes that share it. A
+// process shares the namespace of its parent unless it was forked with a copy
+// of it, and mounts made in one namespace are not seen from any other.
+type namespace struct {
+	mounts []*mount // in the order they were made
+	users  int      // the number of processes using the namespace
+}
+
+// A mount grafts the directory 'target' onto 'point', so that a lookup which
+// arrives at 'point' carries on from 'target' instead, and ".." from 'target'
+// leads to the parent of 'point'. The inodes of a mounted device are held by
+// its MountInfo, but a bind holds its own references to both of them.
+type mount struct {
+	point  *common.Inode
+	target *common.Inode
+	bind   bool
+}
+
+// Return the mount that 'rip' is the point of, or nil. A later mount on the
+// same point hides the earlier ones.
+func (ns *namespace) mountOn(rip *common.Inode) *mount {
+	for i := len(ns.mounts) - 1; i >= 0; i-- {
+		if ns.mounts[i].point == rip {
+			return ns.mounts[i]
+		}
+	}
+	return nil
+}
+
+// Return whether the entry 'name' in the directory 'dirp' is the point of a
+// mount, before any mounts on it are crossed.
+func (ns *namespace) covers(dirp *common.Inode, name string) bool {
+	ok, dnum, inum := Lookup(dirp, name)
+	if !ok {
+		return false
+	}
+	for _, m := range ns.mounts {
+		if m.point.Devinfo.Devnum == dnum && m.point.Inum == inum {
+			return true
+		}
+	}
+	return false
+}
+
+// The mounts crossed on the way to a directory, innermost last. A directory
+// that has been bound elsewhere can still be reached from its own parent, so
+// it is the way a lookup came, not the directory, that decides where ".."
+// leads. Trails are shared, so they are never changed in place.
+type trail []*mount
+
+// Return 't' with the mount 'm' crossed after it
+func (t trail) push(m *mount) trail {
+	return append(t[:len(t):len(t)], m)
+}
+
+// Return the mount in the namespace that grafts the same directories as
+// 'm', which may belong to the namespace it was copied from, or nil if it
+// has since been removed.
+func (ns *namespace) find(m *mount) *mount {
+	for _, n := range ns.mounts {
+		if n.point == m.point && n.target == m.target {
+			return n
+		}
+	}
+	return nil
+}
+
+// Return the mount that 'rip' was reached through at the end of 't', along
+// with the trail that led to its point, or nil and 't' if 'rip' was reached
+// from its own parent.
+func (ns *namespace) mountOf(rip *common.Inode, t trail) (*mount, trail) {
+	if n := len(t); n > 0 && t[n-1].target == rip {
+		if m := ns.find(t[n-1]); m != nil {
+			return m, t[:n-1]
+		}
+	}
+	return nil, t
+}
+
+// Return the inode that is reached in place of 'rip', after crossing any
+// mounts on it.
+func (ns *namespace) cross(rip *common.Inode) *common.Inode {
+	rip, _ = ns.crossFrom(rip, nil)
+	return rip
+}
+
+// As cross, also returning the trail 't' that led to 'rip' extended with the
+// mounts crossed.
+func (ns *namespace) crossFrom(rip *common.Inode, t trail) (*common.Inode, trail) {
+	for m := ns.mountOn(rip); m != nil; m = ns.mountOn(rip) {
+		rip, t = m.target, t.push(m)
+	}
+	return rip, t
+}
+
+// Return whether the device 'devnum' is mounted in the namespace
+func (ns *namespace) hasDevice(devnum int) bool {
+	for _, m := range ns.mounts {
+		if !m.bind && m.target.Devinfo.Devnum == devnum {
+			return true
+		}
+	}
+	return false
+}
+
+func (ns *namespace) remove(m *mount) {
+	for i := range ns.mounts {
+		if ns.mounts[i] == m {
+			ns.mounts = append(ns.mounts[:i], ns.mounts[i+1:]...)
+			return
+		}
+	}
+}
+
+// Make a copy of the namespace 'ns', for a single process
+func (fs *FileSystem) copyNamespace(ns *namespace) *namespace {
+	nns := &namespace{users: 1}
+	for _, m := range ns.mounts {
+		if m.bind {
+			fs.itable.DupInode(m.point)
+			fs.itable.DupInode(m.target)
+		}
+		nns.mounts = append(nns.mounts, &mount{m.point, m.target, m.bind})
+	}
+	return nns
+}
+
+// Remove a mount from the namespace 'ns', releasing the inodes of a bind
+func (fs *FileSystem) unbind(ns *namespace, m *mount) {
+	ns.remove(m)
+	if m.bind {
+		fs.itable.PutInode(m.point)
+		fs.itable.PutInode(m.target)
+	}
+}
+
+// Stop the process 'proc', which has already left the process table, from
+// using its namespace. Once a namespace has no users left its binds are
+// released, and any device that was only mounted there is detached.
+func (fs *FileSystem) leaveNamespace(proc *Process) {
+	ns := proc.ns
+	if ns.users--; ns.users > 0 {
+		return
+	}
+	for len(ns.mounts) > 0 {
+		m := ns.mounts[len(ns.mounts)-1]
+		fs.unbind(ns, m)
+		if !m.bind && !fs.mountedElsewhere(m.target.Devinfo.Devnum, ns) {
+			fs.detach(m.target.Mounted)
+		}
+	}
+}
+
+// Remove every mount of the device 'devnum', and every bind of a directory on
+// it, from each namespace.
+func (fs *FileSystem) unmountAll(devnum int) {
+	for _, proc := range fs.procs {
+		ns := proc.ns
+		for i := len(ns.mounts) - 1; i >= 0; i-- {
+			m := ns.mounts[i]
+			if m.target.Devinfo.Devnum == devnum || m.point.Devinfo.Devnum == devnum {
+				fs.unbind(ns, m)
+			}
+		}
+	}
+}
+
+// Return whether the device 'devnum' is mounted in a namespace other than
+// 'ns'.
+func (fs *FileSystem) mountedElsewhere(devnum int, ns *namespace) bool {
+	for _, proc := range fs.procs {
+		if proc.ns != ns && proc.ns.hasDevice(devnum) {
+			return true
+		}
+	}
+	return false
+}
+
+// Graft the directory 'src' onto the directory 'dst' in the namespace of the
+// process. Only the superuser may change the namespace, as with mount.
+func (fs *FileSystem) do_bind(proc *Process, src, dst string) error {
+	if proc.effuid != common.SU_UID {
+		return common.EPERM
+	}
+
+	target, err := fs.eatPath(proc, src)
+	if err != nil {
+		return err
+	}
+	point, err := fs.eatPath(proc, dst)
+	if err != nil {
+		fs.itable.PutInode(target)
+		return err
+	}
+
+	// Each is already the top of any mounts on it. Binding a directory onto
+	// itself or anywhere below it would make a cycle, so that walking the
+	// tree below it would never end.
+	var r error
+	if !target.IsDirectory() || !point.IsDirectory() {
+		r = common.ENOTDIR
+	} else if proc.ns.cross(target) != target || proc.ns.cross(point) != point {
+		r = common.EINVAL
+	} else if below, err := fs.within(proc.ns, target, point); err != nil {
+		r = err
+	} else if below {
+		r = common.EINVAL
+	}
+	if r != nil {
+		fs.itable.PutInode(target)
+		fs.itable.PutInode(point)
+		return r
+	}
+
+	proc.ns.mounts = append(proc.ns.mounts, &mount{point, target, true})
+	return nil
+}
+
+// Return whether the directory 'rip' can be reached from the directory 'dirp'
+// in the namespace 'ns'. Every way up from 'rip' is searched, since a
+// directory that has been bound elsewhere can be reached both from its own
+// parent and from each place it has been bound.
+func (fs *FileSystem) within(ns *namespace, dirp, rip *common.Inode) (bool, error) {
+	// The inodes are held until the search is over, so that none of them
+	// can be reused for another inode while it is in 'seen'.
+	seen := make(map[*common.Inode]bool)
+	held := []*common.Inode{}
+	todo := []*common.Inode{fs.itable.DupInode(rip)}
+	defer func() {
+		for _, ip := range append(held, todo...) {
+			fs.itable.PutInode(ip)
+		}
+	}()
+
+	for len(todo) > 0 {
+		ip := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		held = append(held, ip)
+		if seen[ip] {
+			continue
+		} else if ip == dirp {
+			return true, nil
+		}
+		seen[ip] = true
+
+		for _, m := range ns.mounts {
+			if m.target == ip {
+				todo = append(todo, fs.itable.DupInode(m.point))
+			}
+		}
+
+		// The root directory of a device is its own parent
+		ok, dnum, inum := Lookup(ip, "..")
+		if !ok || inum == ip.Inum {
+			continue
+		}
+		parent, err := fs.itable.GetInode(dnum, inum)
+		if err != nil {
+			return false, err
+		}
+		todo = append(todo, parent)
+	}
+	return false, nil
+}
@@ -0,0 +1,225 @@
+package fs
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/jnwhiteh/minixfs/common"
+	"github.com/jnwhiteh/minixfs/device"
+	"github.com/jnwhiteh/minixfs/testutils"
+	"testing"
+)
+
+func forkNamespace(test *testing.T, proc *Process) *Process {
+	child, err := proc.ForkNamespace()
+	if err != nil {
+		testutils.FatalLevel(test, 2, "Failed when forking: %s", err)
+	}
+	return child
+}
+
+func expectStat(test *testing.T, proc *Process, path string, expected error) {
+	if _, err := proc.Stat(path); !errors.Is(err, expected) {
+		testutils.ErrorLevel(test, 2, "Stat(%s) expected %v, got: %v", path, expected, err)
+	}
+}
+
+// Test that a device mounted in a copy of the namespace is only seen by the
+// processes using that copy, and stays mounted while any namespace has it.
+func TestNamespaceMount(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+	tenant := forkNamespace(test, proc)
+
+	dev, err := device.NewFileDevice(minixImage(test), binary.LittleEndian)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when creating new device: %s", err)
+	}
+	if err = tenant.Mount(dev, "/mnt", 0); err != nil {
+		testutils.FatalHere(test, "Failed when mounting: %s", err)
+	}
+	stat, _ := tenant.Stat("/mnt")
+	devnum := stat.Dev
+
+	shared, _ := tenant.Fork()
+	shared.Chdir("/mnt/sample")
+	expectStat(test, tenant, "/mnt/sample", nil)
+	expectStat(test, shared, "/mnt/sample", nil)
+	expectStat(test, proc, "/mnt/sample", common.ENOENT)
+	if err = proc.Unmount("/mnt", 0); !errors.Is(err, common.EINVAL) {
+		testutils.ErrorHere(test, "Expected EINVAL, got: %v", err)
+	}
+
+	// Unmounting in one copy leaves the device mounted in the other
+	other := forkNamespace(test, tenant)
+	if err = tenant.Unmount("/mnt", 0); err != nil {
+		testutils.ErrorHere(test, "Failed when unmounting: %s", err)
+	}
+	expectStat(test, shared, "/mnt/sample", common.ENOENT)
+	expectStat(test, other, "/mnt/sample", nil)
+
+	// A directory on the device can no longer be named from the namespace
+	// it was unmounted in
+	if _, err = shared.Getcwd(); !errors.Is(err, common.ENOENT) {
+		testutils.ErrorHere(test, "Expected ENOENT, got: %v", err)
+	}
+	shared.Chdir("/")
+
+	// The device goes once the last namespace it is mounted in has gone,
+	// freeing its device number for the next mount
+	fs.Exit(other)
+	if next := mountMirror(test, fs, proc); next != devnum {
+		testutils.ErrorHere(test, "Device was not released with its namespace")
+	}
+	proc.Unmount("/mnt", 0)
+
+	fs.Exit(shared)
+	fs.Exit(tenant)
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test grafting a directory onto another path in a private namespace
+func TestBind(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+	proc.Mkdir("/tmp/ns", 0755)
+	proc.Mkdir("/tmp/ns/src", 0755)
+	proc.Mkdir("/tmp/ns/dst", 0755)
+	createFile(test, proc, "/tmp/ns/src/file", "data")
+	parent, _ := proc.Stat("/tmp/ns")
+
+	tenant := forkNamespace(test, proc)
+	if err := tenant.Bind("/tmp/ns/src", "/tmp/ns/dst"); err != nil {
+		testutils.FatalHere(test, "Failed when binding: %s", err)
+	}
+	expectStat(test, tenant, "/tmp/ns/dst/file", nil)
+	expectStat(test, proc, "/tmp/ns/dst/file", common.ENOENT)
+
+	// The bound directory appears to live where it was bound
+	tenant.Chdir("/tmp/ns/dst")
+	expectCwd(test, tenant, "/tmp/ns/dst")
+	if stat, err := tenant.Stat(".."); err != nil || stat.Inum != parent.Inum {
+		testutils.ErrorHere(test, "Expected parent inode %d, got %+v (%v)", parent.Inum, stat, err)
+	}
+	tenant.Chdir("/")
+
+	if err := tenant.Bind("/tmp/ns/src/file", "/tmp/ns/dst"); !errors.Is(err, common.ENOTDIR) {
+		testutils.ErrorHere(test, "Expected ENOTDIR, got: %v", err)
+	}
+	if err := tenant.Bind("/tmp/ns/src", "/tmp/ns/dst"); !errors.Is(err, common.EINVAL) {
+		testutils.ErrorHere(test, "Expected EINVAL, got: %v", err)
+	}
+
+	// A bind point can be neither removed nor replaced while it is bound
+	tenant.Mkdir("/tmp/ns/empty", 0755)
+	busy := map[string]error{
+		"rename":  tenant.Rename("/tmp/ns/dst", "/tmp/ns/moved"),
+		"replace": tenant.Rename("/tmp/ns/empty", "/tmp/ns/dst"),
+		"unlink":  tenant.Unlink("/tmp/ns/dst"),
+		"rmdir":   tenant.Rmdir("/tmp/ns/dst"),
+	}
+	for op, err := range busy {
+		if !errors.Is(err, common.EBUSY) {
+			testutils.ErrorHere(test, "Expected EBUSY from %s, got: %v", op, err)
+		}
+	}
+	tenant.Rmdir("/tmp/ns/empty")
+	expectStat(test, tenant, "/tmp/ns/dst/file", nil)
+
+	// A directory cannot be bound onto itself or anywhere below it
+	for _, dst := range []string{"/tmp/ns", "/tmp/ns/src"} {
+		if err := tenant.Bind("/tmp/ns", dst); !errors.Is(err, common.EINVAL) {
+			testutils.ErrorHere(test, "Expected EINVAL binding onto %s, got: %v", dst, err)
+		}
+	}
+	user := forkAs(test, tenant, 1, 1)
+	if err := user.Bind("/tmp/ns/src", "/tmp"); !errors.Is(err, common.EPERM) {
+		testutils.ErrorHere(test, "Expected EPERM, got: %v", err)
+	}
+
+	// A copy of the namespace keeps the bind when the original drops it
+	other := forkNamespace(test, tenant)
+	if err := tenant.Unmount("/tmp/ns/dst", 0); err != nil {
+		testutils.ErrorHere(test, "Failed when unbinding: %s", err)
+	}
+	expectStat(test, tenant, "/tmp/ns/dst/file", common.ENOENT)
+	expectStat(test, other, "/tmp/ns/dst/file", nil)
+
+	fs.Exit(other)
+	fs.Exit(user)
+	fs.Exit(tenant)
+	proc.Unlink("/tmp/ns/src/file")
+	proc.Rmdir("/tmp/ns/src")
+	proc.Rmdir("/tmp/ns/dst")
+	proc.Rmdir("/tmp/ns")
+	fs.Exit(proc)
+	if err := fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test that a bound directory reached from its own parent still behaves as
+// if it lives there, and only behaves as if it lives where it was bound when
+// reached through the bind.
+func TestBindSource(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+	for _, path := range []string{"/tmp/ns", "/tmp/ns/src", "/tmp/ns/src/sub", "/tmp/ns/x", "/tmp/ns/x/dst"} {
+		proc.Mkdir(path, 0755)
+	}
+	parent, _ := proc.Stat("/tmp/ns")
+	x, _ := proc.Stat("/tmp/ns/x")
+
+	tenant := forkNamespace(test, proc)
+	if err := tenant.Bind("/tmp/ns/src", "/tmp/ns/x/dst"); err != nil {
+		testutils.FatalHere(test, "Failed when binding: %s", err)
+	}
+
+	for path, inum := range map[string]int{"/tmp/ns/src/..": parent.Inum, "/tmp/ns/x/dst/..": x.Inum} {
+		if stat, err := tenant.Stat(path); err != nil || stat.Inum != inum {
+			testutils.ErrorHere(test, "Stat(%s) expected inode %d, got %+v (%v)", path, inum, stat, err)
+		}
+	}
+	for _, path := range []string{"/tmp/ns/src", "/tmp/ns/x/dst", "/tmp/ns/x/dst/sub"} {
+		tenant.Chdir(path)
+		expectCwd(test, tenant, path)
+	}
+
+	// An open directory remembers the way it was reached
+	dir, err := tenant.Open("/tmp/ns/x/dst", common.O_RDONLY, 0)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when opening directory: %s", err)
+	}
+	if stat, err := tenant.FstatAt(dir, "..", 0); err != nil || stat.Inum != x.Inum {
+		testutils.ErrorHere(test, "Expected parent inode %d, got %+v (%v)", x.Inum, stat, err)
+	}
+	tenant.Chdir("/")
+	tenant.Fchdir(dir)
+	expectCwd(test, tenant, "/tmp/ns/x/dst")
+	tenant.Close(dir)
+	tenant.Chdir("/")
+
+	// Only the bind can be unmounted, and only from where it was bound
+	if err = tenant.Unmount("/tmp/ns/src", 0); !errors.Is(err, common.EINVAL) {
+		testutils.ErrorHere(test, "Expected EINVAL, got: %v", err)
+	}
+
+	// The source can be reached from /tmp/ns/x through the bind, so binding
+	// that onto a directory below the source would make a cycle
+	if err = tenant.Bind("/tmp/ns/x", "/tmp/ns/src/sub"); !errors.Is(err, common.EINVAL) {
+		testutils.ErrorHere(test, "Expected EINVAL, got: %v", err)
+	}
+
+	if err = tenant.Unmount("/tmp/ns/x/dst", 0); err != nil {
+		testutils.ErrorHere(test, "Failed when unbinding: %s", err)
+	}
+	fs.Exit(tenant)
+	for _, path := range []string{"/tmp/ns/x/dst", "/tmp/ns/x", "/tmp/ns/src/sub", "/tmp/ns/src", "/tmp/ns"} {
+		if err = proc.Rmdir(path); err != nil {
+			testutils.ErrorHere(test, "Failed when removing %s: %s", path, err)
+		}
+	}
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
@@ -1,22 +1,18 @@
 package fs
 
 import (
+	"errors"
 	"github.com/jnwhiteh/minixfs/common"
 	"github.com/jnwhiteh/minixfs/testutils"
 	"runtime"
 	"testing"
 )
 
-func checkFileAndCount(proc *Process, file common.Fd) (bool, int) {
-	filp := file.(*filp)
-
+func checkFileAndCount(proc *Process, file int) (bool, int) {
 	// Verify open file count and presence of *File entry
-	found := false
+	found := proc.files[file] != nil
 	count := 0
 	for _, fi := range proc.files {
-		if fi == filp {
-			found = true
-		}
 		if fi != nil {
 			count++
 		}
@@ -72,9 +68,10 @@ func TestOpenClose(test *testing.T) {
 	//  * inode cache
 	//  * allocation table
 	//  * file server
+	//  * watch table
 
 	// This test is fragile, so be careful with it!
-	expected := numgoros - 5
+	expected := numgoros - 6
 	if runtime.NumGoroutine() != expected {
 		test.Logf("Original stack:\n%s\n", stacknow)
 		newstack := make([]byte, 4096)
@@ -83,3 +80,129 @@ func TestOpenClose(test *testing.T) {
 		testutils.FatalHere(test, "Goroutine count mismatch got %d, expected %d", expected, runtime.NumGoroutine())
 	}
 }
+
+// Test that opening a device node fails, since there is no driver behind it
+func TestOpenSpecial(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	// There is no mknod, so turn an empty file into a character device
+	createFile(test, proc, "/tmp/tty", "")
+	rip, err := fs.eatPath(proc, "/tmp/tty")
+	if err != nil {
+		testutils.FatalHere(test, "Failed when looking up file: %s", err)
+	}
+	rip.Mode = common.I_CHAR_SPECIAL | 0666
+	rip.Dirty = true
+	fs.itable.PutInode(rip)
+
+	if _, err = proc.Open("/tmp/tty", common.O_RDWR, 0); !errors.Is(err, common.ENXIO) {
+		testutils.ErrorHere(test, "Expected ENXIO, got: %v", err)
+	}
+	if _, err = proc.Open("/tmp/tty", common.O_CREAT|common.O_WRONLY, 0666); !errors.Is(err, common.ENXIO) {
+		testutils.ErrorHere(test, "Expected ENXIO, got: %v", err)
+	}
+	if err = proc.Unlink("/tmp/tty"); err != nil {
+		testutils.ErrorHere(test, "Failed when unlinking device: %s", err)
+	}
+
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test that duplicated descriptors share the open file and its position, and
+// that the file is only closed along with the last of them.
+func TestDup(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	file, err := proc.Open("/sample/europarl-en.txt", common.O_RDONLY, 0)
+	if err != nil {
+		testutils.FatalHere(test, "Failed opening file: %s", err)
+	}
+	dup, err := proc.Dup(file)
+	if err != nil || dup != file+1 {
+		testutils.FatalHere(test, "Dup returned %d (%v), expected %d", dup, err, file+1)
+	}
+	proc.Seek(file, 100, common.SEEK_SET)
+	if pos, _ := proc.Seek(dup, 0, common.SEEK_CUR); pos != 100 {
+		testutils.ErrorHere(test, "Position not shared, got %d", pos)
+	}
+
+	if fd, err := proc.Fcntl(file, common.F_DUPFD, 10); err != nil || fd != 10 {
+		testutils.ErrorHere(test, "F_DUPFD returned %d (%v), expected 10", fd, err)
+	}
+	if _, err = proc.Fcntl(file, common.F_DUPFD, common.OPEN_MAX); !errors.Is(err, common.EINVAL) {
+		testutils.ErrorHere(test, "Expected EINVAL, got: %v", err)
+	}
+
+	// Duplicating onto an open descriptor closes it first
+	other, _ := proc.Open("/tmp", common.O_RDONLY, 0)
+	if fd, err := proc.Dup2(file, other); err != nil || fd != other {
+		testutils.ErrorHere(test, "Dup2 returned %d (%v), expected %d", fd, err, other)
+	}
+	if stat, _ := proc.Fstat(other); stat.Inum != 542 {
+		testutils.ErrorHere(test, "Dup2 target refers to inode %d", stat.Inum)
+	}
+	if _, err = proc.Dup2(file, -1); !errors.Is(err, common.EBADF) {
+		testutils.ErrorHere(test, "Expected EBADF, got: %v", err)
+	}
+
+	proc.Close(file)
+	proc.Close(other)
+	proc.Close(10)
+	if n, err := proc.Read(dup, make([]byte, 10)); n != 10 || err != nil {
+		testutils.ErrorHere(test, "Failed reading remaining duplicate: %d (%v)", n, err)
+	}
+	proc.Close(dup)
+	if _, err = proc.Dup(dup); !errors.Is(err, common.EBADF) {
+		testutils.ErrorHere(test, "Expected EBADF, got: %v", err)
+	}
+
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test the per-process limit on open descriptors
+func TestOpenMax(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	user := forkAs(test, proc, 1, 1)
+	if err := user.SetOpenMax(2); err != nil {
+		testutils.ErrorHere(test, "Failed lowering limit: %s", err)
+	}
+	user.Open("/", common.O_RDONLY, 0)
+	user.Open("/", common.O_RDONLY, 0)
+	if _, err := user.Open("/", common.O_RDONLY, 0); !errors.Is(err, common.EMFILE) {
+		testutils.ErrorHere(test, "Expected EMFILE, got: %v", err)
+	}
+	if err := user.SetOpenMax(common.OPEN_MAX + 1); !errors.Is(err, common.EPERM) {
+		testutils.ErrorHere(test, "Expected EPERM, got: %v", err)
+	}
+
+	// The super user can go beyond the default, and the limit is inherited
+	if err := proc.SetOpenMax(2 * common.OPEN_MAX); err != nil {
+		testutils.ErrorHere(test, "Failed raising limit: %s", err)
+	}
+	child, _ := proc.Fork()
+	for i := 0; i < 2*common.OPEN_MAX; i++ {
+		if _, err := child.Open("/", common.O_RDONLY, 0); err != nil {
+			testutils.FatalHere(test, "Failed opening descriptor %d: %s", i, err)
+		}
+	}
+	if _, err := child.Open("/", common.O_RDONLY, 0); !errors.Is(err, common.EMFILE) {
+		testutils.ErrorHere(test, "Expected EMFILE, got: %v", err)
+	}
+	if fd, err := child.Dup2(0, 3*common.OPEN_MAX); !errors.Is(err, common.EBADF) {
+		testutils.ErrorHere(test, "Expected EBADF, got %d (%v)", fd, err)
+	}
+
+	fs.Exit(child)
+	fs.Exit(user)
+	fs.Exit(proc)
+	if err := fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
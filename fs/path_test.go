@@ -1,7 +1,10 @@
 package fs
 
 import (
+	"errors"
+	"github.com/jnwhiteh/minixfs/common"
 	"github.com/jnwhiteh/minixfs/testutils"
+	"strings"
 	"testing"
 )
 
@@ -81,3 +84,63 @@ func TestEatPath(test *testing.T) {
 		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
 	}
 }
+
+// Test that repeated and trailing separators are handled, and that overly
+// long names are rejected rather than truncated.
+func TestPathNormalize(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	var lookups = []struct {
+		path string
+		inum int
+		err  error
+	}{
+		{"//sample///europarl-en.txt", 542, nil},
+		{"/sample/", 541, nil},
+		{"sample//", 541, nil},
+		{"///", 1, nil},
+		{"/sample/europarl-en.txt/", 0, common.ENOTDIR},
+		{"/sample/" + strings.Repeat("x", common.NAME_MAX+1), 0, common.ENAMETOOLONG},
+	}
+	for _, l := range lookups {
+		stat, err := proc.Stat(l.path)
+		if l.err != nil {
+			if !errors.Is(err, l.err) {
+				testutils.ErrorHere(test, "Stat(%s) expected %v, got: %v", l.path, l.err, err)
+			}
+		} else if err != nil || stat.Inum != l.inum {
+			testutils.ErrorHere(test, "Stat(%s) expected inode %d, got %+v (%v)", l.path, l.inum, stat, err)
+		}
+	}
+
+	// A name of exactly NAME_MAX bytes is stored in full
+	longname := strings.Repeat("n", common.NAME_MAX)
+	if err := proc.Mkdir("/tmp/"+longname+"/", 0755); err != nil {
+		testutils.ErrorHere(test, "Failed when creating directory: %s", err)
+	}
+	entries, _ := proc.ReadDir("/tmp")
+	if findEntry(entries, longname) == nil {
+		testutils.ErrorHere(test, "Long name was not stored in full")
+	}
+	if err := proc.Rmdir("/tmp/" + longname + "/"); err != nil {
+		testutils.ErrorHere(test, "Failed when removing directory: %s", err)
+	}
+	if err := proc.Mkdir("/tmp/"+longname+"x", 0755); !errors.Is(err, common.ENAMETOOLONG) {
+		testutils.ErrorHere(test, "Expected ENAMETOOLONG, got: %v", err)
+	}
+
+	// Only directories can be named with a trailing slash
+	if _, err := proc.Open("/tmp/file/", common.O_CREAT|common.O_RDWR, 0666); !errors.Is(err, common.EISDIR) {
+		testutils.ErrorHere(test, "Expected EISDIR creating file with trailing slash, got: %v", err)
+	}
+	createFile(test, proc, "/tmp/file", "")
+	if err := proc.Unlink("/tmp/file/"); !errors.Is(err, common.ENOTDIR) {
+		testutils.ErrorHere(test, "Expected ENOTDIR unlinking file with trailing slash, got: %v", err)
+	}
+	proc.Unlink("/tmp/file")
+
+	fs.Exit(proc)
+	if err := fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
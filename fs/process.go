@@ -7,26 +7,39 @@ import (
 type Process struct {
 	pid     int           // the numeric id of this process
 	umask   uint16        // file creation mask
+	realuid int           // the real user id of the process
+	effuid  int           // the effective user id of the process
+	realgid int           // the real group id of the process
+	effgid  int           // the effective group id of the process
+	groups  []int         // supplementary group ids of the process
 	rootdir *common.Inode // root directory of the process
 	workdir *common.Inode // working directory of the process
+	workvia trail         // the mounts crossed to reach the working directory
+	ns      *namespace    // the mount table seen by the process
 	files   []*filp       // list of file descriptors
+	openmax int           // the number of descriptors the process may have open
 	fs      *FileSystem   // the file system for this process
 }
 
-func (proc *Process) Mount(dev common.BlockDevice, path string) error {
-	proc.fs.in <- req_FS_Mount{proc, dev, path}
+func (proc *Process) Mount(dev common.BlockDevice, path string, flags int) error {
+	proc.fs.in <- req_FS_Mount{proc, dev, path, flags}
 	result := (<-proc.fs.out).(res_FS_Mount)
 	return result.Arg0
 }
-func (proc *Process) Unmount(path string) error {
-	proc.fs.in <- req_FS_Unmount{proc, path}
+func (proc *Process) Unmount(path string, flags int) error {
+	proc.fs.in <- req_FS_Unmount{proc, path, flags}
 	result := (<-proc.fs.out).(res_FS_Unmount)
 	return result.Arg0
 }
-func (proc *Process) Sync() {
+func (proc *Process) Bind(src string, dst string) error {
+	proc.fs.in <- req_FS_Bind{proc, src, dst}
+	result := (<-proc.fs.out).(res_FS_Bind)
+	return result.Arg0
+}
+func (proc *Process) Sync() error {
 	proc.fs.in <- req_FS_Sync{}
-	<-proc.fs.out
-	return
+	result := (<-proc.fs.out).(res_FS_Sync)
+	return result.Arg0
 }
 func (proc *Process) Shutdown() {
 	proc.fs.in <- req_FS_Shutdown{}
@@ -38,36 +51,106 @@ func (proc *Process) Fork() (*Process, error) {
 	result := (<-proc.fs.out).(res_FS_Fork)
 	return result.Arg0, result.Arg1
 }
+func (proc *Process) ForkNamespace() (*Process, error) {
+	proc.fs.in <- req_FS_ForkNamespace{proc}
+	result := (<-proc.fs.out).(res_FS_ForkNamespace)
+	return result.Arg0, result.Arg1
+}
 func (proc *Process) Exit() {
 	proc.fs.in <- req_FS_Exit{proc}
 	<-proc.fs.out
 	return
 }
-func (proc *Process) Open(path string, flags int, mode uint16) (common.Fd, error) {
+func (proc *Process) Open(path string, flags int, mode uint16) (int, error) {
 	proc.fs.in <- req_FS_OpenCreat{proc, path, flags, mode}
 	result := (<-proc.fs.out).(res_FS_OpenCreat)
 	return result.Arg0, result.Arg1
 }
-func (proc *Process) Creat(path string, flags int, mode uint16) (common.Fd, error) {
+func (proc *Process) Creat(path string, flags int, mode uint16) (int, error) {
 	proc.fs.in <- req_FS_OpenCreat{proc, path, flags | common.O_CREAT, mode}
 	result := (<-proc.fs.out).(res_FS_OpenCreat)
 	return result.Arg0, result.Arg1
 }
-func (proc *Process) Close(fd common.Fd) error {
+func (proc *Process) Close(fd int) error {
 	proc.fs.in <- req_FS_Close{proc, fd}
 	result := (<-proc.fs.out).(res_FS_Close)
 	return result.Arg0
 }
+func (proc *Process) OpenAt(dirfd int, path string, flags int, mode uint16) (int, error) {
+	proc.fs.in <- req_FS_OpenAt{proc, dirfd, path, flags, mode}
+	result := (<-proc.fs.out).(res_FS_OpenAt)
+	return result.Arg0, result.Arg1
+}
+func (proc *Process) Dup(fd int) (int, error) {
+	proc.fs.in <- req_FS_Dup{proc, fd}
+	result := (<-proc.fs.out).(res_FS_Dup)
+	return result.Arg0, result.Arg1
+}
+func (proc *Process) Dup2(oldfd int, newfd int) (int, error) {
+	proc.fs.in <- req_FS_Dup2{proc, oldfd, newfd}
+	result := (<-proc.fs.out).(res_FS_Dup2)
+	return result.Arg0, result.Arg1
+}
+func (proc *Process) Fcntl(fd int, cmd int, arg int) (int, error) {
+	proc.fs.in <- req_FS_Fcntl{proc, fd, cmd, arg}
+	result := (<-proc.fs.out).(res_FS_Fcntl)
+	return result.Arg0, result.Arg1
+}
 func (proc *Process) Stat(path string) (*common.StatInfo, error) {
 	proc.fs.in <- req_FS_Stat{proc, path}
 	result := (<-proc.fs.out).(res_FS_Stat)
 	return result.Arg0, result.Arg1
 }
+func (proc *Process) FstatAt(dirfd int, path string, flags int) (*common.StatInfo, error) {
+	proc.fs.in <- req_FS_FstatAt{proc, dirfd, path, flags}
+	result := (<-proc.fs.out).(res_FS_FstatAt)
+	return result.Arg0, result.Arg1
+}
+func (proc *Process) Statfs(path string) (*common.StatfsInfo, error) {
+	proc.fs.in <- req_FS_Statfs{proc, path}
+	result := (<-proc.fs.out).(res_FS_Statfs)
+	return result.Arg0, result.Arg1
+}
+func (proc *Process) Watch(path string, mask int) (<-chan common.Event, func(), error) {
+	proc.fs.in <- req_FS_Watch{proc, path, mask}
+	result := (<-proc.fs.out).(res_FS_Watch)
+	return result.Arg0, result.Arg1, result.Arg2
+}
+func (proc *Process) ReadDir(path string) ([]common.Dirent, error) {
+	proc.fs.in <- req_FS_ReadDir{proc, path}
+	result := (<-proc.fs.out).(res_FS_ReadDir)
+	return result.Arg0, result.Arg1
+}
+func (proc *Process) Lstat(path string) (*common.StatInfo, error) {
+	proc.fs.in <- req_FS_Lstat{proc, path}
+	result := (<-proc.fs.out).(res_FS_Lstat)
+	return result.Arg0, result.Arg1
+}
 func (proc *Process) Chmod(path string, mode uint16) error {
 	proc.fs.in <- req_FS_Chmod{proc, path, mode}
 	result := (<-proc.fs.out).(res_FS_Chmod)
 	return result.Arg0
 }
+func (proc *Process) Chown(path string, uid, gid int) error {
+	proc.fs.in <- req_FS_Chown{proc, path, uid, gid}
+	result := (<-proc.fs.out).(res_FS_Chown)
+	return result.Arg0
+}
+func (proc *Process) Fchmod(fd int, mode uint16) error {
+	proc.fs.in <- req_FS_Fchmod{proc, fd, mode}
+	result := (<-proc.fs.out).(res_FS_Fchmod)
+	return result.Arg0
+}
+func (proc *Process) Fchown(fd int, uid, gid int) error {
+	proc.fs.in <- req_FS_Fchown{proc, fd, uid, gid}
+	result := (<-proc.fs.out).(res_FS_Fchown)
+	return result.Arg0
+}
+func (proc *Process) Utime(path string, atime, mtime int32) error {
+	proc.fs.in <- req_FS_Utime{proc, path, atime, mtime}
+	result := (<-proc.fs.out).(res_FS_Utime)
+	return result.Arg0
+}
 func (proc *Process) Link(oldpath, newpath string) error {
 	proc.fs.in <- req_FS_Link{proc, oldpath, newpath}
 	result := (<-proc.fs.out).(res_FS_Link)
@@ -78,11 +161,46 @@ func (proc *Process) Unlink(path string) error {
 	result := (<-proc.fs.out).(res_FS_Unlink)
 	return result.Arg0
 }
+func (proc *Process) UnlinkAt(dirfd int, path string, flags int) error {
+	proc.fs.in <- req_FS_UnlinkAt{proc, dirfd, path, flags}
+	result := (<-proc.fs.out).(res_FS_UnlinkAt)
+	return result.Arg0
+}
+func (proc *Process) Rename(oldpath, newpath string) error {
+	proc.fs.in <- req_FS_Rename{proc, oldpath, newpath}
+	result := (<-proc.fs.out).(res_FS_Rename)
+	return result.Arg0
+}
+func (proc *Process) RenameAt(olddirfd int, oldpath string, newdirfd int, newpath string) error {
+	proc.fs.in <- req_FS_RenameAt{proc, olddirfd, oldpath, newdirfd, newpath}
+	result := (<-proc.fs.out).(res_FS_RenameAt)
+	return result.Arg0
+}
+func (proc *Process) Symlink(target, path string) error {
+	proc.fs.in <- req_FS_Symlink{proc, target, path}
+	result := (<-proc.fs.out).(res_FS_Symlink)
+	return result.Arg0
+}
+func (proc *Process) Readlink(path string) (string, error) {
+	proc.fs.in <- req_FS_Readlink{proc, path}
+	result := (<-proc.fs.out).(res_FS_Readlink)
+	return result.Arg0, result.Arg1
+}
+func (proc *Process) ReadlinkAt(dirfd int, path string) (string, error) {
+	proc.fs.in <- req_FS_ReadlinkAt{proc, dirfd, path}
+	result := (<-proc.fs.out).(res_FS_ReadlinkAt)
+	return result.Arg0, result.Arg1
+}
 func (proc *Process) Mkdir(path string, mode uint16) error {
 	proc.fs.in <- req_FS_Mkdir{proc, path, mode}
 	result := (<-proc.fs.out).(res_FS_Mkdir)
 	return result.Arg0
 }
+func (proc *Process) MkdirAt(dirfd int, path string, mode uint16) error {
+	proc.fs.in <- req_FS_MkdirAt{proc, dirfd, path, mode}
+	result := (<-proc.fs.out).(res_FS_MkdirAt)
+	return result.Arg0
+}
 func (proc *Process) Rmdir(path string) error {
 	proc.fs.in <- req_FS_Rmdir{proc, path}
 	result := (<-proc.fs.out).(res_FS_Rmdir)
@@ -93,3 +211,59 @@ func (proc *Process) Chdir(path string) error {
 	result := (<-proc.fs.out).(res_FS_Chdir)
 	return result.Arg0
 }
+func (proc *Process) Chroot(path string) error {
+	proc.fs.in <- req_FS_Chroot{proc, path}
+	result := (<-proc.fs.out).(res_FS_Chroot)
+	return result.Arg0
+}
+func (proc *Process) Fchdir(fd int) error {
+	proc.fs.in <- req_FS_Fchdir{proc, fd}
+	result := (<-proc.fs.out).(res_FS_Fchdir)
+	return result.Arg0
+}
+func (proc *Process) Getcwd() (string, error) {
+	proc.fs.in <- req_FS_Getcwd{proc}
+	result := (<-proc.fs.out).(res_FS_Getcwd)
+	return result.Arg0, result.Arg1
+}
+func (proc *Process) Setuid(uid int) error {
+	proc.fs.in <- req_FS_Setuid{proc, uid}
+	result := (<-proc.fs.out).(res_FS_Setuid)
+	return result.Arg0
+}
+func (proc *Process) Setgid(gid int) error {
+	proc.fs.in <- req_FS_Setgid{proc, gid}
+	result := (<-proc.fs.out).(res_FS_Setgid)
+	return result.Arg0
+}
+func (proc *Process) Setgroups(groups []int) error {
+	proc.fs.in <- req_FS_Setgroups{proc, groups}
+	result := (<-proc.fs.out).(res_FS_Setgroups)
+	return result.Arg0
+}
+func (proc *Process) SetOpenMax(max int) error {
+	proc.fs.in <- req_FS_SetOpenMax{proc, max}
+	result := (<-proc.fs.out).(res_FS_SetOpenMax)
+	return result.Arg0
+}
+
+// The credentials of a process are only changed by the process itself, so
+// they can be read without going through the file server.
+func (proc *Process) Getuid() int {
+	return proc.realuid
+}
+func (proc *Process) Geteuid() int {
+	return proc.effuid
+}
+func (proc *Process) Getgid() int {
+	return proc.realgid
+}
+func (proc *Process) Getegid() int {
+	return proc.effgid
+}
+func (proc *Process) Getgroups() []int {
+	return append([]int(nil), proc.groups...)
+}
+func (proc *Process) OpenMax() int {
+	return proc.openmax
+}
@@ -0,0 +1,241 @@
+package fs
+
+import (
+	"github.com/jnwhiteh/minixfs/common"
+)
+
+// Check whether the process may access the inode in the way described by
+// 'access', which is made up of R_BIT, W_BIT and X_BIT. Returns EACCES if the
+// access is not permitted, or EROFS if write access is asked for on a
+// read-only file system.
+func forbidden(proc *Process, rip *common.Inode, access uint16) error {
+	bits := rip.Mode
+	var perm uint16
+
+	if proc.effuid == common.SU_UID {
+		// Grant read and write permission. Grant search permission for
+		// directories. Grant execute permission (for non-directories) if
+		// and only if one of the 'X' bits is set.
+		xbits := uint16((common.X_BIT << 6) | (common.X_BIT << 3) | common.X_BIT)
+		if rip.IsDirectory() || bits&xbits != 0 {
+			perm = common.R_BIT | common.W_BIT | common.X_BIT
+		} else {
+			perm = common.R_BIT | common.W_BIT
+		}
+	} else {
+		var shift uint
+		if proc.effuid == int(rip.Uid) {
+			shift = 6 // owner
+		} else if proc.inGroup(int(rip.Gid)) {
+			shift = 3 // group
+		} else {
+			shift = 0 // other
+		}
+		perm = (bits >> shift) & (common.R_BIT | common.W_BIT | common.X_BIT)
+	}
+
+	if perm|access != perm {
+		return common.EACCES
+	}
+	if access&common.W_BIT != 0 {
+		return readOnly(rip)
+	}
+	return nil
+}
+
+// Returns EROFS if the inode is on a device that was mounted read-only
+func readOnly(rip *common.Inode) error {
+	if rip.Devinfo.ReadOnly() {
+		return common.EROFS
+	}
+	return nil
+}
+
+// In a directory with the sticky bit set, an entry may only be removed or
+// renamed by the owner of the entry, the owner of the directory or the super
+// user.
+func sticky(proc *Process, dirp, rip *common.Inode) error {
+	if dirp.Mode&common.I_SET_STCKY_BIT == 0 || proc.effuid == common.SU_UID {
+		return nil
+	}
+	if proc.effuid != int(rip.Uid) && proc.effuid != int(dirp.Uid) {
+		return common.EPERM
+	}
+	return nil
+}
+
+// Returns true if 'gid' is the effective group id of the process, or one of
+// its supplementary groups.
+func (proc *Process) inGroup(gid int) bool {
+	if proc.effgid == gid {
+		return true
+	}
+	for _, group := range proc.groups {
+		if group == gid {
+			return true
+		}
+	}
+	return false
+}
+
+func (fs *FileSystem) do_setuid(proc *Process, uid int) error {
+	if proc.effuid == common.SU_UID {
+		proc.realuid = uid
+	} else if uid != proc.realuid {
+		return common.EPERM
+	}
+	proc.effuid = uid
+	return nil
+}
+
+func (fs *FileSystem) do_setgid(proc *Process, gid int) error {
+	if proc.effuid == common.SU_UID {
+		proc.realgid = gid
+	} else if gid != proc.realgid {
+		return common.EPERM
+	}
+	proc.effgid = gid
+	return nil
+}
+
+func (fs *FileSystem) do_setgroups(proc *Process, groups []int) error {
+	if proc.effuid != common.SU_UID {
+		return common.EPERM
+	}
+	proc.groups = append([]int(nil), groups...)
+	return nil
+}
+
+func (fs *FileSystem) do_chmod(proc *Process, path string, mode uint16) error {
+	rip, err := fs.eatPath(proc, path)
+	if err != nil {
+		return err
+	}
+
+	err = fs.chmod(proc, rip, mode)
+	fs.itable.PutInode(rip)
+	return err
+}
+
+func (fs *FileSystem) do_fchmod(proc *Process, fd int, mode uint16) error {
+	filp, err := proc.getFilp(fd)
+	if err != nil {
+		return err
+	}
+	return fs.chmod(proc, filp.inode, mode)
+}
+
+// Only the owner of a file or the super user may change its mode. Anyone
+// else that does not belong to the group of the file cannot set its setgid
+// bit.
+func (fs *FileSystem) chmod(proc *Process, rip *common.Inode, mode uint16) error {
+	return setattr(rip, func(rip *common.Inode) error {
+		su := proc.effuid == common.SU_UID
+		if !su && proc.effuid != int(rip.Uid) {
+			return common.EPERM
+		}
+		if err := readOnly(rip); err != nil {
+			return err
+		}
+		if !su && !proc.inGroup(int(rip.Gid)) {
+			mode &^= common.I_SET_GID_BIT
+		}
+
+		rip.Mode = (rip.Mode &^ common.ALL_MODES) | (mode & common.ALL_MODES)
+		rip.Update |= common.CTIME
+		rip.Dirty = true
+		rip.Notify(common.IN_ATTRIB, "")
+		return nil
+	})
+}
+
+func (fs *FileSystem) do_chown(proc *Process, path string, uid, gid int) error {
+	rip, err := fs.eatPath(proc, path)
+	if err != nil {
+		return err
+	}
+
+	err = fs.chown(proc, rip, uid, gid)
+	fs.itable.PutInode(rip)
+	return err
+}
+
+func (fs *FileSystem) do_fchown(proc *Process, fd int, uid, gid int) error {
+	filp, err := proc.getFilp(fd)
+	if err != nil {
+		return err
+	}
+	return fs.chown(proc, filp.inode, uid, gid)
+}
+
+// The super user can change the owner and group of any file. Anyone else may
+// only change the group of a file they own to one of their own groups, and
+// doing so clears the setuid and setgid bits. An id of -1 leaves that part of
+// the ownership unchanged.
+func (fs *FileSystem) chown(proc *Process, rip *common.Inode, uid, gid int) error {
+	return setattr(rip, func(rip *common.Inode) error {
+		if uid == -1 {
+			uid = int(rip.Uid)
+		}
+		if gid == -1 {
+			gid = int(rip.Gid)
+		}
+
+		if proc.effuid != common.SU_UID {
+			if proc.effuid != int(rip.Uid) || uid != int(rip.Uid) {
+				return common.EPERM // no giving away
+			}
+			if gid != int(rip.Gid) && !proc.inGroup(gid) {
+				return common.EPERM
+			}
+		}
+		if err := readOnly(rip); err != nil {
+			return err
+		}
+		if proc.effuid != common.SU_UID {
+			rip.Mode &^= common.I_SET_UID_BIT | common.I_SET_GID_BIT
+		}
+
+		rip.Uid = int16(uid)
+		rip.Gid = uint16(gid)
+		rip.Update |= common.CTIME
+		rip.Dirty = true
+		rip.Notify(common.IN_ATTRIB, "")
+		return nil
+	})
+}
+
+// Only the owner of a file or the super user can change its times
+func (fs *FileSystem) do_utime(proc *Process, path string, atime, mtime int32) error {
+	rip, err := fs.eatPath(proc, path)
+	if err != nil {
+		return err
+	}
+
+	err = setattr(rip, func(rip *common.Inode) error {
+		if proc.effuid != common.SU_UID && proc.effuid != int(rip.Uid) {
+			return common.EPERM
+		}
+		if err := readOnly(rip); err != nil {
+			return err
+		}
+		rip.Atime = atime
+		rip.Mtime = mtime
+		rip.Update = common.CTIME // don't let pending updates clobber these
+		rip.Dirty = true
+		rip.Notify(common.IN_ATTRIB, "")
+		return nil
+	})
+
+	fs.itable.PutInode(rip)
+	return err
+}
+
+// Apply 'change' to the attributes of an inode. While the inode is open its
+// file server may be reading and stamping it, so the change is made there.
+func setattr(rip *common.Inode, change func(rip *common.Inode) error) error {
+	if rip.File != nil {
+		return rip.File.Change(change)
+	}
+	return change(rip)
+}
@@ -0,0 +1,329 @@
+package fs
+
+import (
+	"errors"
+	"github.com/jnwhiteh/minixfs/common"
+	"github.com/jnwhiteh/minixfs/testutils"
+	"testing"
+)
+
+// Change the owner and mode of an inode directly, so that permissions can be
+// tested independently of the calls that change them.
+func setAttr(test *testing.T, fs *FileSystem, proc *Process, path string, uid, gid int, mode uint16) {
+	rip, err := fs.eatPath(proc, path)
+	if err != nil {
+		testutils.FatalLevel(test, 2, "Failed when looking up %s: %s", path, err)
+	}
+	rip.Uid = int16(uid)
+	rip.Gid = uint16(gid)
+	rip.Mode = (rip.Mode & common.I_TYPE) | mode
+	rip.Dirty = true
+	fs.itable.PutInode(rip)
+}
+
+// Fork a new process from 'proc' running as the given user and group
+func forkAs(test *testing.T, proc *Process, uid, gid int) *Process {
+	child, err := proc.Fork()
+	if err != nil {
+		testutils.FatalLevel(test, 2, "Failed when forking: %s", err)
+	}
+	if err = child.Setgid(gid); err != nil {
+		testutils.FatalLevel(test, 2, "Failed when calling setgid: %s", err)
+	}
+	if err = child.Setuid(uid); err != nil {
+		testutils.FatalLevel(test, 2, "Failed when calling setuid: %s", err)
+	}
+	return child
+}
+
+// Test changing the credentials of a process
+func TestCredentials(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	if proc.Getuid() != common.SU_UID || proc.Geteuid() != common.SU_UID {
+		testutils.ErrorHere(test, "Root process is not the super user")
+	}
+
+	user := forkAs(test, proc, 100, 101)
+	if user.Getuid() != 100 || user.Geteuid() != 100 || user.Getgid() != 101 || user.Getegid() != 101 {
+		testutils.ErrorHere(test, "Credentials mismatch: %d %d %d %d", user.Getuid(), user.Geteuid(), user.Getgid(), user.Getegid())
+	}
+	if err := user.Setuid(common.SU_UID); !errors.Is(err, common.EPERM) {
+		testutils.ErrorHere(test, "Expected EPERM regaining super user, got: %v", err)
+	}
+	if err := user.Setgid(0); !errors.Is(err, common.EPERM) {
+		testutils.ErrorHere(test, "Expected EPERM changing group, got: %v", err)
+	}
+	if err := user.Setgroups([]int{0}); !errors.Is(err, common.EPERM) {
+		testutils.ErrorHere(test, "Expected EPERM setting groups, got: %v", err)
+	}
+	if err := user.Setuid(100); err != nil {
+		testutils.ErrorHere(test, "Failed when setting uid to real uid: %s", err)
+	}
+
+	// Credentials are inherited across fork
+	child, _ := user.Fork()
+	if child.Geteuid() != 100 || child.Getegid() != 101 {
+		testutils.ErrorHere(test, "Credentials not inherited: %d %d", child.Geteuid(), child.Getegid())
+	}
+	if err := child.Mount(nil, "/mnt", 0); !errors.Is(err, common.EPERM) {
+		testutils.ErrorHere(test, "Expected EPERM mounting as user, got: %v", err)
+	}
+
+	fs.Exit(child)
+	fs.Exit(user)
+	fs.Exit(proc)
+	if err := fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test that permissions are enforced on path components and targets
+func TestPermissions(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	if err := proc.Mkdir("/tmp/perm", 0777); err != nil {
+		testutils.FatalHere(test, "Failed when creating directory: %s", err)
+	}
+	createFile(test, proc, "/tmp/perm/secret", "secret")
+	setAttr(test, fs, proc, "/tmp/perm", 100, 100, 0755)
+	setAttr(test, fs, proc, "/tmp/perm/secret", 0, 0, 0600)
+
+	owner := forkAs(test, proc, 100, 100)
+	other := forkAs(test, proc, 200, 200)
+
+	// New files belong to the creator, with the umask applied
+	createFile(test, owner, "/tmp/perm/mine", "")
+	stat, err := owner.Stat("/tmp/perm/mine")
+	if err != nil || stat.Uid != 100 || stat.Gid != 100 || stat.Mode&common.ALL_MODES != 0644 {
+		testutils.ErrorHere(test, "New file attributes mismatch: %v %v", stat, err)
+	}
+	if err = owner.Mkdir("/tmp/perm/private", 0700); err != nil {
+		testutils.FatalHere(test, "Failed when creating directory: %s", err)
+	}
+
+	if _, err = owner.Open("/tmp/perm/secret", common.O_RDONLY, 0); !errors.Is(err, common.EACCES) {
+		testutils.ErrorHere(test, "Expected EACCES reading file, got: %v", err)
+	}
+	if file, err := proc.Open("/tmp/perm/secret", common.O_RDWR, 0); err != nil {
+		testutils.ErrorHere(test, "Super user could not open file: %s", err)
+	} else {
+		proc.Close(file)
+	}
+
+	// Without write permission on the directory, entries cannot be changed
+	if _, err = other.Open("/tmp/perm/new", common.O_CREAT|common.O_RDWR, 0666); !errors.Is(err, common.EACCES) {
+		testutils.ErrorHere(test, "Expected EACCES creating file, got: %v", err)
+	}
+	if err = other.Unlink("/tmp/perm/mine"); !errors.Is(err, common.EACCES) {
+		testutils.ErrorHere(test, "Expected EACCES unlinking file, got: %v", err)
+	}
+	if err = other.Rename("/tmp/perm/mine", "/tmp/perm/yours"); !errors.Is(err, common.EACCES) {
+		testutils.ErrorHere(test, "Expected EACCES renaming file, got: %v", err)
+	}
+	if _, err = other.Open("/tmp/perm/mine", common.O_WRONLY, 0); !errors.Is(err, common.EACCES) {
+		testutils.ErrorHere(test, "Expected EACCES writing file, got: %v", err)
+	}
+
+	// Without search permission, nothing below the directory can be reached
+	if _, err = other.Stat("/tmp/perm/private/file"); !errors.Is(err, common.EACCES) {
+		testutils.ErrorHere(test, "Expected EACCES searching directory, got: %v", err)
+	}
+	if err = other.Chdir("/tmp/perm/private"); !errors.Is(err, common.EACCES) {
+		testutils.ErrorHere(test, "Expected EACCES changing directory, got: %v", err)
+	}
+	if _, err = other.ReadDir("/tmp/perm/private"); !errors.Is(err, common.EACCES) {
+		testutils.ErrorHere(test, "Expected EACCES reading directory, got: %v", err)
+	}
+
+	// Supplementary groups grant group permissions
+	setAttr(test, fs, proc, "/tmp/perm", 100, 100, 0775)
+	member, _ := proc.Fork()
+	member.Setgroups([]int{100})
+	member.Setgid(300)
+	member.Setuid(300)
+	createFile(test, member, "/tmp/perm/member", "")
+
+	// In a sticky directory, only owners may remove entries
+	setAttr(test, fs, proc, "/tmp/perm", 100, 100, 01777)
+	createFile(test, other, "/tmp/perm/other", "")
+	if err = other.Unlink("/tmp/perm/mine"); !errors.Is(err, common.EPERM) {
+		testutils.ErrorHere(test, "Expected EPERM in sticky directory, got: %v", err)
+	}
+	if err = other.Unlink("/tmp/perm/other"); err != nil {
+		testutils.ErrorHere(test, "Failed when removing own file: %s", err)
+	}
+	if err = owner.Unlink("/tmp/perm/member"); err != nil {
+		testutils.ErrorHere(test, "Directory owner could not remove file: %s", err)
+	}
+
+	// Only the super user may unlink a directory, and a failed unlink leaves
+	// its link count alone
+	if err = owner.Unlink("/tmp/perm/private"); !errors.Is(err, common.EPERM) {
+		testutils.ErrorHere(test, "Expected EPERM unlinking directory, got: %v", err)
+	}
+	if stat, err = proc.Stat("/tmp/perm/private"); err != nil || stat.Nlinks != 2 {
+		testutils.ErrorHere(test, "Directory links mismatch expected 2, got %v (%v)", stat, err)
+	}
+
+	for _, path := range []string{"/tmp/perm/mine", "/tmp/perm/secret"} {
+		if err = proc.Unlink(path); err != nil {
+			testutils.ErrorHere(test, "Failed when unlinking %s: %s", path, err)
+		}
+	}
+	for _, path := range []string{"/tmp/perm/private", "/tmp/perm"} {
+		if err = proc.Rmdir(path); err != nil {
+			testutils.ErrorHere(test, "Failed when removing %s: %s", path, err)
+		}
+	}
+
+	fs.Exit(member)
+	fs.Exit(other)
+	fs.Exit(owner)
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test changing the mode, ownership and times of a file
+func TestChmodChown(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	path := "/tmp/attr"
+	createFile(test, proc, path, "")
+	user := forkAs(test, proc, 100, 100)
+	other := forkAs(test, proc, 200, 200)
+
+	// The super user can do anything
+	if err := proc.Chown(path, 100, 100); err != nil {
+		testutils.ErrorHere(test, "Failed when calling chown: %s", err)
+	}
+	if err := proc.Chmod(path, 04755); err != nil {
+		testutils.ErrorHere(test, "Failed when calling chmod: %s", err)
+	}
+	stat, _ := proc.Stat(path)
+	if stat.Uid != 100 || stat.Gid != 100 || stat.Mode != common.I_REGULAR|04755 {
+		testutils.ErrorHere(test, "Attributes mismatch: uid %d gid %d mode %o", stat.Uid, stat.Gid, stat.Mode)
+	}
+
+	// Other users can't change anything
+	if err := other.Chmod(path, 0777); !errors.Is(err, common.EPERM) {
+		testutils.ErrorHere(test, "Expected EPERM from chmod, got: %v", err)
+	}
+	if err := other.Chown(path, 200, 200); !errors.Is(err, common.EPERM) {
+		testutils.ErrorHere(test, "Expected EPERM from chown, got: %v", err)
+	}
+	if err := other.Utime(path, 1, 1); !errors.Is(err, common.EPERM) {
+		testutils.ErrorHere(test, "Expected EPERM from utime, got: %v", err)
+	}
+
+	// The owner can't give the file away or move it to a foreign group, and
+	// changing the group clears the setuid bit.
+	if err := user.Chown(path, 200, -1); !errors.Is(err, common.EPERM) {
+		testutils.ErrorHere(test, "Expected EPERM giving file away, got: %v", err)
+	}
+	if err := user.Chown(path, -1, 200); !errors.Is(err, common.EPERM) {
+		testutils.ErrorHere(test, "Expected EPERM changing to foreign group, got: %v", err)
+	}
+	if err := user.Chown(path, -1, 100); err != nil {
+		testutils.ErrorHere(test, "Failed when calling chown as owner: %s", err)
+	}
+	if stat, _ = proc.Stat(path); stat.Mode != common.I_REGULAR|0755 {
+		testutils.ErrorHere(test, "Setuid bit not cleared by chown, mode %o", stat.Mode)
+	}
+
+	// The setgid bit can only be set by members of the group
+	user.Chmod(path, 02755)
+	if stat, _ = proc.Stat(path); stat.Mode != common.I_REGULAR|02755 {
+		testutils.ErrorHere(test, "Setgid bit not set, mode %o", stat.Mode)
+	}
+	proc.Chown(path, -1, 300)
+	user.Chmod(path, 02755)
+	if stat, _ = proc.Stat(path); stat.Mode != common.I_REGULAR|0755 {
+		testutils.ErrorHere(test, "Setgid bit set by non-member, mode %o", stat.Mode)
+	}
+
+	// The same rules apply through an open file descriptor
+	file, err := user.Open(path, common.O_RDONLY, 0)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when opening file: %s", err)
+	}
+	if err = user.Fchmod(file, 0600); err != nil {
+		testutils.ErrorHere(test, "Failed when calling fchmod: %s", err)
+	}
+	if err = user.Fchown(file, -1, 100); err != nil {
+		testutils.ErrorHere(test, "Failed when calling fchown: %s", err)
+	}
+	if stat, _ = user.Fstat(file); stat.Mode != common.I_REGULAR|0600 || stat.Gid != 100 {
+		testutils.ErrorHere(test, "Attributes mismatch: gid %d mode %o", stat.Gid, stat.Mode)
+	}
+	if err = other.Fchmod(file, 0777); !errors.Is(err, common.EBADF) {
+		testutils.ErrorHere(test, "Expected EBADF from fchmod, got: %v", err)
+	}
+	user.Close(file)
+	if err = user.Fchmod(file, 0777); !errors.Is(err, common.EBADF) {
+		testutils.ErrorHere(test, "Expected EBADF from fchmod, got: %v", err)
+	}
+
+	// Times can be set by the owner, and the change time is updated
+	before, _ := proc.Stat(path)
+	if err = user.Utime(path, 1000, 2000); err != nil {
+		testutils.ErrorHere(test, "Failed when calling utime: %s", err)
+	}
+	stat, _ = proc.Stat(path)
+	if stat.Atime != 1000 || stat.Mtime != 2000 || stat.Ctime < before.Ctime || stat.Ctime == 0 {
+		testutils.ErrorHere(test, "Times mismatch: %d %d %d", stat.Atime, stat.Mtime, stat.Ctime)
+	}
+
+	if err = proc.Unlink(path); err != nil {
+		testutils.ErrorHere(test, "Failed when unlinking file: %s", err)
+	}
+
+	fs.Exit(other)
+	fs.Exit(user)
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test changing the attributes of a file while it is being read
+func TestFchmodWhileReading(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	fd, err := proc.Open("/sample/europarl-en.txt", common.O_RDONLY, 0)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when opening file: %s", err)
+	}
+	file, _ := proc.File(fd)
+	orig, _ := proc.Fstat(fd)
+
+	done := make(chan bool)
+	go func() {
+		buf := make([]byte, 64)
+		for i := 0; i < 100; i++ {
+			file.ReadAt(buf, int64(i*64))
+			file.Fstat()
+		}
+		done <- true
+	}()
+
+	for i := 0; i < 100; i++ {
+		if err = proc.Fchmod(fd, uint16(0600+i%2)); err != nil {
+			testutils.ErrorHere(test, "Failed when calling fchmod: %s", err)
+		}
+		if err = proc.Chown("/sample/europarl-en.txt", -1, i%2); err != nil {
+			testutils.ErrorHere(test, "Failed when calling chown: %s", err)
+		}
+	}
+	<-done
+
+	proc.Fchmod(fd, orig.Mode&common.ALL_MODES)
+	proc.Fchown(fd, -1, int(orig.Gid))
+	proc.Close(fd)
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
@@ -0,0 +1,186 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jnwhiteh/minixfs/common"
+	"github.com/jnwhiteh/minixfs/testutils"
+	"io"
+	"testing"
+)
+
+func findEntry(entries []common.Dirent, name string) *common.Dirent {
+	for i := range entries {
+		if entries[i].Name == name {
+			return &entries[i]
+		}
+	}
+	return nil
+}
+
+// Test reading the entries of a directory by path
+func TestReadDir(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	entries, err := proc.ReadDir("/")
+	if err != nil {
+		testutils.FatalHere(test, "Failed when reading directory: %s", err)
+	}
+
+	dirs := map[string]int{".": 1, "..": 1, "usr": 2, "mnt": 518, "sample": 541, "var": 543}
+	for name, inum := range dirs {
+		entry := findEntry(entries, name)
+		if entry == nil {
+			testutils.ErrorHere(test, "Missing entry %q", name)
+		} else if entry.Inum != inum || entry.Type != common.I_DIRECTORY {
+			testutils.ErrorHere(test, "Entry %q mismatch, inode %d type %o", name, entry.Inum, entry.Type)
+		}
+	}
+
+	entries, err = proc.ReadDir("/sample")
+	if err != nil || len(entries) != 3 {
+		testutils.FatalHere(test, "Failed when reading /sample: %d entries, %v", len(entries), err)
+	}
+	if entry := findEntry(entries, "europarl-en.txt"); entry == nil || entry.Inum != 542 || entry.Type != common.I_REGULAR {
+		testutils.ErrorHere(test, "Entry mismatch for europarl-en.txt: %v", entry)
+	}
+
+	if _, err = proc.ReadDir("/sample/europarl-en.txt"); !errors.Is(err, common.ENOTDIR) {
+		testutils.ErrorHere(test, "Expected ENOTDIR, got: %v", err)
+	}
+
+	// Removed entries leave empty slots that must be skipped
+	proc.Mkdir("/tmp/readdir", 0777)
+	for _, name := range []string{"a", "b", "c"} {
+		createFile(test, proc, "/tmp/readdir/"+name, "")
+	}
+	proc.Unlink("/tmp/readdir/b")
+
+	entries, err = proc.ReadDir("/tmp/readdir")
+	if err != nil || len(entries) != 4 || findEntry(entries, "b") != nil {
+		testutils.ErrorHere(test, "Unexpected entries after unlink: %v %v", entries, err)
+	}
+
+	proc.Unlink("/tmp/readdir/a")
+	proc.Unlink("/tmp/readdir/c")
+	if err = proc.Rmdir("/tmp/readdir"); err != nil {
+		testutils.ErrorHere(test, "Failed when removing directory: %s", err)
+	}
+
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test reading directory entries incrementally from an open directory
+func TestGetdents(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	if _, err := proc.Open("/", common.O_RDWR, 0); !errors.Is(err, common.EISDIR) {
+		testutils.ErrorHere(test, "Expected EISDIR opening directory for writing, got: %v", err)
+	}
+
+	expected, _ := proc.ReadDir("/")
+	dir, err := proc.Open("/", common.O_RDONLY, 0)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when opening directory: %s", err)
+	}
+
+	var entries []common.Dirent
+	for {
+		chunk, err := proc.Getdents(dir, 3)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			testutils.FatalHere(test, "Failed when calling getdents: %s", err)
+		} else if len(chunk) > 3 {
+			testutils.ErrorHere(test, "Too many entries returned: %d", len(chunk))
+		}
+		entries = append(entries, chunk...)
+	}
+
+	if len(entries) != len(expected) {
+		testutils.FatalHere(test, "Entry count mismatch expected %d, got %d", len(expected), len(entries))
+	}
+	for i := range entries {
+		// Only ReadDir fills in the file types
+		want := expected[i]
+		want.Type = 0
+		if entries[i] != want {
+			testutils.ErrorHere(test, "Entry %d mismatch expected %v, got %v", i, want, entries[i])
+		}
+	}
+
+	// Rewinding the directory starts from the first entry again
+	proc.Seek(dir, 0, 0)
+	if entries, err = proc.Getdents(dir, 0); err != nil || len(entries) != len(expected) {
+		testutils.ErrorHere(test, "Failed when rereading directory: %d entries, %v", len(entries), err)
+	}
+
+	proc.Close(dir)
+
+	file, err := proc.Open("/sample/europarl-en.txt", common.O_RDONLY, 0)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when opening file: %s", err)
+	}
+	if _, err = proc.Getdents(file, 1); !errors.Is(err, common.ENOTDIR) {
+		testutils.ErrorHere(test, "Expected ENOTDIR, got: %v", err)
+	}
+	proc.Close(file)
+
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test reading an open directory while entries are added to it
+func TestGetdentsWhileChanging(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	proc.Mkdir("/tmp/changing", 0755)
+	dir, err := proc.Open("/tmp/changing", common.O_RDONLY, 0)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when opening directory: %s", err)
+	}
+
+	file, _ := proc.File(dir)
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			createFile(test, proc, fmt.Sprintf("/tmp/changing/%d", i), "")
+		}
+		done <- true
+	}()
+
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		file.Seek(0, 0)
+		if _, err = file.Getdents(0); err != nil {
+			testutils.FatalHere(test, "Failed when calling getdents: %s", err)
+		}
+	}
+
+	proc.Seek(dir, 0, 0)
+	if entries, _ := proc.Getdents(dir, 0); len(entries) != 102 {
+		testutils.ErrorHere(test, "Entry count mismatch expected %d, got %d", 102, len(entries))
+	}
+	proc.Close(dir)
+
+	for i := 0; i < 100; i++ {
+		proc.Unlink(fmt.Sprintf("/tmp/changing/%d", i))
+	}
+	if err = proc.Rmdir("/tmp/changing"); err != nil {
+		testutils.ErrorHere(test, "Failed when removing directory: %s", err)
+	}
+
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
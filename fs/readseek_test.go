@@ -2,6 +2,7 @@ package fs
 
 import (
 	"bytes"
+	"errors"
 	"github.com/jnwhiteh/minixfs/common"
 	"github.com/jnwhiteh/minixfs/testutils"
 	"io"
@@ -30,7 +31,7 @@ func TestRead(test *testing.T) {
 	offset := 0
 
 	for {
-		n, err := file.Read(data)
+		n, err := proc.Read(file, data)
 		od, oerr := ofile.Read(odata)
 
 		if n != od {
@@ -72,7 +73,7 @@ func TestSeek(test *testing.T) {
 
 	type seekData struct {
 		whence int
-		pos    int
+		pos    int64
 	}
 
 	seekOps := []seekData{
@@ -90,14 +91,14 @@ func TestSeek(test *testing.T) {
 	odata := make([]byte, numbytes)
 
 	for idx, testData := range seekOps {
-		pos, err := file.Seek(testData.pos, testData.whence)
-		opos, err := ofile.Seek(int64(testData.pos), testData.whence)
+		pos, err := proc.Seek(file, testData.pos, testData.whence)
+		opos, err := ofile.Seek(testData.pos, testData.whence)
 
-		if int64(pos) != opos {
+		if pos != opos {
 			testutils.FatalHere(test, "Seek position mismatch in test %d: exected %d, got %d", idx, opos, pos)
 		}
 
-		n, err := file.Read(data)
+		n, err := proc.Read(file, data)
 		od, oerr := ofile.Read(odata)
 
 		if n != od {
@@ -117,3 +118,165 @@ func TestSeek(test *testing.T) {
 		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
 	}
 }
+
+// Test seeking relative to the end of the file, and rejecting seeks to
+// negative positions or with an unknown whence.
+func TestSeekEnd(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+	file, err := proc.Open("/sample/europarl-en.txt", common.O_RDONLY, 0666)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when opening file: %s", err)
+	}
+
+	size := int64(4489799)
+	if pos, err := proc.Seek(file, -10, common.SEEK_END); err != nil || pos != size-10 {
+		testutils.ErrorHere(test, "SEEK_END mismatch expected %d, got %d (%v)", size-10, pos, err)
+	}
+	if pos, err := proc.Seek(file, 5, common.SEEK_CUR); err != nil || pos != size-5 {
+		testutils.ErrorHere(test, "SEEK_CUR mismatch expected %d, got %d (%v)", size-5, pos, err)
+	}
+
+	// Errors leave the position unchanged
+	if _, err = proc.Seek(file, -size-1, common.SEEK_END); !errors.Is(err, common.EINVAL) {
+		testutils.ErrorHere(test, "Expected EINVAL seeking before start, got: %v", err)
+	}
+	if _, err = proc.Seek(file, -1, common.SEEK_SET); !errors.Is(err, common.EINVAL) {
+		testutils.ErrorHere(test, "Expected EINVAL seeking to -1, got: %v", err)
+	}
+	if _, err = proc.Seek(file, 0, 42); !errors.Is(err, common.EINVAL) {
+		testutils.ErrorHere(test, "Expected EINVAL for unknown whence, got: %v", err)
+	}
+	if pos, _ := proc.Seek(file, 0, common.SEEK_CUR); pos != size-5 {
+		testutils.ErrorHere(test, "Position changed by failed seek: %d", pos)
+	}
+
+	proc.Close(file)
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test finding holes and data in a sparse file
+func TestSeekHoles(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+	file, err := proc.Open("/tmp/sparse", common.O_CREAT|common.O_RDWR, 0666)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when creating file: %s", err)
+	}
+
+	// Data in the first block and the fourth block, with a hole between
+	bsize := int64(4096)
+	proc.Write(file, []byte("data"))
+	proc.Seek(file, 3*bsize, common.SEEK_SET)
+	proc.Write(file, []byte("more"))
+	size := 3*bsize + 4
+
+	var seeks = []struct {
+		pos      int64
+		whence   int
+		expected int64
+	}{
+		{0, common.SEEK_DATA, 0},
+		{2, common.SEEK_DATA, 2},
+		{0, common.SEEK_HOLE, bsize},
+		{bsize + 10, common.SEEK_HOLE, bsize + 10},
+		{bsize + 10, common.SEEK_DATA, 3 * bsize},
+		{3*bsize + 1, common.SEEK_HOLE, size},
+	}
+	for _, s := range seeks {
+		if pos, err := proc.Seek(file, s.pos, s.whence); err != nil || pos != s.expected {
+			testutils.ErrorHere(test, "Seek(%d, %d) expected %d, got %d (%v)", s.pos, s.whence, s.expected, pos, err)
+		}
+	}
+
+	if _, err = proc.Seek(file, size, common.SEEK_DATA); !errors.Is(err, common.ENXIO) {
+		testutils.ErrorHere(test, "Expected ENXIO for SEEK_DATA at end, got: %v", err)
+	}
+	if _, err = proc.Seek(file, size, common.SEEK_HOLE); !errors.Is(err, common.ENXIO) {
+		testutils.ErrorHere(test, "Expected ENXIO for SEEK_HOLE at end, got: %v", err)
+	}
+
+	// The hole reads as zeroes
+	data := []byte("xxxxxx")
+	if n, err := proc.Pread(file, data, 3*bsize-2); n != 6 || err != nil {
+		testutils.ErrorHere(test, "ReadAt across hole returned %d (%v)", n, err)
+	}
+	if string(data) != "\x00\x00more" {
+		testutils.ErrorHere(test, "ReadAt across hole got %q", data)
+	}
+
+	proc.Close(file)
+	if err = proc.Unlink("/tmp/sparse"); err != nil {
+		testutils.ErrorHere(test, "Failed when unlinking file: %s", err)
+	}
+
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test positional reads against the host operating system, and that they do
+// not move the file position.
+func TestReadAt(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+	file, err := proc.Open("/sample/europarl-en.txt", common.O_RDONLY, 0)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when opening file: %s", err)
+	}
+
+	ofile := OpenEuroparl(test)
+	proc.Seek(file, 100, common.SEEK_SET)
+
+	data := make([]byte, 5000)
+	odata := make([]byte, 5000)
+	for _, off := range []int64{0, 31337, 4096*100 + 7, 4489799 - 10, 4489799, 4489799 + 10} {
+		n, err := proc.Pread(file, data, off)
+		on, oerr := ofile.ReadAt(odata, off)
+		if n != on || err != oerr {
+			testutils.ErrorHere(test, "ReadAt(%d) expected %d (%v), got %d (%v)", off, on, oerr, n, err)
+		}
+		if bytes.Compare(data[:n], odata[:on]) != 0 {
+			testutils.ErrorHere(test, "ReadAt(%d) data mismatch", off)
+		}
+	}
+	if _, err = proc.Pread(file, data, -1); !errors.Is(err, common.EINVAL) {
+		testutils.ErrorHere(test, "Expected EINVAL for negative offset, got: %v", err)
+	}
+	if pos, _ := proc.Seek(file, 0, common.SEEK_CUR); pos != 100 {
+		testutils.ErrorHere(test, "Position changed by ReadAt: %d", pos)
+	}
+
+	// The descriptor can be used wherever the io interfaces are expected
+	fd, _ := proc.File(file)
+	section := io.NewSectionReader(fd, 4489799-5, 100)
+	if tail, err := io.ReadAll(section); err != nil || len(tail) != 5 {
+		testutils.ErrorHere(test, "Reading section got %d bytes (%v)", len(tail), err)
+	}
+
+	// Closing it closes the descriptor it was obtained from
+	if err = fd.Close(); err != nil {
+		testutils.ErrorHere(test, "Failed when closing file: %s", err)
+	}
+	if err = proc.Close(file); !errors.Is(err, common.EBADF) {
+		testutils.ErrorHere(test, "Expected EBADF closing twice, got: %v", err)
+	}
+
+	// Closing it again leaves alone a new file that reuses the descriptor
+	reused, _ := proc.Open("/sample/europarl-en.txt", common.O_RDONLY, 0)
+	if reused != file {
+		testutils.FatalHere(test, "Descriptor not reused, got %d, expected %d", reused, file)
+	}
+	if err = fd.Close(); !errors.Is(err, common.EBADF) {
+		testutils.ErrorHere(test, "Expected EBADF closing file twice, got: %v", err)
+	}
+	if err = proc.Close(reused); err != nil {
+		testutils.ErrorHere(test, "Failed when closing reused descriptor: %s", err)
+	}
+
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
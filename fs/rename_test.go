@@ -0,0 +1,284 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jnwhiteh/minixfs/common"
+	"github.com/jnwhiteh/minixfs/testutils"
+	"testing"
+)
+
+func createFile(test *testing.T, proc *Process, path string, data string) {
+	file, err := proc.Open(path, common.O_CREAT|common.O_TRUNC|common.O_RDWR, 0666)
+	if err != nil {
+		testutils.FatalLevel(test, 2, "Failed when creating %s: %s", path, err)
+	}
+	if _, err = proc.Write(file, []byte(data)); err != nil {
+		testutils.FatalLevel(test, 2, "Failed when writing %s: %s", path, err)
+	}
+	if err = proc.Close(file); err != nil {
+		testutils.FatalLevel(test, 2, "Failed when closing %s: %s", path, err)
+	}
+}
+
+// Test renaming a file within a directory and replacing an existing file
+func TestRenameFile(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	createFile(test, proc, "/tmp/rename_a", "A")
+	createFile(test, proc, "/tmp/rename_b", "BB")
+
+	stat, err := proc.Stat("/tmp/rename_a")
+	if err != nil {
+		testutils.FatalHere(test, "Failed when calling stat: %s", err)
+	}
+	inum := stat.Inum
+
+	// Rename to a new name, in the same directory
+	if err = proc.Rename("/tmp/rename_a", "/tmp/rename_c"); err != nil {
+		testutils.FatalHere(test, "Failed when renaming file: %s", err)
+	}
+	if _, err = proc.Stat("/tmp/rename_a"); !errors.Is(err, common.ENOENT) {
+		testutils.ErrorHere(test, "Old name still exists after rename: %v", err)
+	}
+	if stat, err = proc.Stat("/tmp/rename_c"); err != nil || stat.Inum != inum {
+		testutils.ErrorHere(test, "New name does not refer to renamed inode: %v", err)
+	}
+
+	// Rename over the top of an existing file
+	if err = proc.Rename("/tmp/rename_c", "/tmp/rename_b"); err != nil {
+		testutils.FatalHere(test, "Failed when replacing file: %s", err)
+	}
+	if _, err = proc.Stat("/tmp/rename_c"); !errors.Is(err, common.ENOENT) {
+		testutils.ErrorHere(test, "Old name still exists after rename: %v", err)
+	}
+	stat, err = proc.Stat("/tmp/rename_b")
+	if err != nil || stat.Inum != inum || stat.Size != 1 || stat.Nlinks != 1 {
+		testutils.ErrorHere(test, "Replaced file has wrong contents: %v %v", stat, err)
+	}
+
+	// Renaming a file to itself does nothing
+	if err = proc.Rename("/tmp/rename_b", "/tmp/rename_b"); err != nil {
+		testutils.ErrorHere(test, "Failed when renaming file to itself: %s", err)
+	}
+	if err = proc.Rename("/tmp/rename_b", "/tmp/."); !errors.Is(err, common.EINVAL) {
+		testutils.ErrorHere(test, "Expected EINVAL when renaming to '.', got: %v", err)
+	}
+	if err = proc.Rename("/tmp/nonexistent", "/tmp/rename_c"); !errors.Is(err, common.ENOENT) {
+		testutils.ErrorHere(test, "Expected ENOENT when renaming missing file, got: %v", err)
+	}
+
+	if err = proc.Unlink("/tmp/rename_b"); err != nil {
+		testutils.ErrorHere(test, "Failed when unlinking file: %s", err)
+	}
+
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test moving a directory to a new parent, which must update '..' and the
+// link counts of both parents.
+func TestRenameDirectory(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	for _, path := range []string{"/tmp/d1", "/tmp/d2", "/tmp/d1/sub", "/tmp/d1/sub/inner"} {
+		if err := proc.Mkdir(path, 0777); err != nil {
+			testutils.FatalHere(test, "Failed when creating %s: %s", path, err)
+		}
+	}
+	createFile(test, proc, "/tmp/d2/file", "")
+
+	if err := proc.Rename("/tmp/d1/sub", "/tmp/d2/sub"); err != nil {
+		testutils.FatalHere(test, "Failed when moving directory: %s", err)
+	}
+
+	d1, _ := proc.Stat("/tmp/d1")
+	d2, _ := proc.Stat("/tmp/d2")
+	if d1.Nlinks != 2 {
+		testutils.ErrorHere(test, "Old parent links mismatch expected %d, got %d", 2, d1.Nlinks)
+	}
+	if d2.Nlinks != 3 {
+		testutils.ErrorHere(test, "New parent links mismatch expected %d, got %d", 3, d2.Nlinks)
+	}
+
+	rip, err := fs.eatPath(proc, "/tmp/d2/sub")
+	if err != nil {
+		testutils.FatalHere(test, "Failed when looking up moved directory: %s", err)
+	}
+	if ok, _, inum := Lookup(rip, ".."); !ok || inum != d2.Inum {
+		testutils.ErrorHere(test, "Moved directory .. mismatch expected %d, got %d", d2.Inum, inum)
+	}
+	fs.itable.PutInode(rip)
+
+	// A directory cannot be moved into its own subtree
+	if err = proc.Rename("/tmp/d2", "/tmp/d2/sub/inner/d2"); !errors.Is(err, common.EINVAL) {
+		testutils.ErrorHere(test, "Expected EINVAL moving directory into itself, got: %v", err)
+	}
+	if err = proc.Rename("/tmp/d2", "/tmp/d2/sub"); !errors.Is(err, common.EINVAL) {
+		testutils.ErrorHere(test, "Expected EINVAL moving directory onto child, got: %v", err)
+	}
+
+	// Type and emptiness checks when replacing an existing target
+	if err = proc.Rename("/tmp/d1", "/tmp/d2/file"); !errors.Is(err, common.ENOTDIR) {
+		testutils.ErrorHere(test, "Expected ENOTDIR, got: %v", err)
+	}
+	if err = proc.Rename("/tmp/d2/file", "/tmp/d1"); !errors.Is(err, common.EISDIR) {
+		testutils.ErrorHere(test, "Expected EISDIR, got: %v", err)
+	}
+	if err = proc.Rename("/tmp/d1", "/tmp/d2/sub"); !errors.Is(err, common.ENOTEMPTY) {
+		testutils.ErrorHere(test, "Expected ENOTEMPTY, got: %v", err)
+	}
+
+	// Replace an empty directory in a different parent
+	if err = proc.Rename("/tmp/d1", "/tmp/d2/sub/inner"); err != nil {
+		testutils.ErrorHere(test, "Failed when replacing empty directory: %s", err)
+	}
+	tmp, _ := proc.Stat("/tmp")
+	sub, _ := proc.Stat("/tmp/d2/sub")
+	if sub.Nlinks != 3 {
+		testutils.ErrorHere(test, "Parent links mismatch expected %d, got %d", 3, sub.Nlinks)
+	}
+
+	for _, path := range []string{"/tmp/d2/sub/inner", "/tmp/d2/sub"} {
+		if err = proc.Rmdir(path); err != nil {
+			testutils.ErrorHere(test, "Failed when removing %s: %s", path, err)
+		}
+	}
+	proc.Unlink("/tmp/d2/file")
+	if err = proc.Rmdir("/tmp/d2"); err != nil {
+		testutils.ErrorHere(test, "Failed when removing /tmp/d2: %s", err)
+	}
+	if after, _ := proc.Stat("/tmp"); after.Nlinks != tmp.Nlinks-1 {
+		testutils.ErrorHere(test, "/tmp links mismatch expected %d, got %d", tmp.Nlinks-1, after.Nlinks)
+	}
+
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test that a rename which cannot enter its new name on a full device
+// leaves the old name in place, and that replacing an existing target needs
+// no space at all.
+func TestRenameFullDevice(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	// Fill the first block of a directory, so any new entry needs a zone
+	createFile(test, proc, "/tmp/full_a", "A")
+	createFile(test, proc, "/tmp/full_b", "BB")
+	if err := proc.Mkdir("/tmp/full_dir", 0777); err != nil {
+		testutils.FatalHere(test, "Failed when creating directory: %s", err)
+	}
+	slots := fs.devinfo[common.ROOT_DEVICE].Blocksize / common.DIR_ENTRY_SIZE
+	for i := 2; i < slots; i++ {
+		if err := proc.Link("/tmp/full_b", fmt.Sprintf("/tmp/full_dir/%d", i)); err != nil {
+			testutils.FatalHere(test, "Failed when linking file: %s", err)
+		}
+	}
+
+	// Use up every remaining zone on the device
+	alloc := fs.devinfo[common.ROOT_DEVICE].AllocTbl
+	var zones []int
+	for {
+		z, err := alloc.AllocZone(0)
+		if err != nil {
+			break
+		}
+		zones = append(zones, z)
+	}
+
+	stat, _ := proc.Stat("/tmp/full_a")
+	if err := proc.Rename("/tmp/full_a", "/tmp/full_dir/new"); !errors.Is(err, common.ENOSPC) {
+		testutils.ErrorHere(test, "Expected ENOSPC, got: %v", err)
+	}
+	if after, err := proc.Stat("/tmp/full_a"); err != nil || after.Inum != stat.Inum {
+		testutils.ErrorHere(test, "Old name lost after failed rename: %v", err)
+	}
+
+	if err := proc.Rename("/tmp/full_a", "/tmp/full_dir/2"); err != nil {
+		testutils.ErrorHere(test, "Failed when replacing file: %s", err)
+	}
+	if after, err := proc.Stat("/tmp/full_dir/2"); err != nil || after.Inum != stat.Inum {
+		testutils.ErrorHere(test, "Target does not refer to renamed inode: %v", err)
+	}
+	if b, _ := proc.Stat("/tmp/full_b"); b.Nlinks != uint16(slots-2) {
+		testutils.ErrorHere(test, "Replaced file links mismatch expected %d, got %d", slots-2, b.Nlinks)
+	}
+
+	for _, z := range zones {
+		alloc.FreeZone(z)
+	}
+	for i := 2; i < slots; i++ {
+		proc.Unlink(fmt.Sprintf("/tmp/full_dir/%d", i))
+	}
+	proc.Unlink("/tmp/full_b")
+	if err := proc.Rmdir("/tmp/full_dir"); err != nil {
+		testutils.ErrorHere(test, "Failed when removing directory: %s", err)
+	}
+
+	fs.Exit(proc)
+	if err := fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test that renames across devices are refused
+func TestRenameCrossDevice(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	createFile(test, proc, "/tmp/xdev", "")
+	mountImageCopy(test, proc)
+
+	if err := proc.Rename("/tmp/xdev", "/mnt/tmp/xdev"); !errors.Is(err, common.EXDEV) {
+		testutils.ErrorHere(test, "Expected EXDEV, got: %v", err)
+	}
+
+	if err := proc.Unmount("/mnt", 0); err != nil {
+		testutils.ErrorHere(test, "Failed when unmounting: %s", err)
+	}
+	if err := proc.Unlink("/tmp/xdev"); err != nil {
+		testutils.ErrorHere(test, "Failed when unlinking file: %s", err)
+	}
+
+	fs.Exit(proc)
+	if err := fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test that moving a directory does not need search permission on the
+// directories above its new parent.
+func TestRenameUnsearchableAncestor(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	for _, path := range []string{"/tmp/locked", "/tmp/locked/pub", "/tmp/locked/pub/a", "/tmp/locked/pub/b"} {
+		proc.Mkdir(path, 0777)
+		proc.Chmod(path, 0777)
+	}
+	proc.Chmod("/tmp/locked", 0700)
+
+	proc.Chdir("/tmp/locked/pub")
+	user := forkAs(test, proc, 1, 1)
+	proc.Chdir("/")
+	if err := user.Rename("a", "b/a"); err != nil {
+		testutils.ErrorHere(test, "Failed when renaming below unsearchable directory: %s", err)
+	}
+	if err := user.Rename("b", "b/a/b"); !errors.Is(err, common.EINVAL) {
+		testutils.ErrorHere(test, "Expected EINVAL moving directory into itself, got: %v", err)
+	}
+	fs.Exit(user)
+
+	for _, path := range []string{"/tmp/locked/pub/b/a", "/tmp/locked/pub/b", "/tmp/locked/pub", "/tmp/locked"} {
+		if err := proc.Rmdir(path); err != nil {
+			testutils.ErrorHere(test, "Failed when removing %s: %s", path, err)
+		}
+	}
+
+	fs.Exit(proc)
+	if err := fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
@@ -13,10 +13,11 @@ const (
 	ENTER                 // add 'path' to the directory listing with inode # 'inum'
 	DELETE                // remove 'path' from the directory listing
 	IS_EMPTY              // return OK if only . and .. are in the dir, else ENOTEMPTY
+	REPLACE               // change the inode # of 'path' to 'inum'
 )
 
 func search_dir(dirp *common.Inode, path string, inum *int, op dirop) error {
-	// TODO: Check permissions (see minix source)
+	// Permissions are checked by the callers, which know the process
 	devinfo := dirp.Devinfo
 	blocksize := devinfo.Blocksize
 
@@ -68,13 +69,19 @@ func search_dir(dirp *common.Inode, path string, inum *int, op dirop) error {
 
 			if match {
 				var r error = nil
-				// LOOK_UP or DELETE found what it wanted
+				// LOOK_UP, DELETE or REPLACE found what it wanted
 				if op == IS_EMPTY {
 					r = common.ENOTEMPTY
 				} else if op == DELETE {
 					// TODO: Save inode for recovery
 					dp.Inum = 0 // erase entry
 					bp.Dirty = true
+					dirp.Update |= common.CTIME | common.MTIME
+					dirp.Dirty = true
+				} else if op == REPLACE {
+					dp.Inum = uint32(*inum)
+					bp.Dirty = true
+					dirp.Update |= common.CTIME | common.MTIME
 					dirp.Dirty = true
 				} else {
 					*inum = int(dp.Inum)
@@ -139,7 +146,7 @@ func search_dir(dirp *common.Inode, path string, inum *int, op dirop) error {
 	bp.Dirty = true
 
 	dirp.Bcache.PutBlock(bp, common.DIRECTORY_BLOCK)
-	// TODO: update times
+	dirp.Update |= common.CTIME | common.MTIME
 	dirp.Dirty = true
 	if new_slots > old_slots {
 		dirp.Size = (int32(new_slots * common.DIR_ENTRY_SIZE))
@@ -7,6 +7,7 @@ import (
 	"github.com/jnwhiteh/minixfs/common"
 	"github.com/jnwhiteh/minixfs/device"
 	"github.com/jnwhiteh/minixfs/inode"
+	"github.com/jnwhiteh/minixfs/watch"
 	"log"
 )
 
@@ -20,23 +21,35 @@ type FileSystem struct {
 	procs      map[int]*Process // the list of user processes
 	pidcounter int              // the next available pid
 
+	clock   common.Clock    // the source of time for inode timestamps
+	watches common.WatchTbl // the watches on files and directories
+
 	in  chan reqFS
 	out chan resFS
 }
 
-// Create a new FileSystem from a given file on the filesystem
-func OpenFileSystemFile(filename string) (*FileSystem, *Process, error) {
-	dev, err := device.NewFileDevice(filename, binary.LittleEndian)
+// Create a new FileSystem from a given file on the filesystem, mounted with
+// the given flags. The file is opened read-only when MS_RDONLY is given.
+func OpenFileSystemFile(filename string, flags int) (*FileSystem, *Process, error) {
+	var dev common.BlockDevice
+	var err error
+	if flags&common.MS_RDONLY != 0 {
+		dev, err = device.NewFileDeviceReadOnly(filename, binary.LittleEndian)
+	} else {
+		dev, err = device.NewFileDevice(filename, binary.LittleEndian)
+	}
 
 	if err != nil {
 		return nil, nil, err
 	}
 
-	return NewFileSystem(dev)
+	return NewFileSystem(dev, nil, flags)
 }
 
-// Create a new FileSystem from a given file on the filesystem
-func NewFileSystem(dev common.BlockDevice) (*FileSystem, *Process, error) {
+// Create a new FileSystem with the given device as its root device, mounted
+// with the given flags. Inodes are stamped with times from 'clock', or from
+// the system clock if it is nil.
+func NewFileSystem(dev common.BlockDevice, clock common.Clock, flags int) (*FileSystem, *Process, error) {
 	// Check to make sure we have a valid device
 	devinfo, err := common.GetDeviceInfo(dev)
 	if err != nil {
@@ -45,6 +58,12 @@ func NewFileSystem(dev common.BlockDevice) (*FileSystem, *Process, error) {
 
 	fs := new(FileSystem)
 
+	if clock == nil {
+		clock = common.SystemClock
+	}
+	fs.clock = clock
+	fs.watches = watch.NewWatchTbl()
+
 	fs.devices = make([]common.BlockDevice, common.NR_DEVICES)
 	fs.devinfo = make([]*common.DeviceInfo, common.NR_DEVICES)
 
@@ -52,6 +71,9 @@ func NewFileSystem(dev common.BlockDevice) (*FileSystem, *Process, error) {
 	fs.itable = inode.NewCache(fs.bcache, common.NR_DEVICES, common.NR_INODES)
 
 	devinfo.Devnum = common.ROOT_DEVICE
+	devinfo.Clock = clock
+	devinfo.Flags = flags
+	devinfo.Watches = fs.watches
 
 	if err := fs.bcache.MountDevice(common.ROOT_DEVICE, dev, devinfo); err != nil {
 		log.Printf("Could not mount root device: %s", err)
@@ -78,12 +100,16 @@ func NewFileSystem(dev common.BlockDevice) (*FileSystem, *Process, error) {
 
 	// Create the root process
 	fs.procs[common.ROOT_PROCESS] = &Process{
-		common.ROOT_PROCESS,
-		022,
-		rip,
-		rip,
-		make([]*filp, common.OPEN_MAX),
-		fs,
+		pid:     common.ROOT_PROCESS,
+		umask:   022,
+		realuid: common.SU_UID,
+		effuid:  common.SU_UID,
+		rootdir: rip,
+		workdir: fs.itable.DupInode(rip),
+		ns:      &namespace{users: 1},
+		files:   make([]*filp, common.OPEN_MAX),
+		openmax: common.OPEN_MAX,
+		fs:      fs,
 	}
 
 	// Initialite the pidcounter
@@ -100,50 +126,153 @@ func (fs *FileSystem) loop() {
 		req := <-fs.in
 		switch req := req.(type) {
 		case req_FS_Mount:
-			err := fs.do_mount(req.proc, req.dev, req.path)
-			fs.out <- res_FS_Mount{err}
+			err := fs.do_mount(req.proc, req.dev, req.path, req.flags)
+			fs.out <- res_FS_Mount{pathError("mount", req.path, err)}
 		case req_FS_Unmount:
-			err := fs.do_unmount(req.proc, req.path)
-			fs.out <- res_FS_Unmount{err}
+			err := fs.do_unmount(req.proc, req.path, req.flags)
+			fs.out <- res_FS_Unmount{pathError("unmount", req.path, err)}
+		case req_FS_Bind:
+			err := fs.do_bind(req.proc, req.src, req.dst)
+			fs.out <- res_FS_Bind{linkError("bind", req.src, req.dst, err)}
 		case req_FS_Sync:
-			// Code here
+			err := fs.do_sync()
+			fs.out <- res_FS_Sync{syscallError("sync", err)}
 		case req_FS_Shutdown:
 			err := fs.do_shutdown()
-			if err == nil {
+			if err != common.EBUSY {
 				alive = false
 			}
 			fs.out <- res_FS_Shutdown{err}
+		case req_FS_Mounts:
+			mounts, err := fs.do_mounts()
+			fs.out <- res_FS_Mounts{mounts, syscallError("mounts", err)}
 		case req_FS_Fork:
-			proc, err := fs.do_fork(req.proc)
-			fs.out <- res_FS_Fork{proc, err}
+			proc, err := fs.do_fork(req.proc, false)
+			fs.out <- res_FS_Fork{proc, syscallError("fork", err)}
+		case req_FS_ForkNamespace:
+			proc, err := fs.do_fork(req.proc, true)
+			fs.out <- res_FS_ForkNamespace{proc, syscallError("fork", err)}
 		case req_FS_Exit:
 			fs.do_exit(req.proc)
 			fs.out <- res_FS_Exit{}
 		case req_FS_OpenCreat:
-			fd, err := fs.do_open(req.proc, req.path, req.flags, req.mode)
-			fs.out <- res_FS_OpenCreat{fd, err}
+			fd, err := fs.do_open(req.proc, nil, req.path, req.flags, req.mode)
+			fs.out <- res_FS_OpenCreat{fd, pathError("open", req.path, err)}
+		case req_FS_OpenAt:
+			fd, err := fs.do_openat(req.proc, req.dirfd, req.path, req.flags, req.mode)
+			fs.out <- res_FS_OpenAt{fd, pathError("openat", req.path, err)}
 		case req_FS_Close:
+			filp := req.proc.fdFilp(req.fd)
 			err := fs.do_close(req.proc, req.fd)
-			fs.out <- res_FS_Close{err}
+			fs.out <- res_FS_Close{fdError("close", req.fd, filp, err)}
+		case req_FS_Dup:
+			fd, err := fs.do_dup(req.proc, req.fd)
+			fs.out <- res_FS_Dup{fd, fdError("dup", req.fd, req.proc.fdFilp(req.fd), err)}
+		case req_FS_Dup2:
+			fd, err := fs.do_dup2(req.proc, req.oldfd, req.newfd)
+			fs.out <- res_FS_Dup2{fd, fdError("dup2", req.oldfd, req.proc.fdFilp(req.oldfd), err)}
+		case req_FS_Fcntl:
+			ret, err := fs.do_fcntl(req.proc, req.fd, req.cmd, req.arg)
+			fs.out <- res_FS_Fcntl{ret, fdError("fcntl", req.fd, req.proc.fdFilp(req.fd), err)}
+		case req_FS_Getfd:
+			filp, err := req.proc.getFilp(req.fd)
+			fs.out <- res_FS_Getfd{filp, err}
+		case req_FS_CloseFile:
+			err := fs.do_closefile(req.proc, req.fd, req.filp)
+			fs.out <- res_FS_CloseFile{fdError("close", req.fd, req.filp, err)}
 		case req_FS_Stat:
-			// Code here
+			stat, err := fs.do_stat(req.proc, nil, req.path)
+			fs.out <- res_FS_Stat{stat, pathError("stat", req.path, err)}
+		case req_FS_FstatAt:
+			stat, err := fs.do_fstatat(req.proc, req.dirfd, req.path, req.flags)
+			fs.out <- res_FS_FstatAt{stat, pathError("fstatat", req.path, err)}
+		case req_FS_Statfs:
+			stat, err := fs.do_statfs(req.proc, req.path)
+			fs.out <- res_FS_Statfs{stat, pathError("statfs", req.path, err)}
+		case req_FS_Watch:
+			events, cancel, err := fs.do_watch(req.proc, req.path, req.mask)
+			fs.out <- res_FS_Watch{events, cancel, pathError("watch", req.path, err)}
+		case req_FS_ReadDir:
+			entries, err := fs.do_readdir(req.proc, req.path)
+			fs.out <- res_FS_ReadDir{entries, pathError("readdir", req.path, err)}
+		case req_FS_Getdents:
+			entries, err := fs.do_getdents(req.filp, req.count)
+			fs.out <- res_FS_Getdents{entries, err}
+		case req_FS_Lstat:
+			stat, err := fs.do_lstat(req.proc, nil, req.path)
+			fs.out <- res_FS_Lstat{stat, pathError("lstat", req.path, err)}
 		case req_FS_Chmod:
-			// Code here
+			err := fs.do_chmod(req.proc, req.path, req.mode)
+			fs.out <- res_FS_Chmod{pathError("chmod", req.path, err)}
+		case req_FS_Chown:
+			err := fs.do_chown(req.proc, req.path, req.uid, req.gid)
+			fs.out <- res_FS_Chown{pathError("chown", req.path, err)}
+		case req_FS_Fchmod:
+			err := fs.do_fchmod(req.proc, req.fd, req.mode)
+			fs.out <- res_FS_Fchmod{fdError("fchmod", req.fd, req.proc.fdFilp(req.fd), err)}
+		case req_FS_Fchown:
+			err := fs.do_fchown(req.proc, req.fd, req.uid, req.gid)
+			fs.out <- res_FS_Fchown{fdError("fchown", req.fd, req.proc.fdFilp(req.fd), err)}
+		case req_FS_Utime:
+			err := fs.do_utime(req.proc, req.path, req.atime, req.mtime)
+			fs.out <- res_FS_Utime{pathError("utime", req.path, err)}
 		case req_FS_Link:
 			err := fs.do_link(req.proc, req.oldpath, req.newpath)
-			fs.out <- res_FS_Link{err}
+			fs.out <- res_FS_Link{linkError("link", req.oldpath, req.newpath, err)}
 		case req_FS_Unlink:
-			err := fs.do_unlink(req.proc, req.path)
-			fs.out <- res_FS_Unlink{err}
+			err := fs.do_unlink(req.proc, nil, req.path)
+			fs.out <- res_FS_Unlink{pathError("unlink", req.path, err)}
+		case req_FS_UnlinkAt:
+			err := fs.do_unlinkat(req.proc, req.dirfd, req.path, req.flags)
+			fs.out <- res_FS_UnlinkAt{pathError("unlinkat", req.path, err)}
+		case req_FS_Rename:
+			err := fs.do_rename(req.proc, nil, req.oldpath, nil, req.newpath)
+			fs.out <- res_FS_Rename{linkError("rename", req.oldpath, req.newpath, err)}
+		case req_FS_RenameAt:
+			err := fs.do_renameat(req.proc, req.olddirfd, req.oldpath, req.newdirfd, req.newpath)
+			fs.out <- res_FS_RenameAt{linkError("renameat", req.oldpath, req.newpath, err)}
+		case req_FS_Symlink:
+			err := fs.do_symlink(req.proc, req.target, req.path)
+			fs.out <- res_FS_Symlink{linkError("symlink", req.target, req.path, err)}
+		case req_FS_Readlink:
+			target, err := fs.do_readlink(req.proc, nil, req.path)
+			fs.out <- res_FS_Readlink{target, pathError("readlink", req.path, err)}
+		case req_FS_ReadlinkAt:
+			target, err := fs.do_readlinkat(req.proc, req.dirfd, req.path)
+			fs.out <- res_FS_ReadlinkAt{target, pathError("readlinkat", req.path, err)}
 		case req_FS_Mkdir:
-			err := fs.do_mkdir(req.proc, req.path, req.mode)
-			fs.out <- res_FS_Mkdir{err}
+			err := fs.do_mkdir(req.proc, nil, req.path, req.mode)
+			fs.out <- res_FS_Mkdir{pathError("mkdir", req.path, err)}
+		case req_FS_MkdirAt:
+			err := fs.do_mkdirat(req.proc, req.dirfd, req.path, req.mode)
+			fs.out <- res_FS_MkdirAt{pathError("mkdirat", req.path, err)}
 		case req_FS_Rmdir:
-			err := fs.do_rmdir(req.proc, req.path)
-			fs.out <- res_FS_Rmdir{err}
+			err := fs.do_rmdir(req.proc, nil, req.path)
+			fs.out <- res_FS_Rmdir{pathError("rmdir", req.path, err)}
+		case req_FS_Setuid:
+			err := fs.do_setuid(req.proc, req.uid)
+			fs.out <- res_FS_Setuid{syscallError("setuid", err)}
+		case req_FS_Setgid:
+			err := fs.do_setgid(req.proc, req.gid)
+			fs.out <- res_FS_Setgid{syscallError("setgid", err)}
+		case req_FS_Setgroups:
+			err := fs.do_setgroups(req.proc, req.groups)
+			fs.out <- res_FS_Setgroups{syscallError("setgroups", err)}
+		case req_FS_SetOpenMax:
+			err := fs.do_setopenmax(req.proc, req.max)
+			fs.out <- res_FS_SetOpenMax{syscallError("setopenmax", err)}
 		case req_FS_Chdir:
 			err := fs.do_chdir(req.proc, req.path)
-			fs.out <- res_FS_Chdir{err}
+			fs.out <- res_FS_Chdir{pathError("chdir", req.path, err)}
+		case req_FS_Chroot:
+			err := fs.do_chroot(req.proc, req.path)
+			fs.out <- res_FS_Chroot{pathError("chroot", req.path, err)}
+		case req_FS_Fchdir:
+			err := fs.do_fchdir(req.proc, req.fd)
+			fs.out <- res_FS_Fchdir{fdError("fchdir", req.fd, req.proc.fdFilp(req.fd), err)}
+		case req_FS_Getcwd:
+			path, err := fs.do_getcwd(req.proc)
+			fs.out <- res_FS_Getcwd{path, syscallError("getcwd", err)}
 		}
 	}
 }
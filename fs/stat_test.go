@@ -0,0 +1,153 @@
+package fs
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/jnwhiteh/minixfs/common"
+	"github.com/jnwhiteh/minixfs/device"
+	"github.com/jnwhiteh/minixfs/testutils"
+	"strings"
+	"testing"
+)
+
+// Test that stat returns the information stored in the inode, for both files
+// and directories.
+func TestStat(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	type statTest struct {
+		path  string
+		inum  int
+		ftype uint16
+		links int
+		size  int
+	}
+
+	statTests := []statTest{
+		{"/", 1, common.I_DIRECTORY, 14, 1088},
+		{"/sample", 541, common.I_DIRECTORY, 2, 192},
+		{"/sample/europarl-en.txt", 542, common.I_REGULAR, 1, 4489799},
+		{"/var/run/syslogd.pid", 481, common.I_REGULAR, 1, 5},
+	}
+
+	for _, stest := range statTests {
+		stat, err := proc.Stat(stest.path)
+		if err != nil {
+			testutils.FatalHere(test, "Failed when calling stat on %s: %s", stest.path, err)
+		}
+		if stat.Dev != common.ROOT_DEVICE {
+			testutils.ErrorHere(test, "[%s] mismatch for dev got %d, expected %d", stest.path, stat.Dev, common.ROOT_DEVICE)
+		}
+		if stat.Inum != stest.inum {
+			testutils.ErrorHere(test, "[%s] mismatch for inum got %d, expected %d", stest.path, stat.Inum, stest.inum)
+		}
+		if stat.Mode&common.I_TYPE != stest.ftype {
+			testutils.ErrorHere(test, "[%s] mismatch for type got %o, expected %o", stest.path, stat.Mode&common.I_TYPE, stest.ftype)
+		}
+		if stat.Nlinks != uint16(stest.links) {
+			testutils.ErrorHere(test, "[%s] mismatch for links got %d, expected %d", stest.path, stat.Nlinks, stest.links)
+		}
+		if stat.Size != int32(stest.size) {
+			testutils.ErrorHere(test, "[%s] mismatch for size got %d, expected %d", stest.path, stat.Size, stest.size)
+		}
+		if stat.Blksize != fs.devinfo[common.ROOT_DEVICE].Blocksize {
+			testutils.ErrorHere(test, "[%s] mismatch for blksize got %d, expected %d", stest.path, stat.Blksize, fs.devinfo[common.ROOT_DEVICE].Blocksize)
+		}
+		blocks := (stest.size + stat.Blksize - 1) / stat.Blksize
+		if stat.Blocks != blocks {
+			testutils.ErrorHere(test, "[%s] mismatch for blocks got %d, expected %d", stest.path, stat.Blocks, blocks)
+		}
+	}
+
+	if _, err := proc.Stat("/sample/nonexistent"); !errors.Is(err, common.ENOENT) {
+		testutils.ErrorHere(test, "Expected ENOENT error, got: %v", err)
+	}
+
+	fs.Exit(proc)
+	err := fs.Shutdown()
+	if err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test that fstat on an open file agrees with stat on its path
+func TestFstat(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	file, err := proc.Open("/sample/europarl-en.txt", common.O_RDONLY, 0666)
+	if err != nil {
+		testutils.FatalHere(test, "Failed opening file: %s", err)
+	}
+
+	fstat, err := proc.Fstat(file)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when calling fstat: %s", err)
+	}
+	stat, err := proc.Stat("/sample/europarl-en.txt")
+	if err != nil {
+		testutils.FatalHere(test, "Failed when calling stat: %s", err)
+	}
+	if *fstat != *stat {
+		testutils.ErrorHere(test, "Stat mismatch expected %v, got %v", stat, fstat)
+	}
+
+	if err = proc.Close(file); err != nil {
+		testutils.ErrorHere(test, "Failed when closing file: %s", err)
+	}
+
+	fs.Exit(proc)
+	err = fs.Shutdown()
+	if err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test that statfs reports the free space, and that the counts are kept up to
+// date as files are created and removed.
+func TestStatfs(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	before, err := proc.Statfs("/sample")
+	if err != nil {
+		testutils.FatalHere(test, "Failed when calling statfs: %s", err)
+	}
+	if before.Blocksize != 4096 || before.FreeInodes >= before.Inodes || before.FreeZones >= before.Zones {
+		testutils.ErrorHere(test, "Unexpected statfs result: %+v", before)
+	}
+
+	// Four data zones and one inode
+	createFile(test, proc, "/tmp/statfs", strings.Repeat("x", 3*4096+1))
+	during, _ := proc.Statfs("/tmp/statfs")
+	if during.FreeInodes != before.FreeInodes-1 || during.FreeZones != before.FreeZones-4 {
+		testutils.ErrorHere(test, "Free counts went from %+v to %+v", before, during)
+	}
+
+	proc.Unlink("/tmp/statfs")
+	after, _ := proc.Statfs("/")
+	if *after != *before {
+		testutils.ErrorHere(test, "Free counts went from %+v to %+v after unlink", before, after)
+	}
+
+	// A fresh count of the bit maps on the device agrees
+	proc.Sync()
+	dev, err := device.NewFileDeviceReadOnly(minixImage(test), binary.LittleEndian)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when creating new device: %s", err)
+	}
+	if err = proc.Mount(dev, "/mnt", common.MS_RDONLY); err != nil {
+		testutils.FatalHere(test, "Failed when mounting: %s", err)
+	}
+	if mounted, _ := proc.Statfs("/mnt"); *mounted != *after {
+		testutils.ErrorHere(test, "Counts on device %+v do not match %+v", mounted, after)
+	}
+	proc.Unmount("/mnt", 0)
+
+	if _, err = proc.Statfs("/nonexistent"); !errors.Is(err, common.ENOENT) {
+		testutils.ErrorHere(test, "Expected ENOENT, got: %v", err)
+	}
+
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
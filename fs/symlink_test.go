@@ -0,0 +1,125 @@
+package fs
+
+import (
+	"errors"
+	"github.com/jnwhiteh/minixfs/common"
+	"github.com/jnwhiteh/minixfs/testutils"
+	"testing"
+)
+
+// Test creating symbolic links and following them during path lookup
+func TestSymlink(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	target := "/sample/europarl-en.txt"
+	if err := proc.Symlink(target, "/tmp/link"); err != nil {
+		testutils.FatalHere(test, "Failed when creating symlink: %s", err)
+	}
+	if err := proc.Symlink("../sample", "/tmp/rel"); err != nil {
+		testutils.FatalHere(test, "Failed when creating symlink: %s", err)
+	}
+
+	if link, err := proc.Readlink("/tmp/link"); err != nil || link != target {
+		testutils.ErrorHere(test, "Readlink mismatch expected %q, got %q (%v)", target, link, err)
+	}
+	if _, err := proc.Readlink(target); !errors.Is(err, common.EINVAL) {
+		testutils.ErrorHere(test, "Expected EINVAL reading a regular file as link, got: %v", err)
+	}
+
+	// Stat follows the link, lstat does not
+	stat, err := proc.Stat("/tmp/link")
+	if err != nil || stat.Inum != 542 {
+		testutils.ErrorHere(test, "Stat did not follow link: %v %v", stat, err)
+	}
+	lstat, err := proc.Lstat("/tmp/link")
+	if err != nil {
+		testutils.FatalHere(test, "Failed when calling lstat: %s", err)
+	}
+	if lstat.Mode&common.I_TYPE != common.I_SYMBOLIC_LINK || lstat.Size != int32(len(target)) {
+		testutils.ErrorHere(test, "Lstat mismatch, mode %o size %d", lstat.Mode, lstat.Size)
+	}
+
+	// Relative links in the middle of a path are resolved from the directory
+	// containing the link.
+	if stat, err = proc.Stat("/tmp/rel/europarl-en.txt"); err != nil || stat.Inum != 542 {
+		testutils.ErrorHere(test, "Lookup through relative link failed: %v %v", stat, err)
+	}
+
+	// Opening the link opens the file it points to
+	file, err := proc.Open("/tmp/link", common.O_CREAT|common.O_RDONLY, 0666)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when opening link: %s", err)
+	}
+	if stat, err = proc.Fstat(file); err != nil || stat.Inum != 542 {
+		testutils.ErrorHere(test, "Opened wrong file through link: %v %v", stat, err)
+	}
+	proc.Close(file)
+
+	// Unlinking removes the link, not the file
+	for _, path := range []string{"/tmp/link", "/tmp/rel"} {
+		if err = proc.Unlink(path); err != nil {
+			testutils.ErrorHere(test, "Failed when unlinking %s: %s", path, err)
+		}
+	}
+	if _, err = proc.Lstat("/tmp/link"); !errors.Is(err, common.ENOENT) {
+		testutils.ErrorHere(test, "Link still exists after unlink: %v", err)
+	}
+	if _, err = proc.Stat(target); err != nil {
+		testutils.ErrorHere(test, "Target removed with link: %s", err)
+	}
+
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test dangling links, cycles and invalid link targets
+func TestSymlinkErrors(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	proc.Symlink("/tmp/loop2", "/tmp/loop1")
+	proc.Symlink("/tmp/loop1", "/tmp/loop2")
+	proc.Symlink("/nonexistent", "/tmp/dangling")
+
+	if _, err := proc.Stat("/tmp/loop1"); !errors.Is(err, common.ELOOP) {
+		testutils.ErrorHere(test, "Expected ELOOP, got: %v", err)
+	}
+	if _, err := proc.Stat("/tmp/loop1/file"); !errors.Is(err, common.ELOOP) {
+		testutils.ErrorHere(test, "Expected ELOOP in directory component, got: %v", err)
+	}
+	if _, err := proc.Stat("/tmp/dangling"); !errors.Is(err, common.ENOENT) {
+		testutils.ErrorHere(test, "Expected ENOENT for dangling link, got: %v", err)
+	}
+	if _, err := proc.Lstat("/tmp/dangling"); err != nil {
+		testutils.ErrorHere(test, "Failed when calling lstat on dangling link: %s", err)
+	}
+
+	if err := proc.Symlink("/sample", "/tmp/dangling"); !errors.Is(err, common.EEXIST) {
+		testutils.ErrorHere(test, "Expected EEXIST, got: %v", err)
+	}
+	if err := proc.Symlink("", "/tmp/empty"); !errors.Is(err, common.ENOENT) {
+		testutils.ErrorHere(test, "Expected ENOENT for empty target, got: %v", err)
+	}
+	long := make([]byte, 4096)
+	for i := range long {
+		long[i] = 'a'
+	}
+	if err := proc.Symlink(string(long), "/tmp/long"); !errors.Is(err, common.ENAMETOOLONG) {
+		testutils.ErrorHere(test, "Expected ENAMETOOLONG, got: %v", err)
+	}
+	if _, err := proc.Lstat("/tmp/long"); !errors.Is(err, common.ENOENT) {
+		testutils.ErrorHere(test, "Failed link was not removed: %v", err)
+	}
+
+	for _, path := range []string{"/tmp/loop1", "/tmp/loop2", "/tmp/dangling"} {
+		if err := proc.Unlink(path); err != nil {
+			testutils.ErrorHere(test, "Failed when unlinking %s: %s", path, err)
+		}
+	}
+
+	fs.Exit(proc)
+	if err := fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
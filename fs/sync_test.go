@@ -0,0 +1,157 @@
+package fs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/jnwhiteh/minixfs/common"
+	"github.com/jnwhiteh/minixfs/device"
+	"github.com/jnwhiteh/minixfs/testutils"
+	"testing"
+)
+
+// Mount a second copy of the image on /mnt, so we can see what has actually
+// been written to the device rather than what is in the cache.
+func mountImageCopy(test *testing.T, proc *Process) {
+	dev, err := device.NewFileDevice(minixImage(test), binary.LittleEndian)
+	if err != nil {
+		testutils.FatalLevel(test, 2, "Failed when creating new device: %s", err)
+	}
+	if err = proc.Mount(dev, "/mnt", 0); err != nil {
+		testutils.FatalLevel(test, 2, "Failed when mounting: %s", err)
+	}
+}
+
+func readWholeFile(test *testing.T, proc *Process, path string, size int) []byte {
+	file, err := proc.Open(path, common.O_RDONLY, 0)
+	if err != nil {
+		testutils.FatalLevel(test, 2, "Failed when opening %s: %s", path, err)
+	}
+	data := make([]byte, size)
+	n, err := proc.Read(file, data)
+	if err != nil {
+		testutils.FatalLevel(test, 2, "Failed when reading %s: %s", path, err)
+	}
+	proc.Close(file)
+	return data[:n]
+}
+
+// Test that sync writes new files through to the device
+func TestSync(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	data := bytes.Repeat([]byte("sync me\n"), 2000)
+	file, err := proc.Open("/tmp/sync.txt", common.O_CREAT|common.O_TRUNC|common.O_RDWR, 0666)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when creating file: %s", err)
+	}
+	if _, err = proc.Write(file, data); err != nil {
+		testutils.FatalHere(test, "Failed when writing file: %s", err)
+	}
+	if err = proc.Sync(); err != nil {
+		testutils.FatalHere(test, "Failed when syncing: %s", err)
+	}
+
+	mountImageCopy(test, proc)
+	ondisk := readWholeFile(test, proc, "/mnt/tmp/sync.txt", len(data)+1)
+	if bytes.Compare(ondisk, data) != 0 {
+		testutils.ErrorHere(test, "Data on device does not match data written (%d bytes, expected %d)", len(ondisk), len(data))
+	}
+	if err = proc.Unmount("/mnt", 0); err != nil {
+		testutils.ErrorHere(test, "Failed when unmounting: %s", err)
+	}
+
+	proc.Close(file)
+	if err = proc.Unlink("/tmp/sync.txt"); err != nil {
+		testutils.ErrorHere(test, "Failed when unlinking file: %s", err)
+	}
+
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test that syncing a single file writes its data and inode to the device
+func TestFsync(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	// Make sure the directory entry is on the device, fsync won't do that
+	file, err := proc.Open("/tmp/fsync.txt", common.O_CREAT|common.O_TRUNC|common.O_RDWR, 0666)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when creating file: %s", err)
+	}
+	if err = proc.Sync(); err != nil {
+		testutils.FatalHere(test, "Failed when syncing: %s", err)
+	}
+
+	// Write enough data to require an indirect block
+	blocksize := fs.devinfo[common.ROOT_DEVICE].Blocksize
+	data := bytes.Repeat([]byte("fsync"), (blocksize*(common.V2_NR_DZONES+2))/5)
+	if _, err = proc.Write(file, data); err != nil {
+		testutils.FatalHere(test, "Failed when writing file: %s", err)
+	}
+	if err = proc.Fsync(file); err != nil {
+		testutils.FatalHere(test, "Failed when calling fsync: %s", err)
+	}
+
+	mountImageCopy(test, proc)
+	ondisk := readWholeFile(test, proc, "/mnt/tmp/fsync.txt", len(data)+1)
+	if bytes.Compare(ondisk, data) != 0 {
+		testutils.ErrorHere(test, "Data on device does not match data written (%d bytes, expected %d)", len(ondisk), len(data))
+	}
+	if err = proc.Unmount("/mnt", 0); err != nil {
+		testutils.ErrorHere(test, "Failed when unmounting: %s", err)
+	}
+
+	proc.Close(file)
+	if err = proc.Unlink("/tmp/fsync.txt"); err != nil {
+		testutils.ErrorHere(test, "Failed when unlinking file: %s", err)
+	}
+
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test that writes to a file opened with O_SYNC reach the device before the
+// write returns.
+func TestOSync(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	file, err := proc.Open("/tmp/osync.txt", common.O_CREAT|common.O_TRUNC|common.O_RDWR|common.O_SYNC, 0666)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when creating file: %s", err)
+	}
+	if err = proc.Sync(); err != nil {
+		testutils.FatalHere(test, "Failed when syncing: %s", err)
+	}
+
+	blocksize := fs.devinfo[common.ROOT_DEVICE].Blocksize
+	data := bytes.Repeat([]byte("osync"), (blocksize*(common.V2_NR_DZONES+2))/5)
+	half := len(data) / 2
+	for _, chunk := range [][]byte{data[:half], data[half:]} {
+		if _, err = proc.Write(file, chunk); err != nil {
+			testutils.FatalHere(test, "Failed when writing file: %s", err)
+		}
+	}
+
+	mountImageCopy(test, proc)
+	ondisk := readWholeFile(test, proc, "/mnt/tmp/osync.txt", len(data)+1)
+	if bytes.Compare(ondisk, data) != 0 {
+		testutils.ErrorHere(test, "Data on device does not match data written (%d bytes, expected %d)", len(ondisk), len(data))
+	}
+	if err = proc.Unmount("/mnt", 0); err != nil {
+		testutils.ErrorHere(test, "Failed when unmounting: %s", err)
+	}
+
+	proc.Close(file)
+	if err = proc.Unlink("/tmp/osync.txt"); err != nil {
+		testutils.ErrorHere(test, "Failed when unlinking file: %s", err)
+	}
+
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
@@ -5,12 +5,17 @@ import (
 	"github.com/jnwhiteh/minixfs/alloctbl"
 	"github.com/jnwhiteh/minixfs/common"
 	"github.com/jnwhiteh/minixfs/file"
+	"io"
 	"log"
 	"math"
+	"sort"
 	"sync"
 )
 
-func (fs *FileSystem) do_mount(proc *Process, dev common.BlockDevice, path string) error {
+func (fs *FileSystem) do_mount(proc *Process, dev common.BlockDevice, path string, flags int) error {
+	if proc.effuid != common.SU_UID {
+		return common.EPERM // only the super user may mount
+	}
 	if dev == nil {
 		return common.EINVAL
 	}
@@ -45,24 +50,29 @@ func (fs *FileSystem) do_mount(proc *Process, dev common.BlockDevice, path strin
 		return err
 	}
 
-	// Create a new allocation table for this device
-	alloc := alloctbl.NewAllocTbl(devinfo, fs.bcache, freeIndex)
-
-	// Update the device number/alloc table
+	// Update the device number
 	devinfo.Devnum = freeIndex
-	devinfo.AllocTbl = alloc
+	devinfo.Clock = fs.clock
+	devinfo.Flags = flags
+	devinfo.Watches = fs.watches
 
 	// Add the device to the block cache/inode table
 	fs.bcache.MountDevice(freeIndex, dev, devinfo)
 	fs.itable.MountDevice(freeIndex, devinfo)
+
+	// Create a new allocation table for this device, which reads the bit
+	// maps through the block cache
+	devinfo.AllocTbl = alloctbl.NewAllocTbl(devinfo, fs.bcache, freeIndex)
+
 	fs.devices[freeIndex] = dev
 	fs.devinfo[freeIndex] = devinfo
 
 	// Get the inode of the file to be mounted on
-	rip, err := fs.eatPath(fs.procs[common.ROOT_PROCESS], path)
+	rip, err := fs.eatPath(proc, path)
 
 	if err != nil {
 		// Perform lots of cleanup
+		devinfo.AllocTbl.Shutdown()
 		fs.devices[freeIndex] = nil
 		fs.devinfo[freeIndex] = nil
 		fs.bcache.UnmountDevice(freeIndex)
@@ -107,6 +117,7 @@ func (fs *FileSystem) do_mount(proc *Process, dev common.BlockDevice, path strin
 	if r != nil {
 		// TODO: Refactor this error handling code?
 		// Perform lots of cleanup
+		devinfo.AllocTbl.Shutdown()
 		fs.devices[freeIndex] = nil
 		fs.devinfo[freeIndex] = nil
 		fs.bcache.UnmountDevice(freeIndex)
@@ -124,14 +135,20 @@ func (fs *FileSystem) do_mount(proc *Process, dev common.BlockDevice, path strin
 
 	// Store the mountinfo in the device info table for easy mapping
 	devinfo.MountInfo = minfo
+
+	// The device is only seen in the namespace of the process
+	proc.ns.mounts = append(proc.ns.mounts, &mount{rip, root_ip, false})
 	return nil
 }
 
-func (fs *FileSystem) do_unmount(proc *Process, path string) error {
+func (fs *FileSystem) do_unmount(proc *Process, path string, flags int) error {
 	// The filesystem hierarchy cannot change during the processing of
 	// this request. We're going to use a bit of a hack here,
 	// returning the inode and then continuing to use it.
-	rip, err := fs.eatPath(proc, path)
+	if proc.effuid != common.SU_UID {
+		return common.EPERM // only the super user may unmount
+	}
+	rip, via, err := fs.eatPathTrail(proc, nil, path, true)
 	if err != nil {
 		return err
 	}
@@ -139,48 +156,167 @@ func (fs *FileSystem) do_unmount(proc *Process, path string) error {
 	devIndex := rip.Devinfo.Devnum
 	fs.itable.PutInode(rip)
 
+	m, _ := proc.ns.mountOf(rip, via)
+	if m == nil {
+		return common.EINVAL // not a mounted file system
+	}
+
+	// A bind, or a device that is still mounted in another namespace, only
+	// has to be taken out of this one.
+	if m.bind || fs.mountedElsewhere(devIndex, proc.ns) {
+		fs.unbind(proc.ns, m)
+		return fs.release_detached()
+	}
+
+	minfo := rip.Mounted
+
+	// Take away anything that would keep the device busy
+	if flags&common.MNT_FORCE != 0 {
+		fs.revoke(devIndex, minfo.MountPoint)
+	}
+
+	// Detach the device from the hierarchy, leaving the root inode held so
+	// the device stays mounted until it is no longer in use.
+	if flags&common.MNT_DETACH != 0 {
+		fs.unbind(proc.ns, m)
+		fs.detach(minfo)
+		return fs.release_detached()
+	}
+
 	// See if the mounted device is busy. Only one inode using it should be
 	// open, the root inode, and only once.
-
 	if fs.itable.IsDeviceBusy(devIndex) {
 		return common.EBUSY // can't unmount a busy file system
 	}
 
-	if rip.Mounted == nil {
-		return common.EINVAL // not a mounted file system
-	}
+	return fs.release_device(devIndex)
+}
 
-	minfo := rip.Mounted
+// Revoke every use of the device 'devnum' by a process. Open files on the
+// device are closed underneath their descriptors, which fail with EBADF from
+// then on, and binds of directories on the device are removed. A process
+// whose root directory is on the device is left without root and working
+// directories, so that its lookups fail rather than escaping to the rest of
+// the hierarchy. Any other working directory on the device is moved to the
+// inode 'mp' that the device is mounted on.
+func (fs *FileSystem) revoke(devnum int, mp *common.Inode) {
+	fs.unmountAll(devnum)
+	for _, proc := range fs.procs {
+		for _, fd := range proc.files {
+			if fd != nil && fd.inode != nil && fd.inode.Devinfo.Devnum == devnum {
+				if err := fd.revoke(); err != nil {
+					log.Printf("Failed when revoking file in unmount(%v): %s", proc, err)
+				}
+			}
+		}
+		rooted := proc.rootdir != nil && proc.rootdir.Devinfo.Devnum == devnum
+		if rooted {
+			fs.itable.PutInode(proc.rootdir)
+			proc.rootdir = nil
+		}
+		if proc.workdir != nil && proc.workdir.Devinfo.Devnum == devnum {
+			fs.itable.PutInode(proc.workdir)
+			proc.workdir = nil
+			if !rooted {
+				proc.workdir = fs.itable.DupInode(mp)
+			}
+			// Keep the part of the trail that led to the mount point
+			for i, m := range proc.workvia {
+				if m.target.Devinfo.Devnum == devnum {
+					proc.workvia = proc.workvia[:i]
+					break
+				}
+			}
+		}
+	}
+}
 
-	// Clear each inode of the mount info
+// Detach a mounted device from the hierarchy, releasing the inode it is
+// mounted on but leaving the root inode held, so that the device stays
+// mounted until it is no longer in use.
+func (fs *FileSystem) detach(minfo *common.MountInfo) {
 	minfo.MountPoint.Mounted = nil
-	minfo.MountTarget.Mounted = nil
-
-	// Release each inode
 	fs.itable.PutInode(minfo.MountPoint)
+	minfo.MountPoint = nil
+}
+
+// Finish unmounting the device 'devnum', which must no longer be in use:
+// release the inodes linking it into the hierarchy, write back its cache and
+// shut down its servers. If writing back fails the device is still
+// unmounted, but the error is reported.
+func (fs *FileSystem) release_device(devnum int) error {
+	minfo := fs.devinfo[devnum].MountInfo
+	fs.unmountAll(devnum)
+
+	// Clear each inode of the mount info and release it. A detached device
+	// has already given up its mount point.
+	if minfo.MountPoint != nil {
+		minfo.MountPoint.Mounted = nil
+		fs.itable.PutInode(minfo.MountPoint)
+	}
+	minfo.MountTarget.Mounted = nil
 	fs.itable.PutInode(minfo.MountTarget)
 
+	// Any watches on the device come to an end
+	fs.watches.UnmountDevice(devnum)
+
 	// Flush and invalidate the cache for the device
-	fs.bcache.Flush(devIndex)
-	fs.bcache.Invalidate(devIndex)
+	ferr := fs.bcache.Flush(devnum)
+	fs.bcache.Invalidate(devnum)
 
 	// Shut down the allocation table for this device
-	fs.devinfo[devIndex].AllocTbl.Shutdown()
+	fs.devinfo[devnum].AllocTbl.Shutdown()
 
 	// Shut down the device itself
-	fs.devices[devIndex].Close()
+	fs.devices[devnum].Close()
 
-	fs.devices[devIndex] = nil
-	fs.devinfo[devIndex] = nil
-	fs.bcache.UnmountDevice(devIndex)
-	fs.itable.UnmountDevice(devIndex)
+	fs.devices[devnum] = nil
+	fs.devinfo[devnum] = nil
+	fs.bcache.UnmountDevice(devnum)
+	fs.itable.UnmountDevice(devnum)
 
-	return nil
+	return ferr
+}
+
+// Finish the unmount of any detached device that is no longer in use. This
+// is called by each request that can give up the last use of a device, before
+// it replies, and returns the first error from writing back a device.
+func (fs *FileSystem) release_detached() error {
+	var ferr error
+	for i := common.ROOT_DEVICE + 1; i < common.NR_DEVICES; i++ {
+		if fs.devices[i] == nil || fs.devinfo[i].MountInfo.MountPoint != nil {
+			continue
+		}
+		if !fs.itable.IsDeviceBusy(i) {
+			if err := fs.release_device(i); err != nil && ferr == nil {
+				ferr = err
+			}
+		}
+	}
+	return ferr
+}
+
+// Write every dirty inode and block back to its device and ask each mounted
+// device to commit the changes to stable storage.
+func (fs *FileSystem) do_sync() error {
+	fs.itable.FlushAll()
+
+	var ferr error // the first error encountered, if any
+	for i := 0; i < common.NR_DEVICES; i++ {
+		if fs.devices[i] != nil {
+			if err := fs.bcache.Sync(i, nil); err != nil && ferr == nil {
+				ferr = err
+			}
+		}
+	}
+
+	return ferr
 }
 
-func (fs *FileSystem) do_fork(proc *Process) (*Process, error) {
+func (fs *FileSystem) do_fork(proc *Process, newns bool) (*Process, error) {
 	// Fork a process, duplicating the current root/working directories and
-	// all file descriptors.
+	// all file descriptors. The child shares the namespace of the parent, or
+	// is given a copy of it.
 
 	child := new(Process)
 	fs.procs[fs.pidcounter] = child
@@ -188,15 +324,30 @@ func (fs *FileSystem) do_fork(proc *Process) (*Process, error) {
 	child.pid = fs.pidcounter
 	fs.pidcounter++
 	child.umask = proc.umask
+	child.realuid, child.effuid = proc.realuid, proc.effuid
+	child.realgid, child.effgid = proc.realgid, proc.effgid
+	child.groups = append([]int(nil), proc.groups...)
 	child.rootdir = fs.itable.DupInode(proc.rootdir)
 	child.workdir = fs.itable.DupInode(proc.workdir)
+	child.workvia = proc.workvia
 	child.fs = proc.fs
+	if newns {
+		child.ns = fs.copyNamespace(proc.ns)
+	} else {
+		child.ns = proc.ns
+		child.ns.users++
+	}
 
-	child.files = make([]*filp, common.OPEN_MAX)
+	child.files = make([]*filp, len(proc.files))
+	child.openmax = proc.openmax
+	// The child shares each open filp, including its position, so the filp
+	// is only closed once both processes have closed it.
 	for idx, fd := range proc.files {
 		if fd != nil {
+			fd.m.Lock()
+			fd.count++
+			fd.m.Unlock()
 			child.files[idx] = fd
-			fd.file.Dup()
 		}
 	}
 
@@ -208,7 +359,8 @@ func (fs *FileSystem) do_exit(proc *Process) {
 	for i := 0; i < len(proc.files); i++ {
 		fd := proc.files[i]
 		if fd != nil {
-			if err := fd.Close(); err != nil {
+			fd.releaseLocks(proc)
+			if err := fd.close(); err != nil {
 				log.Printf("Failed when closing file in exit(%v): %s", proc, err)
 			}
 		}
@@ -219,11 +371,19 @@ func (fs *FileSystem) do_exit(proc *Process) {
 	fs.itable.PutInode(proc.rootdir)
 	fs.itable.PutInode(proc.workdir)
 	delete(fs.procs, proc.pid)
+	fs.leaveNamespace(proc)
+
+	if err := fs.release_detached(); err != nil {
+		log.Printf("Failed when releasing detached device in exit(%v): %s", proc, err)
+	}
 }
 
-// Attempt to shut down the file system, only return 'true' if the shutdown
-// was successful and the main server loop can exit.
+// Attempt to shut down the file system. EBUSY is returned if the file system
+// is still in use, in which case nothing has been changed. Any other error
+// comes from writing back the cache, and the shutdown has still happened.
 func (fs *FileSystem) do_shutdown() error {
+	var ferr error // the first error encountered while flushing
+
 	// Attempt to unmount each non-root device
 	for i := common.ROOT_DEVICE + 1; i < common.NR_DEVICES; i++ {
 		if fs.devices[i] != nil {
@@ -231,48 +391,35 @@ func (fs *FileSystem) do_shutdown() error {
 				return common.EBUSY
 			}
 
-			minfo := fs.devinfo[i].MountInfo
-
-			// Clear each inode of the mount info
-			minfo.MountPoint.Mounted = nil
-			minfo.MountTarget.Mounted = nil
-
-			// Release each inode
-			fs.itable.PutInode(minfo.MountPoint)
-			fs.itable.PutInode(minfo.MountTarget)
-
-			// Flush and invalidate the cache for the device
-			fs.bcache.Flush(i)
-			fs.bcache.Invalidate(i)
-
-			// Shut down the alloc table for this device
-			fs.devinfo[i].AllocTbl.Shutdown()
-
-			// Shut down the device itself
-			fs.devices[i].Close()
-
-			fs.devices[i] = nil
-			fs.devinfo[i] = nil
-			fs.bcache.UnmountDevice(i)
-			fs.itable.UnmountDevice(i)
+			if err := fs.release_device(i); err != nil && ferr == nil {
+				ferr = err
+			}
 		}
 	}
 
+	// The working directory of the root process does not keep the root
+	// device busy, so its reference is set aside while checking.
+	proc := fs.procs[common.ROOT_PROCESS]
+	if proc != nil {
+		fs.itable.PutInode(proc.workdir)
+	}
+
 	// Now try to unmount the root device
 	if fs.itable.IsDeviceBusy(common.ROOT_DEVICE) {
+		if proc != nil {
+			fs.itable.DupInode(proc.workdir)
+		}
 		// Cannot unmount this device, so we need to fail
 		return common.EBUSY
 	} else {
-		// Release the inodes for the root process
-		proc := fs.procs[common.ROOT_PROCESS]
+		// Release the root directory of the root process
 		if proc != nil { // if it hasn't been shut down already
-			if proc.rootdir != proc.workdir && proc.workdir != nil {
-				fs.itable.PutInode(proc.workdir)
-			}
 			fs.itable.PutInode(proc.rootdir)
 		}
 
-		fs.bcache.Flush(common.ROOT_DEVICE)
+		if err := fs.bcache.Flush(common.ROOT_DEVICE); err != nil && ferr == nil {
+			ferr = err
+		}
 		fs.bcache.Invalidate(common.ROOT_DEVICE)
 
 		fs.devinfo[common.ROOT_DEVICE].AllocTbl.Shutdown()
@@ -291,22 +438,92 @@ func (fs *FileSystem) do_shutdown() error {
 	if err := fs.itable.Shutdown(); err != nil {
 		panic(fmt.Sprintf("Failed to shut down block cache: %s", err))
 	}
+	fs.watches.Shutdown()
 
-	return nil
+	return ferr
+}
+
+// Describe each mounted device, sorted by the path of the mount point. A
+// device therefore always follows the device it is mounted on, so they can be
+// unmounted in reverse order.
+func (fs *FileSystem) do_mounts() ([]common.MountStat, error) {
+	var mounts []common.MountStat
+	for i := common.ROOT_DEVICE; i < common.NR_DEVICES; i++ {
+		devinfo := fs.devinfo[i]
+		if fs.devices[i] == nil || devinfo == nil {
+			continue
+		}
+		if i != common.ROOT_DEVICE && devinfo.MountInfo.MountPoint == nil {
+			continue // detached, waiting to be released
+		}
+
+		path := "/"
+		if i != common.ROOT_DEVICE {
+			var err error
+			if path, err = fs.dirPath(nil, nil, devinfo.MountInfo.MountPoint, nil); err != nil {
+				return nil, err
+			}
+		}
+
+		inodes, zones := devinfo.AllocTbl.FreeCount()
+		mounts = append(mounts, common.MountStat{
+			Devnum:     i,
+			Path:       path,
+			Flags:      devinfo.Flags,
+			Blocksize:  devinfo.Blocksize,
+			FreeInodes: inodes,
+			FreeZones:  zones,
+		})
+	}
+
+	sort.Slice(mounts, func(i, j int) bool {
+		return mounts[i].Path < mounts[j].Path
+	})
+	return mounts, nil
 }
 
 func (fs *FileSystem) do_chdir(proc *Process, path string) error {
-	rip, err := fs.eatPath(proc, path)
+	return fs.change(proc, &proc.workdir, &proc.workvia, path)
+}
+
+// Change the root directory of the process, which only the superuser may do.
+// The working directory is left where it is.
+func (fs *FileSystem) do_chroot(proc *Process, path string) error {
+	if proc.effuid != common.SU_UID {
+		return common.EPERM
+	}
+	return fs.change(proc, &proc.rootdir, nil, path)
+}
+
+func (fs *FileSystem) do_fchdir(proc *Process, fd int) error {
+	filp, err := proc.getFilp(fd)
+	if err != nil {
+		return err
+	}
+	return fs.changeInto(proc, &proc.workdir, &proc.workvia, fs.itable.DupInode(filp.inode), filp.trail)
+}
+
+// Change the directory held in 'iip' to the one named by 'path', keeping the
+// trail it was reached along in 'tp' if that is not nil.
+func (fs *FileSystem) change(proc *Process, iip **common.Inode, tp *trail, path string) error {
+	rip, via, err := fs.eatPathTrail(proc, nil, path, true)
 	if err != nil {
 		return err
 	}
+	return fs.changeInto(proc, iip, tp, rip, via)
+}
 
+// Change the directory held in 'iip' to 'rip', reached along 'via', which
+// must be a searchable directory. The reference to 'rip' is taken over, and
+// released on error.
+func (fs *FileSystem) changeInto(proc *Process, iip **common.Inode, tp *trail, rip *common.Inode, via trail) error {
 	var r error
 
 	if !rip.IsDirectory() {
 		r = common.ENOTDIR
+	} else {
+		r = forbidden(proc, rip, common.X_BIT)
 	}
-	// TODO: Check permissions
 
 	// If error then return inode
 	if r != nil {
@@ -315,9 +532,177 @@ func (fs *FileSystem) do_chdir(proc *Process, path string) error {
 	}
 
 	// Everything is okay, make the change
-	fs.itable.PutInode(proc.workdir)
-	proc.workdir = rip
-	return nil
+	fs.itable.PutInode(*iip)
+	*iip = rip
+	if tp != nil {
+		*tp = via
+	}
+	return fs.release_detached()
+}
+
+// Return the path of the working directory, as seen from the root directory
+// of the process.
+func (fs *FileSystem) do_getcwd(proc *Process) (string, error) {
+	if proc.rootdir == nil || proc.workdir == nil {
+		return "", common.ENOENT // revoked by a forced unmount
+	}
+	return fs.dirPath(proc.ns, proc.rootdir, proc.workdir, proc.workvia)
+}
+
+func (fs *FileSystem) do_stat(proc *Process, start *filp, path string) (*common.StatInfo, error) {
+	rip, err := fs.eatPathFrom(proc, start, path, true)
+	if err != nil {
+		return nil, err
+	}
+
+	stat := common.Stat(rip)
+	fs.itable.PutInode(rip)
+	return stat, nil
+}
+
+// Watch the file or directory 'path' for the events in 'mask', which the
+// process must be able to read. The events are delivered on the returned
+// channel, which is closed when the watch is cancelled or ends because the
+// file was deleted or its device unmounted.
+func (fs *FileSystem) do_watch(proc *Process, path string, mask int) (<-chan common.Event, func(), error) {
+	if mask&common.IN_ALL == 0 {
+		return nil, nil, common.EINVAL
+	}
+
+	rip, err := fs.eatPath(proc, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = forbidden(proc, rip, common.R_BIT); err != nil {
+		fs.itable.PutInode(rip)
+		return nil, nil, err
+	}
+
+	watches := fs.watches
+	id, events := watches.Watch(rip.Devinfo.Devnum, rip.Inum, mask&common.IN_ALL)
+	fs.itable.PutInode(rip)
+	return events, func() { watches.Cancel(id) }, nil
+}
+
+// Describe the file system containing 'path'. The free counts are kept by
+// the allocation table, so this does not need to scan the bit maps.
+func (fs *FileSystem) do_statfs(proc *Process, path string) (*common.StatfsInfo, error) {
+	rip, err := fs.eatPath(proc, path)
+	if err != nil {
+		return nil, err
+	}
+
+	devinfo := rip.Devinfo
+	fs.itable.PutInode(rip)
+
+	inodes, zones := devinfo.AllocTbl.FreeCount()
+	return &common.StatfsInfo{
+		Blocksize:  devinfo.Blocksize,
+		Zones:      devinfo.Zones - devinfo.Firstdatazone,
+		FreeZones:  zones,
+		Inodes:     devinfo.Inodes,
+		FreeInodes: inodes,
+	}, nil
+}
+
+// Read all of the entries of a directory, along with their file types
+func (fs *FileSystem) do_readdir(proc *Process, path string) ([]common.Dirent, error) {
+	rip, err := fs.eatPath(proc, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []common.Dirent
+	if err = forbidden(proc, rip, common.R_BIT); err == nil {
+		entries, _, err = common.ReadDir(rip, 0, 0, true)
+	}
+	fs.itable.PutInode(rip)
+	return entries, err
+}
+
+// Read up to 'count' entries from an open directory, continuing from where
+// the last call left off. The file types are left out, so a large directory
+// can be listed without fetching every inode in it. Directories are only changed from within the file
+// system server, so they are read here too, rather than by the file server.
+func (fs *FileSystem) do_getdents(fi *filp, count int) ([]common.Dirent, error) {
+	fi.m.Lock()
+	defer fi.m.Unlock()
+
+	if fi.file == nil {
+		return nil, common.EBADF
+	}
+
+	entries, pos, err := common.ReadDir(fi.inode, fi.pos, count, false)
+	fi.pos = pos
+
+	if err == nil && count > 0 && len(entries) == 0 {
+		err = io.EOF
+	}
+	return entries, err
+}
+
+func (fs *FileSystem) do_lstat(proc *Process, start *filp, path string) (*common.StatInfo, error) {
+	rip, err := fs.eatPathFrom(proc, start, path, false)
+	if err != nil {
+		return nil, err
+	}
+
+	stat := common.Stat(rip)
+	fs.itable.PutInode(rip)
+	return stat, nil
+}
+
+func (fs *FileSystem) do_symlink(proc *Process, target, path string) error {
+	if len(target) == 0 {
+		return common.ENOENT
+	}
+
+	bits := uint16(common.I_SYMBOLIC_LINK | common.RWX_MODES)
+	dirp, rip, rest, err := fs.new_node(proc, nil, path, bits, common.NO_ZONE)
+	if err != nil {
+		fs.itable.PutInode(rip)
+		fs.itable.PutInode(dirp)
+		return err
+	}
+
+	// The target is stored as the contents of the link, and must fit within
+	// a single block.
+	if len(target) >= rip.Devinfo.Blocksize {
+		err = common.ENAMETOOLONG
+	} else {
+		_, err = common.Write(rip, []byte(target), 0)
+		rip.Size = int32(len(target)) // Write only extends regular files
+	}
+
+	if err != nil {
+		// Remove the link we just created, freeing the inode
+		Unlink(dirp, rest)
+		rip.Nlinks--
+		rip.Dirty = true
+	} else {
+		dirp.Notify(common.IN_CREATE, rest)
+	}
+
+	fs.itable.PutInode(rip)
+	fs.itable.PutInode(dirp)
+	return err
+}
+
+func (fs *FileSystem) do_readlink(proc *Process, start *filp, path string) (string, error) {
+	rip, err := fs.eatPathFrom(proc, start, path, false)
+	if err != nil {
+		return "", err
+	}
+
+	var target string
+	if rip.Type() != common.I_SYMBOLIC_LINK {
+		err = common.EINVAL
+	} else {
+		target, err = readLink(rip)
+	}
+
+	fs.itable.PutInode(rip)
+	return target, err
 }
 
 var mode_map = []uint16{
@@ -326,112 +711,205 @@ var mode_map = []uint16{
 	common.R_BIT | common.W_BIT,
 	0}
 
-func (fs *FileSystem) do_open(proc *Process, path string, oflags int, omode uint16) (common.Fd, error) {
+func (fs *FileSystem) do_open(proc *Process, start *filp, path string, oflags int, omode uint16) (int, error) {
 	// Remap the bottom two bits of oflags
 	bits := mode_map[oflags&common.O_ACCMODE]
 
 	var err error
 	var rip *common.Inode
+	var via trail // the mounts crossed to reach an existing directory
 	var exist bool = false
 
 	// If O_CREATE is set, try to make the file
 	if oflags&common.O_CREAT > 0 {
 		// Create a new node by calling new_node()
-		omode := common.I_REGULAR | (omode & common.ALL_MODES & proc.umask)
-		dirp, newrip, _, err := fs.new_node(proc, path, omode, common.NO_ZONE)
+		omode := common.I_REGULAR | (omode & common.ALL_MODES &^ proc.umask)
+		dirp, newrip, name, err := fs.new_node(proc, start, path, omode, common.NO_ZONE)
 		if err == nil {
-			exist = false
-		} else if err != common.EEXIST {
-			return nil, err
-		} else {
-			exist = (oflags&common.O_EXCL == 0)
+			dirp.Notify(common.IN_CREATE, name)
+		} else if err == common.EEXIST {
+			if oflags&common.O_EXCL != 0 {
+				fs.itable.PutInode(newrip)
+				fs.itable.PutInode(dirp)
+				return -1, err
+			}
+			// Open the existing file, following it if it is a link
+			exist = true
+			loops := 0
+			newrip, _, err = fs.followLink(proc, dirp, nil, newrip, nil, &loops)
 		}
 
 		// we don't need the parent directory
 		fs.itable.PutInode(dirp)
+		if err != nil {
+			return -1, err
+		}
 		rip = newrip
 	} else {
 		// grab the inode at the given path
-		rip, err = fs.eatPath(proc, path)
+		rip, via, err = fs.eatPathTrail(proc, start, path, true)
 		if err != nil {
-			return nil, err
+			return -1, err
 		}
+		exist = true
 	}
 
 	// Find an available filp entry for the file descriptor
-	fdindex := -1
-	for i := 0; i < len(proc.files); i++ {
-		if proc.files[i] == nil {
-			fdindex = i
-			break
-		}
-	}
-
-	if fdindex == -1 {
-		return nil, common.EMFILE
+	fdindex, err := proc.getFd(0)
+	if err != nil {
+		fs.itable.PutInode(rip)
+		return -1, err
 	}
 
 	err = nil // we'll use this to set error codes
 
 	if exist { // if the file existed already
-		// TODO: Check permissions here
+		err = forbidden(proc, rip, bits)
+	}
+
+	if exist && err == nil {
 		switch rip.Type() {
 		case common.I_REGULAR:
 			if oflags&common.O_TRUNC > 0 {
-				common.Truncate(rip, 0, fs.bcache)
-				// Flush the inode so it gets written on next block cache
-				fs.itable.FlushInode(rip)
+				if err = forbidden(proc, rip, common.W_BIT); err == nil {
+					err = common.Truncate(rip, 0, fs.bcache)
+				}
+				if err == nil {
+					// Flush the inode so it gets written on next block cache
+					fs.itable.FlushInode(rip)
+					rip.Notify(common.IN_MODIFY, "")
+				}
 			}
 		case common.I_DIRECTORY:
-			// Directories cannot be opened in this system
-			err = common.EISDIR
+			// Directories can only be opened for reading
+			if bits&common.W_BIT != 0 {
+				err = common.EISDIR
+			}
 		default:
-			panic("NYI: Process.Open with non regular/directory")
+			// There are no drivers or pipes behind special files
+			err = common.ENXIO
 		}
 	}
 
 	if err != nil {
 		// Something went wrong, so release the inode
 		fs.itable.PutInode(rip)
-		return nil, err
+		return -1, err
 	}
 
 	// Make sure there is a 'File' server running
 	if rip.File == nil {
 		// Spawn a file process to handle reading/writing
 		rip.File = file.NewFile(rip)
+	} else {
+		// The file server is already running, so register another client
+		rip.File.Dup()
 	}
 
 	// Create a new 'filp' object to expose to the user
-	filp := &filp{1, 0, rip.File, rip, bits, new(sync.Mutex)}
+	flags := oflags & (common.O_APPEND | common.O_SYNC)
+	filp := &filp{1, 0, rip.File, rip, proc, path, via, bits, flags, new(sync.Mutex)}
 	proc.files[fdindex] = filp
 
-	return filp, nil
+	return fdindex, nil
 }
 
-func (fs *FileSystem) do_close(proc *Process, fd common.Fd) error {
-	filp, ok := fd.(*filp)
-	if !ok {
+func (fs *FileSystem) do_close(proc *Process, fd int) error {
+	filp := proc.fdFilp(fd)
+	if filp == nil {
 		return common.EBADF
 	}
 
-	// Find this entry in the process table
-	for i := 0; i < len(proc.files); i++ {
-		if proc.files[i] == filp {
-			// This is actually a valid file descriptor
-			err := filp.Close()
-			proc.files[i] = nil
-			return err
+	filp.releaseLocks(proc)
+	proc.files[fd] = nil
+	err := filp.close()
+	if rerr := fs.release_detached(); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// Close 'fd' only if it still refers to the open file 'filp'
+func (fs *FileSystem) do_closefile(proc *Process, fd int, filp *filp) error {
+	if proc.fdFilp(fd) != filp {
+		return common.EBADF
+	}
+	return fs.do_close(proc, fd)
+}
+
+// Make 'fd' refer to the same open file as the descriptor 'filp', sharing
+// its position and status flags in the same way as after a fork.
+func (proc *Process) installFd(fd int, filp *filp) {
+	filp.m.Lock()
+	filp.count++
+	filp.m.Unlock()
+	proc.files[fd] = filp
+}
+
+func (fs *FileSystem) do_dup(proc *Process, fd int) (int, error) {
+	return fs.do_fcntl(proc, fd, common.F_DUPFD, 0)
+}
+
+// Duplicate 'oldfd' as 'newfd', closing whatever 'newfd' referred to.
+func (fs *FileSystem) do_dup2(proc *Process, oldfd, newfd int) (int, error) {
+	filp := proc.fdFilp(oldfd)
+	if filp == nil || newfd < 0 || newfd >= proc.openmax {
+		return -1, common.EBADF
+	}
+	if oldfd == newfd {
+		return newfd, nil
+	}
+
+	for newfd >= len(proc.files) {
+		proc.files = append(proc.files, nil)
+	}
+	if proc.files[newfd] != nil {
+		// As with close, errors here are not reported
+		fs.do_close(proc, newfd)
+	}
+	proc.installFd(newfd, filp)
+	return newfd, nil
+}
+
+func (fs *FileSystem) do_fcntl(proc *Process, fd, cmd, arg int) (int, error) {
+	filp := proc.fdFilp(fd)
+	if filp == nil {
+		return -1, common.EBADF
+	}
+
+	switch cmd {
+	case common.F_DUPFD:
+		// Duplicate onto the lowest free descriptor that is at least 'arg'
+		if arg < 0 || arg >= proc.openmax {
+			return -1, common.EINVAL
 		}
+		newfd, err := proc.getFd(arg)
+		if err != nil {
+			return -1, err
+		}
+		proc.installFd(newfd, filp)
+		return newfd, nil
 	}
+	return -1, common.EINVAL
+}
 
-	// If we get here, it was not a valid file descriptor
-	return common.EBADF
+// Change the number of descriptors the process may have open. Anyone may
+// set a limit up to OPEN_MAX, only the super user may go beyond it.
+// Descriptors that are already open above a lowered limit stay open.
+func (fs *FileSystem) do_setopenmax(proc *Process, max int) error {
+	if max < 1 {
+		return common.EINVAL
+	}
+	if max > common.OPEN_MAX && max > proc.openmax && proc.effuid != common.SU_UID {
+		return common.EPERM
+	}
+	proc.openmax = max
+	return nil
 }
 
-func (fs *FileSystem) do_unlink(proc *Process, path string) error {
+func (fs *FileSystem) do_unlink(proc *Process, start *filp, path string) error {
 	// Get the inodes we need to perform the unlink
-	dirp, rip, filename, err := fs.unlink_prep(proc, path)
+	dirp, rip, filename, err := fs.unlink_prep(proc, start, path)
 	if err != nil {
 		return err
 	} else if dirp == nil || rip == nil {
@@ -443,11 +921,21 @@ func (fs *FileSystem) do_unlink(proc *Process, path string) error {
 		err = common.EBUSY
 	}
 
+	// Only the super user may unlink directories, as with link
+	if err == nil && rip.IsDirectory() && proc.effuid != common.SU_UID {
+		err = common.EPERM
+	}
+
 	if err == nil {
 		// Perform the unlink
 		err = Unlink(dirp, filename)
+	}
+	if err == nil {
 		rip.Nlinks--
+		rip.Update |= common.CTIME
 		rip.Dirty = true
+		dirp.Notify(common.IN_DELETE, filename)
+		unlinked(rip)
 	}
 
 	// Regardless, return both inodes
@@ -468,10 +956,14 @@ func (fs *FileSystem) do_link(proc *Process, oldpath, newpath string) error {
 		return common.EMLINK
 	}
 
-	// TODO: only root user can link to directories
+	// Only the super user may link to directories
+	if rip.IsDirectory() && proc.effuid != common.SU_UID {
+		fs.itable.PutInode(rip)
+		return common.EPERM
+	}
 
 	// Grab the new parent directory
-	dirp, rest, err := fs.lastDir(proc, newpath)
+	dirp, rest, err := fs.lastDir(proc, nil, newpath)
 	if err != nil {
 		fs.itable.PutInode(rip)
 		return err
@@ -485,6 +977,8 @@ func (fs *FileSystem) do_link(proc *Process, oldpath, newpath string) error {
 		// The target already exists
 		fs.itable.PutInode(newrip)
 		r = common.EEXIST
+	} else if err != common.ENOENT {
+		r = err
 	}
 
 	// Check for links across devices
@@ -492,12 +986,21 @@ func (fs *FileSystem) do_link(proc *Process, oldpath, newpath string) error {
 		r = common.EXDEV
 	}
 
+	if r == nil {
+		r = forbidden(proc, dirp, common.W_BIT|common.X_BIT)
+	}
+
 	// Perform the link operation
-	r = Link(dirp, rest, rip.Inum)
+	if r == nil {
+		r = Link(dirp, rest, rip.Inum)
+	}
 
 	if r == nil { // everything was successful, register the linking
 		rip.Nlinks++
+		rip.Update |= common.CTIME
 		rip.Dirty = true
+		dirp.Notify(common.IN_CREATE, rest)
+		rip.Notify(common.IN_ATTRIB, "")
 	}
 
 	// Done, release both inodes
@@ -506,10 +1009,178 @@ func (fs *FileSystem) do_link(proc *Process, oldpath, newpath string) error {
 	return r
 }
 
-func (fs *FileSystem) do_mkdir(proc *Process, path string, mode uint16) error {
+// Rename a file or directory, replacing the target if it already exists. A
+// directory that is moved to a new parent has its '..' entry rewritten.
+func (fs *FileSystem) do_rename(proc *Process, oldstart *filp, oldpath string, newstart *filp, newpath string) error {
+	// Get the old parent directory and the inode being renamed
+	old_dirp, old_ip, old_last, err := fs.unlink_prep(proc, oldstart, oldpath)
+	if err != nil {
+		return err
+	} else if old_dirp == nil || old_ip == nil {
+		return common.ENOENT
+	}
+
+	var r error = nil // to help with cleanup
+
+	odir := old_ip.IsDirectory() // true iff renaming a directory
+
+	// Get the new parent directory and the target, which may not exist
+	new_dirp, new_last, err := fs.lastDir(proc, newstart, newpath)
+	if err != nil {
+		fs.itable.PutInode(old_ip)
+		fs.itable.PutInode(old_dirp)
+		return err
+	}
+	new_ip, err := fs.advance(proc, new_dirp, new_last)
+	if err != nil && err != common.ENOENT {
+		r = err
+	}
+
+	// Renaming '.' or '..' makes no sense
+	if !isFileName(old_last) || !isFileName(new_last) {
+		r = common.EINVAL
+	}
+
+	// Renames may not cross devices
+	if r == nil && old_dirp.Devinfo.Devnum != new_dirp.Devinfo.Devnum {
+		r = common.EXDEV
+	}
+
+	// The new parent must be writable, and an existing target in a sticky
+	// directory must be ours to replace. A directory moving to a new parent
+	// must also be writable so its '..' entry can be changed.
+	if r == nil {
+		r = forbidden(proc, new_dirp, common.W_BIT|common.X_BIT)
+	}
+	if r == nil && new_ip != nil {
+		r = sticky(proc, new_dirp, new_ip)
+	}
+	if r == nil && odir && new_dirp != old_dirp {
+		r = forbidden(proc, old_ip, common.W_BIT)
+	}
+
+	// A directory may not be moved into its own subtree. Walk up from the new
+	// parent using '..' until we find either the root of the device or
+	// 'old_ip'. The entries are read directly, since the caller does not
+	// need search permission on the directories in between.
+	if r == nil && odir && new_dirp != old_dirp {
+		sdirp := fs.itable.DupInode(new_dirp)
+		for sdirp != old_ip && sdirp.Inum != common.ROOT_INODE {
+			ok, dnum, inum := Lookup(sdirp, "..")
+			if !ok || inum == sdirp.Inum {
+				r = common.EINVAL // missing '..' entry, assume the worst
+				break
+			}
+			next, err := fs.itable.GetInode(dnum, inum)
+			if err != nil {
+				r = err
+				break
+			}
+			fs.itable.PutInode(sdirp)
+			sdirp = next
+		}
+		if sdirp == old_ip {
+			r = common.EINVAL
+		}
+		fs.itable.PutInode(sdirp)
+	}
+
+	// Renaming a file to itself is allowed, but does nothing
+	same := (new_ip == old_ip)
+
+	if r == nil && new_ip != nil && !same {
+		switch {
+		case new_ip.Inum == common.ROOT_INODE || proc.ns.covers(new_dirp, new_last):
+			r = common.EBUSY // can't replace a mount point
+		case odir && !new_ip.IsDirectory():
+			r = common.ENOTDIR
+		case !odir && new_ip.IsDirectory():
+			r = common.EISDIR
+		case new_ip.IsDirectory() && !IsEmpty(new_ip):
+			r = common.ENOTEMPTY
+		case new_ip.IsDirectory() && new_ip.Count > 1:
+			r = common.EBUSY // same restriction as rmdir
+		}
+	}
+
+	// A directory moving to a new parent adds a link to that parent
+	if r == nil && odir && new_dirp != old_dirp && new_dirp.Nlinks >= math.MaxUint16 {
+		r = common.EMLINK
+	}
+
+	if r == nil && !same {
+		// An existing target's entry is pointed at the renamed inode, so the
+		// target is only lost once the new name is in place. Otherwise enter
+		// the new name before deleting the old one, unless they are in the
+		// same directory, where the old slot can be reused.
+		if new_ip != nil {
+			if r = Relink(new_dirp, new_last, old_ip.Inum); r == nil {
+				r = Unlink(old_dirp, old_last)
+			}
+		} else if old_dirp == new_dirp {
+			if r = Unlink(old_dirp, old_last); r == nil {
+				r = Link(new_dirp, new_last, old_ip.Inum)
+			}
+		} else {
+			if r = Link(new_dirp, new_last, old_ip.Inum); r == nil {
+				r = Unlink(old_dirp, old_last)
+			}
+		}
+
+		// The replaced target has lost its name, and a directory its '.' and
+		// '..' entries too
+		if r == nil && new_ip != nil {
+			new_ip.Nlinks--
+			if new_ip.IsDirectory() {
+				if r = Unlink(new_ip, ".."); r == nil {
+					new_dirp.Nlinks--
+					new_dirp.Dirty = true
+					if r = Unlink(new_ip, "."); r == nil {
+						new_ip.Nlinks--
+					}
+				}
+			}
+			new_ip.Update |= common.CTIME
+			new_ip.Dirty = true
+		}
+	}
+
+	if r == nil && !same {
+		old_ip.Update |= common.CTIME
+		old_ip.Dirty = true
+
+		event := common.Event{Mask: common.IN_RENAME, Name: new_last, OldName: old_last}
+		fs.watches.Notify(old_dirp.Devinfo.Devnum, old_dirp.Inum, event)
+		if new_dirp != old_dirp {
+			fs.watches.Notify(new_dirp.Devinfo.Devnum, new_dirp.Inum, event)
+		}
+		if new_ip != nil {
+			unlinked(new_ip)
+		}
+	}
+
+	// Update the '..' entry in a directory that has moved to a new parent
+	if r == nil && !same && odir && new_dirp != old_dirp {
+		if r = Relink(old_ip, "..", new_dirp.Inum); r == nil {
+			old_dirp.Nlinks--
+			old_dirp.Dirty = true
+			new_dirp.Nlinks++
+			new_dirp.Dirty = true
+		}
+	}
+
+	// Release all of the inodes we have acquired
+	fs.itable.PutInode(old_ip)
+	fs.itable.PutInode(old_dirp)
+	fs.itable.PutInode(new_ip)
+	fs.itable.PutInode(new_dirp)
+	return r
+}
+
+func (fs *FileSystem) do_mkdir(proc *Process, start *filp, path string, mode uint16) error {
 	// Create the new inode. If that fails, return err
-	bits := common.I_DIRECTORY | (mode & common.RWX_MODES & proc.umask)
-	dirp, rip, rest, err := fs.new_node(proc, path, bits, 0)
+	bits := common.I_DIRECTORY | (mode & common.RWX_MODES &^ proc.umask)
+	dirp, rip, rest, err := fs.new_node(proc, start, path, bits, 0)
 	if rip == nil || err == common.EEXIST {
 		fs.itable.PutInode(rip)  // can't make dir: it already exists
 		fs.itable.PutInode(dirp) // return parent too
@@ -532,6 +1203,7 @@ func (fs *FileSystem) do_mkdir(proc *Process, path string, mode uint16) error {
 		rip.Nlinks++  // this accounts for .
 		dirp.Nlinks++ // this accounts for ..
 		dirp.Dirty = true
+		dirp.Notify(common.IN_CREATE, rest)
 	} else {
 		// It did not work, so remove the new directory
 		Unlink(dirp, rest)
@@ -552,35 +1224,35 @@ func (fs *FileSystem) do_mkdir(proc *Process, path string, mode uint16) error {
 }
 
 // Remove a directory from the file system.
-func (fs *FileSystem) do_rmdir(proc *Process, path string) error {
+func (fs *FileSystem) do_rmdir(proc *Process, start *filp, path string) error {
 	// Get parent/inode and filename
-	dirp, rip, filename, err := fs.unlink_prep(proc, path)
+	dirp, rip, filename, err := fs.unlink_prep(proc, start, path)
 	if err != nil {
 		return err
 	}
 
-	// Check to see if the directory is empty
-	if !IsEmpty(rip) {
-		return common.ENOTEMPTY
-	}
-
-	if path == "." || path == ".." {
-		return common.EINVAL
-	}
 	if rip.Inum == common.ROOT_INODE { // can't remove root
-		return common.EBUSY
-	}
-
-	// Make sure no one else is using this directory. This is a stronger
-	// condition than given in Minix initially, where it just cannot be the
-	// root or working directory of a process. Could be relaxed, this is just
-	// for sanity.
-	if rip.Count > 1 {
-		return common.EBUSY
+		err = common.EBUSY
+	} else if !rip.IsDirectory() {
+		err = common.ENOTDIR
+	} else if !IsEmpty(rip) { // check to see if the directory is empty
+		err = common.ENOTEMPTY
+	} else if !isFileName(filename) {
+		err = common.EINVAL
+	} else if rip.Count > 1 {
+		// Make sure no one else is using this directory. This is a stronger
+		// condition than given in Minix initially, where it just cannot be
+		// the root or working directory of a process. Could be relaxed, this
+		// is just for sanity.
+		err = common.EBUSY
+	} else {
+		// Actually try to unlink from the parent
+		err = Unlink(dirp, filename)
 	}
 
-	// Actually try to unlink from the parent
-	if err = Unlink(dirp, filename); err != nil {
+	if err != nil {
+		fs.itable.PutInode(rip)
+		fs.itable.PutInode(dirp)
 		return err
 	}
 	rip.Nlinks--
@@ -593,6 +1265,9 @@ func (fs *FileSystem) do_rmdir(proc *Process, path string) error {
 	rip.Nlinks--
 	dirp.Nlinks--
 
+	dirp.Notify(common.IN_DELETE, filename)
+	unlinked(rip)
+
 	// If the unlink was possible it has been done, otherwise it has not
 	// If unlink was possible, it has been done. Otherwise it has not
 	fs.itable.PutInode(rip)
@@ -0,0 +1,91 @@
+package fs
+
+import (
+	"github.com/jnwhiteh/minixfs/common"
+	"github.com/jnwhiteh/minixfs/testutils"
+	"testing"
+	"time"
+)
+
+// A clock that only moves when told to
+type testClock struct {
+	now int64
+}
+
+func (c *testClock) Now() time.Time {
+	return time.Unix(c.now, 0)
+}
+
+func checkTimes(test *testing.T, proc *Process, path string, atime, mtime, ctime int32) {
+	stat, err := proc.Stat(path)
+	if err != nil {
+		testutils.FatalLevel(test, 2, "Failed when calling stat: %s", err)
+	}
+	if stat.Atime != atime || stat.Mtime != mtime || stat.Ctime != ctime {
+		testutils.ErrorLevel(test, 2, "%s times mismatch expected %d/%d/%d, got %d/%d/%d", path,
+			atime, mtime, ctime, stat.Atime, stat.Mtime, stat.Ctime)
+	}
+}
+
+// Test that inode times are stamped from the clock as files change
+func TestTimes(test *testing.T) {
+	clock := &testClock{1000}
+	fs, proc := OpenMinixImageClock(test, clock)
+
+	// Creating a file sets all of its times, and changes the parent
+	proc.Mkdir("/tmp/times", 0777)
+	clock.now = 2000
+	file, err := proc.Open("/tmp/times/file", common.O_CREAT|common.O_RDWR, 0666)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when creating file: %s", err)
+	}
+	checkTimes(test, proc, "/tmp/times/file", 2000, 2000, 2000)
+	checkTimes(test, proc, "/tmp/times", 1000, 2000, 2000)
+
+	// Writing changes the modification and change times
+	clock.now = 3000
+	proc.Write(file, []byte("hello"))
+	checkTimes(test, proc, "/tmp/times/file", 2000, 3000, 3000)
+
+	// Reading changes only the access time
+	clock.now = 4000
+	proc.Seek(file, 0, 0)
+	proc.Read(file, make([]byte, 5))
+	checkTimes(test, proc, "/tmp/times/file", 4000, 3000, 3000)
+
+	// Changing the inode changes only the change time
+	clock.now = 5000
+	proc.Chmod("/tmp/times/file", 0600)
+	checkTimes(test, proc, "/tmp/times/file", 4000, 3000, 5000)
+	clock.now = 6000
+	proc.Link("/tmp/times/file", "/tmp/times/link")
+	checkTimes(test, proc, "/tmp/times/file", 4000, 3000, 6000)
+
+	// Explicit times are not overwritten by pending updates
+	clock.now = 7000
+	proc.Write(file, []byte("world"))
+	proc.Utime("/tmp/times/file", 100, 200)
+	checkTimes(test, proc, "/tmp/times/file", 100, 200, 7000)
+	proc.Close(file)
+
+	// The times are written back to the disk with the inode
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+
+	clock.now = 8000
+	fs, proc = OpenMinixImageClock(test, clock)
+	checkTimes(test, proc, "/tmp/times/link", 100, 200, 7000)
+
+	proc.Unlink("/tmp/times/file")
+	proc.Unlink("/tmp/times/link")
+	if err = proc.Rmdir("/tmp/times"); err != nil {
+		testutils.ErrorHere(test, "Failed when removing directory: %s", err)
+	}
+
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
@@ -1,13 +1,21 @@
 package fs
 
 import (
+	"fmt"
 	"github.com/jnwhiteh/minixfs/common"
+	"io"
 	"math"
+	"strings"
 )
 
-func (fs *FileSystem) new_node(proc *Process, path string, bits uint16, z0 uint) (*common.Inode, *common.Inode, string, error) {
+func (fs *FileSystem) new_node(proc *Process, start *filp, path string, bits uint16, z0 uint) (*common.Inode, *common.Inode, string, error) {
+	// A trailing slash can only name a directory
+	if strings.HasSuffix(path, "/") && bits&common.I_TYPE != common.I_DIRECTORY {
+		return nil, nil, "", common.EISDIR
+	}
+
 	// Open the parent directory
-	dirp, rlast, err := fs.lastDir(proc, path)
+	dirp, rlast, err := fs.lastDir(proc, start, path)
 	if err != nil {
 		return nil, nil, "", err
 	}
@@ -20,8 +28,18 @@ func (fs *FileSystem) new_node(proc *Process, path string, bits uint16, z0 uint)
 	// Does the new entry already exist?
 	rip, err := fs.advance(proc, dirp, rlast)
 	if rip != nil || err == nil {
-		// Must exist or something is wrong..
-		return nil, nil, "", common.EEXIST
+		// Must exist or something is wrong, return the existing entry so
+		// the caller can decide what to do with it
+		return dirp, rip, rlast, common.EEXIST
+	} else if err != common.ENOENT {
+		fs.itable.PutInode(dirp)
+		return nil, nil, "", err
+	}
+
+	// The entry does not exist, so we must be able to write the directory
+	if err = forbidden(proc, dirp, common.W_BIT|common.X_BIT); err != nil {
+		fs.itable.PutInode(dirp)
+		return nil, nil, "", err
 	}
 
 	// The file/directory does not exist, create it
@@ -38,10 +56,17 @@ func (fs *FileSystem) new_node(proc *Process, path string, bits uint16, z0 uint)
 		return nil, nil, "", err
 	}
 	rip.Mode = bits
-	rip.Zone[0] = uint32(z0)
 	rip.Size = 0
-	// TODO: Add uid/gid here
+
+	// Clear any zone numbers left on disk by the inode's previous use
+	for i := 0; i < len(rip.Zone); i++ {
+		rip.Zone[i] = common.NO_ZONE
+	}
+	rip.Zone[0] = uint32(z0)
+	rip.Uid = int16(proc.effuid)
+	rip.Gid = uint16(proc.effgid)
 	rip.Nlinks++
+	rip.Update = common.ATIME | common.CTIME | common.MTIME
 
 	// Force the inode to disk before making a directory entry to make the
 	// system more robust in the face of a crash: an inode with no
@@ -66,9 +91,9 @@ func (fs *FileSystem) new_node(proc *Process, path string, bits uint16, z0 uint)
 // the inode of the final entry itself. In addition, return the portion of the
 // path that is the filename of the final entry, so it can be removed from the
 // parent directory, and any error that may have occurred.
-func (fs *FileSystem) unlink_prep(proc *Process, path string) (*common.Inode, *common.Inode, string, error) {
+func (fs *FileSystem) unlink_prep(proc *Process, start *filp, path string) (*common.Inode, *common.Inode, string, error) {
 	// Get the last directory in the path
-	dirp, rest, err := fs.lastDir(proc, path)
+	dirp, rest, err := fs.lastDir(proc, start, path)
 	if dirp == nil {
 		return nil, nil, "", err
 	}
@@ -80,12 +105,110 @@ func (fs *FileSystem) unlink_prep(proc *Process, path string) (*common.Inode, *c
 		return nil, nil, "", err
 	}
 
-	// Do not remove a mount point
-	if rip.Inum == common.ROOT_INODE {
+	// Do not remove a mount point, before or after crossing it
+	if rip.Inum == common.ROOT_INODE || proc.ns.covers(dirp, rest) {
+		err = common.EBUSY
+	} else if strings.HasSuffix(path, "/") && rip.Type() != common.I_DIRECTORY {
+		err = common.ENOTDIR
+	} else if err = forbidden(proc, dirp, common.W_BIT|common.X_BIT); err == nil {
+		err = sticky(proc, dirp, rip)
+	}
+
+	if err != nil {
 		fs.itable.PutInode(dirp)
 		fs.itable.PutInode(rip)
-		return nil, nil, "", common.EBUSY
+		return nil, nil, "", err
 	}
 
 	return dirp, rip, rest, nil
 }
+
+// Tell anything watching 'rip', which has just lost a link, that either its
+// link count has changed or it has been deleted.
+func unlinked(rip *common.Inode) {
+	if rip.Nlinks == 0 {
+		rip.Notify(common.IN_DELETE, "")
+	} else {
+		rip.Notify(common.IN_ATTRIB, "")
+	}
+}
+
+// Returns true if 'name' can be used as the name of a new directory entry,
+// i.e. it is not empty and is neither '.' or '..'.
+func isFileName(name string) bool {
+	return name != "" && name != "." && name != ".."
+}
+
+// Return the filp for the file descriptor 'fd' in the given process, or nil
+// if it is not open.
+func (proc *Process) fdFilp(fd int) *filp {
+	if fd < 0 || fd >= len(proc.files) {
+		return nil
+	}
+	return proc.files[fd]
+}
+
+// Return the filp for a file descriptor that is open in the given process,
+// and has not been revoked.
+func (proc *Process) getFilp(fd int) (*filp, error) {
+	filp := proc.fdFilp(fd)
+	if filp == nil || filp.inode == nil {
+		return nil, common.EBADF
+	}
+	return filp, nil
+}
+
+// Return the lowest file descriptor that is not open in the given process
+// and is no lower than 'start', growing the table of descriptors up to the
+// limit of the process if needed.
+func (proc *Process) getFd(start int) (int, error) {
+	for fd := start; fd < proc.openmax; fd++ {
+		if fd == len(proc.files) {
+			proc.files = append(proc.files, nil)
+		}
+		if proc.files[fd] == nil {
+			return fd, nil
+		}
+	}
+	return -1, common.EMFILE
+}
+
+// Wrap an error returned by a system call with the name of the call and the
+// path it was made on. A nil error is returned unchanged.
+func pathError(op, path string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &common.PathError{Op: op, Path: path, Err: err}
+}
+
+// As pathError, for the system calls that take two paths
+func linkError(op, oldpath, newpath string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &common.LinkError{Op: op, Old: oldpath, New: newpath, Err: err}
+}
+
+// As pathError, for calls on a file descriptor, which are reported using the
+// path the file was opened with, or the descriptor number if it is not open.
+// Like a nil error, io.EOF is returned unchanged so it can be compared
+// against.
+func fdError(op string, fd int, filp *filp, err error) error {
+	if err == nil || err == io.EOF {
+		return err
+	}
+	name := fmt.Sprintf("fd %d", fd)
+	if filp != nil {
+		name = filp.name
+	}
+	return &common.PathError{Op: op, Path: name, Err: err}
+}
+
+// As pathError, for the system calls that do not involve a path
+func syscallError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &common.SyscallError{Syscall: op, Err: err}
+}
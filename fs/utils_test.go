@@ -1,6 +1,9 @@
 package fs
 
 import (
+	"encoding/binary"
+	"github.com/jnwhiteh/minixfs/common"
+	"github.com/jnwhiteh/minixfs/device"
 	"github.com/jnwhiteh/minixfs/testutils"
 	"os"
 	"path"
@@ -15,9 +18,45 @@ func getExtraFilename(filename string) string {
 	return path.Join(dir, filename)
 }
 
+// Copies of the test image, by the name of the test that made them
+var minixImages = make(map[string]string)
+
+// Return the filename of a copy of the test image private to the given
+// test, so tests that write to it leave the original untouched. Every call
+// made by the same test returns the same copy.
+func minixImage(test *testing.T) string {
+	if filename, ok := minixImages[test.Name()]; ok {
+		return filename
+	}
+	data, err := os.ReadFile(getExtraFilename("minix3root.img"))
+	if err != nil {
+		testutils.FatalLevel(test, 2, "Failed reading test image: %s", err)
+	}
+	filename := path.Join(test.TempDir(), "minix3root.img")
+	if err := os.WriteFile(filename, data, 0666); err != nil {
+		testutils.FatalLevel(test, 2, "Failed copying test image: %s", err)
+	}
+	minixImages[test.Name()] = filename
+	test.Cleanup(func() { delete(minixImages, test.Name()) })
+	return filename
+}
+
 func OpenMinixImage(test *testing.T) (*FileSystem, *Process) {
-	imageFilename := getExtraFilename("minix3root.img")
-	fs, proc, err := OpenFileSystemFile(imageFilename)
+	imageFilename := minixImage(test)
+	fs, proc, err := OpenFileSystemFile(imageFilename, 0)
+	if err != nil {
+		testutils.FatalHere(test, "Failed opening file system: %s", err)
+	}
+	return fs, proc
+}
+
+// Open the test image with inode times taken from the given clock
+func OpenMinixImageClock(test *testing.T, clock common.Clock) (*FileSystem, *Process) {
+	dev, err := device.NewFileDevice(minixImage(test), binary.LittleEndian)
+	if err != nil {
+		testutils.FatalHere(test, "Failed opening device: %s", err)
+	}
+	fs, proc, err := NewFileSystem(dev, clock, 0)
 	if err != nil {
 		testutils.FatalHere(test, "Failed opening file system: %s", err)
 	}
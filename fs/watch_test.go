@@ -0,0 +1,166 @@
+package fs
+
+import (
+	"errors"
+	"github.com/jnwhiteh/minixfs/common"
+	"github.com/jnwhiteh/minixfs/testutils"
+	"testing"
+)
+
+func watchPath(test *testing.T, proc *Process, path string, mask int) (<-chan common.Event, func()) {
+	events, cancel, err := proc.Watch(path, mask)
+	if err != nil {
+		testutils.FatalLevel(test, 2, "Failed when watching %s: %s", path, err)
+	}
+	return events, cancel
+}
+
+// Check that exactly the given events are waiting on a watch. Events are
+// queued before the call that caused them returns, so there is no need to
+// wait for them.
+func expectEvents(test *testing.T, events <-chan common.Event, expected ...common.Event) {
+	for _, e := range expected {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				testutils.ErrorLevel(test, 2, "Watch closed, expected %+v", e)
+				return
+			} else if event != e {
+				testutils.ErrorLevel(test, 2, "Expected event %+v, got %+v", e, event)
+			}
+		default:
+			testutils.ErrorLevel(test, 2, "Expected event %+v, got nothing", e)
+		}
+	}
+	select {
+	case event, ok := <-events:
+		if ok {
+			testutils.ErrorLevel(test, 2, "Unexpected event %+v", event)
+		}
+	default:
+	}
+}
+
+// Check that a watch has ended, after any events that are still waiting
+func expectClosed(test *testing.T, events <-chan common.Event) {
+	select {
+	case _, ok := <-events:
+		if ok {
+			testutils.ErrorLevel(test, 2, "Expected watch to be closed")
+		}
+	default:
+		testutils.ErrorLevel(test, 2, "Watch has not been closed")
+	}
+}
+
+// Test the events reported for a directory and a file within it
+func TestWatch(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	proc.Mkdir("/tmp/watch", 0755)
+	dir, cancel := watchPath(test, proc, "/tmp/watch", common.IN_ALL)
+
+	createFile(test, proc, "/tmp/watch/a", "")
+	expectEvents(test, dir, common.Event{Mask: common.IN_CREATE, Name: "a"})
+
+	file, _ := watchPath(test, proc, "/tmp/watch/a", common.IN_ALL)
+	fd, _ := proc.Open("/tmp/watch/a", common.O_RDWR, 0)
+	proc.Write(fd, []byte("data"))
+	proc.Ftruncate(fd, 2)
+	proc.Read(fd, make([]byte, 10))
+	proc.Close(fd)
+	proc.Chmod("/tmp/watch/a", 0600)
+	expectEvents(test, file,
+		common.Event{Mask: common.IN_MODIFY},
+		common.Event{Mask: common.IN_MODIFY},
+		common.Event{Mask: common.IN_ATTRIB})
+
+	proc.Rename("/tmp/watch/a", "/tmp/watch/b")
+	proc.Link("/tmp/watch/b", "/tmp/watch/c")
+	proc.Unlink("/tmp/watch/c")
+	expectEvents(test, dir,
+		common.Event{Mask: common.IN_RENAME, Name: "b", OldName: "a"},
+		common.Event{Mask: common.IN_CREATE, Name: "c"},
+		common.Event{Mask: common.IN_DELETE, Name: "c"})
+	expectEvents(test, file, common.Event{Mask: common.IN_ATTRIB}, common.Event{Mask: common.IN_ATTRIB})
+
+	// Deleting the last link ends the watch on the file
+	proc.Unlink("/tmp/watch/b")
+	expectEvents(test, dir, common.Event{Mask: common.IN_DELETE, Name: "b"})
+	expectEvents(test, file, common.Event{Mask: common.IN_DELETE})
+	expectClosed(test, file)
+
+	proc.Mkdir("/tmp/watch/sub", 0755)
+	proc.Symlink("sub", "/tmp/watch/link")
+	proc.Unlink("/tmp/watch/link")
+	proc.Rmdir("/tmp/watch/sub")
+	expectEvents(test, dir,
+		common.Event{Mask: common.IN_CREATE, Name: "sub"},
+		common.Event{Mask: common.IN_CREATE, Name: "link"},
+		common.Event{Mask: common.IN_DELETE, Name: "link"},
+		common.Event{Mask: common.IN_DELETE, Name: "sub"})
+
+	cancel()
+	expectClosed(test, dir)
+	cancel() // cancelling again does nothing
+
+	proc.Rmdir("/tmp/watch")
+	fs.Exit(proc)
+	if err := fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test that only the events asked for are reported, that moving a file
+// between directories is seen by both, and that permission is needed.
+func TestWatchMask(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	proc.Mkdir("/tmp/watch1", 0755)
+	proc.Mkdir("/tmp/watch2", 0700)
+	events1, cancel1 := watchPath(test, proc, "/tmp/watch1", common.IN_RENAME)
+	events2, cancel2 := watchPath(test, proc, "/tmp/watch2", common.IN_ALL)
+
+	createFile(test, proc, "/tmp/watch1/file", "data")
+	proc.Rename("/tmp/watch1/file", "/tmp/watch2/moved")
+	rename := common.Event{Mask: common.IN_RENAME, Name: "moved", OldName: "file"}
+	expectEvents(test, events1, rename)
+	expectEvents(test, events2, rename)
+
+	child := forkAs(test, proc, 1, 1)
+	if _, _, err := child.Watch("/tmp/watch2", common.IN_ALL); !errors.Is(err, common.EACCES) {
+		testutils.ErrorHere(test, "Expected EACCES, got: %v", err)
+	}
+	if _, _, err := proc.Watch("/tmp/watch2", 0); !errors.Is(err, common.EINVAL) {
+		testutils.ErrorHere(test, "Expected EINVAL, got: %v", err)
+	}
+
+	cancel1()
+	cancel2()
+	proc.Unlink("/tmp/watch2/moved")
+	proc.Rmdir("/tmp/watch1")
+	proc.Rmdir("/tmp/watch2")
+	fs.Exit(child)
+	fs.Exit(proc)
+	if err := fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test that unmounting a device ends the watches on it
+func TestWatchUnmount(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+	mountMirror(test, fs, proc)
+
+	events, _ := watchPath(test, proc, "/mnt/sample", common.IN_CREATE)
+	if err := proc.Unmount("/mnt", 0); err != nil {
+		testutils.FatalHere(test, "Failed when unmounting: %s", err)
+	}
+	expectEvents(test, events, common.Event{Mask: common.IN_UNMOUNT})
+	expectClosed(test, events)
+
+	fs.Exit(proc)
+	if err := fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
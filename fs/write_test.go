@@ -2,10 +2,13 @@ package fs
 
 import (
 	"bytes"
+	"errors"
 	"github.com/jnwhiteh/minixfs/common"
 	"github.com/jnwhiteh/minixfs/testutils"
+	"io"
 	"io/ioutil"
 	"os"
+	"sync"
 	"testing"
 )
 
@@ -32,7 +35,7 @@ func TestWrite(test *testing.T) {
 
 	// Open the two files that will be written to
 	gfile, err := fs.Open(proc, "/tmp/europarl-en.txt", common.O_CREAT|common.O_TRUNC|common.O_RDWR, 0666)
-	if err != nil || gfile == nil {
+	if err != nil {
 		testutils.FatalHere(test, "Could not open file on guest: %s", err)
 	}
 	hfile, err := os.OpenFile("/tmp/europarl-en.txt", os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0666)
@@ -53,7 +56,7 @@ func TestWrite(test *testing.T) {
 		}
 		data := filedata[pos:endpos]
 
-		gn, gerr := gfile.Write(data)
+		gn, gerr := proc.Write(gfile, data)
 		hn, herr := hfile.Write(data)
 
 		if gn != hn {
@@ -78,9 +81,9 @@ func TestWrite(test *testing.T) {
 	}
 
 	// Seek to beginning of file
-	gfile.Seek(0, 0)
+	proc.Seek(gfile, 0, 0)
 	written := make([]byte, filesize)
-	n, err := gfile.Read(written)
+	n, err := proc.Read(gfile, written)
 	if n != filesize {
 		testutils.ErrorHere(test, "Verify count mismatch expected %d, got %d", filesize, n)
 	}
@@ -108,3 +111,299 @@ func TestWrite(test *testing.T) {
 		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
 	}
 }
+
+// Test that data written through a process is written back to the device when
+// the file system is shut down, and can be read back after re-opening it.
+func TestWritePersists(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	data := []byte("The quick brown fox jumps over the lazy dog\n")
+	data = bytes.Repeat(data, 1000)
+
+	file, err := proc.Open("/tmp/persist.txt", common.O_CREAT|common.O_TRUNC|common.O_RDWR, 0666)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when creating file: %s", err)
+	}
+	if n, err := proc.Write(file, data); n != len(data) || err != nil {
+		testutils.FatalHere(test, "Failed when writing file (%d bytes written): %s", n, err)
+	}
+	if err = proc.Close(file); err != nil {
+		testutils.FatalHere(test, "Failed when closing file: %s", err)
+	}
+
+	proc.Exit()
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+
+	// Re-open the image and read the data back
+	fs, proc = OpenMinixImage(test)
+
+	file, err = proc.Open("/tmp/persist.txt", common.O_RDONLY, 0666)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when opening file after re-open: %s", err)
+	}
+	written := make([]byte, len(data))
+	if n, err := proc.Read(file, written); n != len(data) || err != nil {
+		testutils.ErrorHere(test, "Failed when reading file (%d bytes read): %s", n, err)
+	}
+	if bytes.Compare(data, written) != 0 {
+		testutils.ErrorHere(test, "Data read after re-open does not match data written")
+	}
+	if err = proc.Close(file); err != nil {
+		testutils.ErrorHere(test, "Failed when closing file: %s", err)
+	}
+
+	if err = proc.Unlink("/tmp/persist.txt"); err != nil {
+		testutils.ErrorHere(test, "Failed when unlinking file: %s", err)
+	}
+
+	proc.Exit()
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test that reads and writes are only allowed on descriptors opened for them
+func TestAccessModes(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	rdonly, err := proc.Open("/sample/europarl-en.txt", common.O_RDONLY, 0)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when opening file: %s", err)
+	}
+	if _, err = proc.Write(rdonly, []byte("x")); !errors.Is(err, common.EBADF) {
+		testutils.ErrorHere(test, "Expected EBADF writing read-only file, got: %v", err)
+	}
+	if err = proc.Ftruncate(rdonly, 0); !errors.Is(err, common.EBADF) {
+		testutils.ErrorHere(test, "Expected EBADF truncating read-only file, got: %v", err)
+	}
+	proc.Close(rdonly)
+
+	wronly, err := proc.Open("/tmp/wronly", common.O_CREAT|common.O_WRONLY, 0666)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when creating file: %s", err)
+	}
+	if _, err = proc.Write(wronly, []byte("x")); err != nil {
+		testutils.ErrorHere(test, "Failed when writing file: %s", err)
+	}
+	proc.Seek(wronly, 0, common.SEEK_SET)
+	if _, err = proc.Read(wronly, make([]byte, 1)); !errors.Is(err, common.EBADF) {
+		testutils.ErrorHere(test, "Expected EBADF reading write-only file, got: %v", err)
+	}
+	proc.Close(wronly)
+	proc.Unlink("/tmp/wronly")
+
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test that appending writes always go to the end of the file, whether the
+// descriptor is shared between processes or opened separately.
+func TestAppend(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	path := "/tmp/append.log"
+	createFile(test, proc, path, "start\n")
+
+	shared, err := proc.Open(path, common.O_WRONLY|common.O_APPEND, 0)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when opening file: %s", err)
+	}
+	child, _ := proc.Fork()
+
+	// Moving the position has no effect on where the data goes
+	proc.Seek(shared, 0, common.SEEK_SET)
+	if _, err = proc.Write(shared, []byte("parent\n")); err != nil {
+		testutils.ErrorHere(test, "Failed when appending: %s", err)
+	}
+	if pos, _ := proc.Seek(shared, 0, common.SEEK_CUR); pos != 13 {
+		testutils.ErrorHere(test, "Position after append mismatch expected %d, got %d", 13, pos)
+	}
+
+	// Concurrent appends through separate descriptors must not overwrite
+	// each other.
+	procs := []*Process{proc, child}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		file, err := procs[i%2].Open(path, common.O_WRONLY|common.O_APPEND, 0)
+		if err != nil {
+			testutils.FatalHere(test, "Failed when opening file: %s", err)
+		}
+		wg.Add(1)
+		go func(proc *Process, file int) {
+			for j := 0; j < 50; j++ {
+				proc.Write(file, []byte("line\n"))
+			}
+			wg.Done()
+		}(procs[i%2], file)
+	}
+	wg.Wait()
+
+	// The shared descriptor is still usable after the child exits
+	fs.Exit(child)
+	if _, err = proc.Write(shared, []byte("end\n")); err != nil {
+		testutils.ErrorHere(test, "Failed when appending after exit: %s", err)
+	}
+
+	stat, _ := proc.Stat(path)
+	if expected := int32(13 + 4*50*5 + 4); stat.Size != expected {
+		testutils.ErrorHere(test, "Size after appends mismatch expected %d, got %d", expected, stat.Size)
+	}
+
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+
+	fs, proc = OpenMinixImage(test)
+	if err = proc.Unlink(path); err != nil {
+		testutils.ErrorHere(test, "Failed when unlinking file: %s", err)
+	}
+	fs.Exit(proc)
+	fs.Shutdown()
+}
+
+// Test positional writes, and copying into a file through the io interfaces
+func TestWriteAt(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	path := "/tmp/writeat"
+	file, err := proc.Open(path, common.O_CREAT|common.O_RDWR, 0666)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when creating file: %s", err)
+	}
+
+	fd, _ := proc.File(file)
+	n, err := io.Copy(fd, OpenEuroparl(test))
+	if n != 4489799 || err != nil {
+		testutils.ErrorHere(test, "Copy wrote %d bytes (%v)", n, err)
+	}
+
+	if _, err = proc.Pwrite(file, []byte("HELLO"), 10); err != nil {
+		testutils.ErrorHere(test, "Failed when writing at offset: %s", err)
+	}
+	if pos, _ := proc.Seek(file, 0, common.SEEK_CUR); pos != n {
+		testutils.ErrorHere(test, "Position changed by WriteAt: %d", pos)
+	}
+	if _, err = proc.Pwrite(file, []byte("x"), -1); !errors.Is(err, common.EINVAL) {
+		testutils.ErrorHere(test, "Expected EINVAL for negative offset, got: %v", err)
+	}
+
+	data := make([]byte, 7)
+	proc.Pread(file, data, 9)
+	if string(data[1:6]) != "HELLO" {
+		testutils.ErrorHere(test, "Data after WriteAt mismatch: %q", data)
+	}
+	proc.Close(file)
+
+	// Positional writes make no sense when appending
+	appender, _ := proc.Open(path, common.O_WRONLY|common.O_APPEND, 0)
+	if _, err = proc.Pwrite(appender, []byte("x"), 0); !errors.Is(err, common.EINVAL) {
+		testutils.ErrorHere(test, "Expected EINVAL for WriteAt on O_APPEND, got: %v", err)
+	}
+	proc.Close(appender)
+
+	if err = proc.Unlink(path); err != nil {
+		testutils.ErrorHere(test, "Failed when unlinking file: %s", err)
+	}
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test that truncating a file frees the zones beyond its new end, and that
+// growing it again leaves zeros rather than the old data.
+func TestTruncate(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	before, _ := proc.Statfs("/tmp")
+	data := bytes.Repeat([]byte("0123456789"), 1300) // four zones
+	file, err := proc.Open("/tmp/truncate.txt", common.O_CREAT|common.O_RDWR, 0666)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when creating file: %s", err)
+	}
+	proc.Write(file, data)
+
+	if err = proc.Ftruncate(file, 5000); err != nil {
+		testutils.FatalHere(test, "Failed when truncating: %s", err)
+	}
+	if stat, _ := proc.Fstat(file); stat.Size != 5000 {
+		testutils.ErrorHere(test, "Size after truncate got %d, expected 5000", stat.Size)
+	}
+	if during, _ := proc.Statfs("/tmp"); during.FreeZones != before.FreeZones-2 {
+		testutils.ErrorHere(test, "Free zones got %d, expected %d", during.FreeZones, before.FreeZones-2)
+	}
+
+	if err = proc.Ftruncate(file, 6000); err != nil {
+		testutils.FatalHere(test, "Failed when growing: %s", err)
+	}
+	buf := make([]byte, 6000)
+	if n, err := proc.Pread(file, buf, 0); n != 6000 || err != nil {
+		testutils.FatalHere(test, "Failed when reading: %d, %v", n, err)
+	}
+	if !bytes.Equal(buf[:5000], data[:5000]) || !bytes.Equal(buf[5000:], make([]byte, 1000)) {
+		testutils.ErrorHere(test, "Data after truncate and grow does not match")
+	}
+
+	// Truncating to nothing and removing the file returns everything
+	proc.Ftruncate(file, 0)
+	proc.Close(file)
+	proc.Unlink("/tmp/truncate.txt")
+	if after, _ := proc.Statfs("/tmp"); *after != *before {
+		testutils.ErrorHere(test, "Free counts went from %+v to %+v", before, after)
+	}
+
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
+
+// Test that truncating within the range of the double indirect block frees
+// the single indirect blocks below it that no longer map anything.
+func TestTruncateDoubleIndirect(test *testing.T) {
+	fs, proc := OpenMinixImage(test)
+
+	// The first zone mapped through the double indirect block, with 4096
+	// byte zones, and the number of zones each single indirect maps.
+	first, per := common.V2_NR_DZONES+1024, 1024
+
+	before, _ := proc.Statfs("/tmp")
+	file, err := proc.Open("/tmp/sparse.txt", common.O_CREAT|common.O_RDWR, 0666)
+	if err != nil {
+		testutils.FatalHere(test, "Failed when creating file: %s", err)
+	}
+	proc.Pwrite(file, []byte("a"), int64(first*4096))
+	proc.Pwrite(file, []byte("b"), int64((first+2*per)*4096))
+	if during, _ := proc.Statfs("/tmp"); during.FreeZones != before.FreeZones-5 {
+		testutils.FatalHere(test, "Free zones got %d, expected %d", during.FreeZones, before.FreeZones-5)
+	}
+
+	// Only the data zone and single indirect of the second write go
+	if err = proc.Ftruncate(file, (first+1)*4096); err != nil {
+		testutils.FatalHere(test, "Failed when truncating: %s", err)
+	}
+	if during, _ := proc.Statfs("/tmp"); during.FreeZones != before.FreeZones-3 {
+		testutils.ErrorHere(test, "Free zones got %d, expected %d", during.FreeZones, before.FreeZones-3)
+	}
+	buf := make([]byte, 1)
+	if n, err := proc.Pread(file, buf, int64(first*4096)); n != 1 || buf[0] != 'a' {
+		testutils.ErrorHere(test, "Reading kept data got %q (%v)", buf[:n], err)
+	}
+
+	proc.Ftruncate(file, 0)
+	proc.Close(file)
+	proc.Unlink("/tmp/sparse.txt")
+	if after, _ := proc.Statfs("/tmp"); *after != *before {
+		testutils.ErrorHere(test, "Free counts went from %+v to %+v", before, after)
+	}
+
+	fs.Exit(proc)
+	if err = fs.Shutdown(); err != nil {
+		testutils.FatalHere(test, "Failed when shutting down filesystem: %s", err)
+	}
+}
@@ -37,6 +37,8 @@ type req_InodeTbl_FlushInode struct {
 	inode *common.Inode
 }
 type res_InodeTbl_FlushInode struct{}
+type req_InodeTbl_FlushAll struct{}
+type res_InodeTbl_FlushAll struct{}
 type req_InodeTbl_IsDeviceBusy struct {
 	devnum int
 }
@@ -71,6 +73,8 @@ func (r req_InodeTbl_PutInode) is_reqInodeTbl()      {}
 func (r res_InodeTbl_PutInode) is_resInodeTbl()      {}
 func (r req_InodeTbl_FlushInode) is_reqInodeTbl()    {}
 func (r res_InodeTbl_FlushInode) is_resInodeTbl()    {}
+func (r req_InodeTbl_FlushAll) is_reqInodeTbl()      {}
+func (r res_InodeTbl_FlushAll) is_resInodeTbl()      {}
 func (r req_InodeTbl_IsDeviceBusy) is_reqInodeTbl()  {}
 func (r res_InodeTbl_IsDeviceBusy) is_resInodeTbl()  {}
 func (r req_InodeTbl_Shutdown) is_reqInodeTbl()      {}
@@ -90,6 +94,8 @@ var _ reqInodeTbl = req_InodeTbl_PutInode{}
 var _ resInodeTbl = res_InodeTbl_PutInode{}
 var _ reqInodeTbl = req_InodeTbl_FlushInode{}
 var _ resInodeTbl = res_InodeTbl_FlushInode{}
+var _ reqInodeTbl = req_InodeTbl_FlushAll{}
+var _ resInodeTbl = res_InodeTbl_FlushAll{}
 var _ reqInodeTbl = req_InodeTbl_IsDeviceBusy{}
 var _ resInodeTbl = res_InodeTbl_IsDeviceBusy{}
 var _ reqInodeTbl = req_InodeTbl_Shutdown{}
@@ -127,6 +133,11 @@ func (s *server_InodeTbl) FlushInode(inode *common.Inode) {
 	<-s.out
 	return
 }
+func (s *server_InodeTbl) FlushAll() {
+	s.in <- req_InodeTbl_FlushAll{}
+	<-s.out
+	return
+}
 func (s *server_InodeTbl) IsDeviceBusy(devnum int) bool {
 	s.in <- req_InodeTbl_IsDeviceBusy{devnum}
 	result := (<-s.out).(res_InodeTbl_IsDeviceBusy)
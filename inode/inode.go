@@ -129,7 +129,9 @@ func (itable *server_InodeTbl) loop() {
 		case req_InodeTbl_DupInode:
 			// Given an inode, duplicate it by incrementing its count
 			rip := req.inode
-			rip.Count++
+			if rip != nil {
+				rip.Count++
+			}
 			itable.out <- res_InodeTbl_DupInode{rip}
 		case req_InodeTbl_PutInode:
 			rip := req.inode
@@ -165,12 +167,19 @@ func (itable *server_InodeTbl) loop() {
 		case req_InodeTbl_FlushInode:
 			rip := req.inode
 
-			if rip == nil {
-				itable.out <- res_InodeTbl_FlushInode{}
-			} else {
+			if rip != nil {
 				itable.writeInode(rip)
 			}
 			itable.out <- res_InodeTbl_FlushInode{}
+		case req_InodeTbl_FlushAll:
+			// Write out every dirty inode that is currently in use
+			for i := 0; i < len(itable.slots); i++ {
+				rip := itable.slots[i].inode
+				if rip.Count > 0 && rip.Dirty {
+					itable.writeInode(rip)
+				}
+			}
+			itable.out <- res_InodeTbl_FlushAll{}
 		case req_InodeTbl_IsDeviceBusy:
 			count := 0
 			for i := 0; i < len(itable.slots); i++ {
@@ -217,13 +226,8 @@ func (c *server_InodeTbl) loadInode(xp *common.Inode) {
 	// The count at this point is guaranteed to be > 0, so the device cannot
 	// be unmounted until the load has completed and the inode has been 'put'
 
-	inum := xp.Inum - 1
-
 	info := xp.Devinfo
-
-	inodes_per_block := info.Blocksize / common.V2_INODE_SIZE
-	ioffset := inum % inodes_per_block
-	blocknum := info.MapOffset + (inum / inodes_per_block)
+	blocknum, ioffset := info.InodeBlock(xp.Inum)
 
 	// Load the inode from the disk and create an in-memory version of it
 	bp := c.bcache.GetBlock(info.Devnum, blocknum, common.INODE_BLOCK, common.NORMAL)
@@ -233,22 +237,29 @@ func (c *server_InodeTbl) loadInode(xp *common.Inode) {
 	inode_d := &inodeb[ioffset]
 	xp.Disk_Inode = inode_d
 	xp.Dirty = false
+	xp.Update = 0
 	xp.Mounted = nil
+	xp.File = nil
 }
 
 func (c *server_InodeTbl) writeInode(xp *common.Inode) {
-	// Calculate the block number we need
-	inum := xp.Inum - 1
+	// Nothing can be written to a read-only file system, and any changes that
+	// were made in memory (such as the access time) are simply dropped.
 	info := xp.Devinfo
-	inodes_per_block := info.Blocksize / common.V2_INODE_SIZE
-	ioffset := inum % inodes_per_block
-	block_num := info.MapOffset + (inum / inodes_per_block)
+	if info.ReadOnly() {
+		xp.Update = 0
+		xp.Dirty = false
+		return
+	}
+
+	// Calculate the block number we need
+	block_num, ioffset := info.InodeBlock(xp.Inum)
 
 	// Load the inode from the disk
 	bp := c.bcache.GetBlock(info.Devnum, block_num, common.INODE_BLOCK, common.NORMAL)
 	inodeb := bp.Block.(common.InodeBlock)
 
-	// TODO: Update times, handle read-only filesystems
+	xp.UpdateTimes()
 	bp.Dirty = true
 
 	// Copy the disk_inode from rip into the inode block
@@ -56,3 +56,21 @@ func (dev *BlockingDevice) Read(buf interface{}, pos int64) error {
 //func (dev *BlockingDevice) Close() error {
 //	return dev.BlockDevice.Close()
 //}
+
+//////////////////////////////////////////////////////////////////////////////
+// A device that fails every write with a given error, while still allowing
+// blocks to be read from the underlying device.
+//////////////////////////////////////////////////////////////////////////////
+
+type FailingDevice struct {
+	common.BlockDevice
+	Err error
+}
+
+func NewFailingDevice(rdev common.BlockDevice, err error) *FailingDevice {
+	return &FailingDevice{rdev, err}
+}
+
+func (dev *FailingDevice) Write(buf interface{}, pos int64) error {
+	return dev.Err
+}
@@ -21,3 +21,9 @@ func FatalHere(test *testing.T, str string, args ...interface{}) {
 	info := fmt.Sprintf("[%s:%d] ", file, line)
 	test.Fatalf(info+str, args...)
 }
+
+func FatalLevel(test *testing.T, level int, str string, args ...interface{}) {
+	_, file, line, _ := runtime.Caller(level)
+	info := fmt.Sprintf("[%s:%d] ", file, line)
+	test.Fatalf(info+str, args...)
+}
@@ -0,0 +1,87 @@
+package watch
+
+import (
+	"github.com/jnwhiteh/minixfs/common"
+)
+
+type req_WatchTbl_Watch struct {
+	devnum int
+	inum   int
+	mask   int
+}
+type res_WatchTbl_Watch struct {
+	Arg0 int
+	Arg1 <-chan common.Event
+}
+type req_WatchTbl_Cancel struct {
+	id int
+}
+type res_WatchTbl_Cancel struct{}
+type req_WatchTbl_Notify struct {
+	devnum int
+	inum   int
+	event  common.Event
+}
+type res_WatchTbl_Notify struct{}
+type req_WatchTbl_UnmountDevice struct {
+	devnum int
+}
+type res_WatchTbl_UnmountDevice struct{}
+type req_WatchTbl_Shutdown struct{}
+type res_WatchTbl_Shutdown struct {
+	Arg0 error
+}
+
+// Interface types and implementations
+type reqWatchTbl interface {
+	is_reqWatchTbl()
+}
+type resWatchTbl interface {
+	is_resWatchTbl()
+}
+
+func (r req_WatchTbl_Watch) is_reqWatchTbl()         {}
+func (r res_WatchTbl_Watch) is_resWatchTbl()         {}
+func (r req_WatchTbl_Cancel) is_reqWatchTbl()        {}
+func (r res_WatchTbl_Cancel) is_resWatchTbl()        {}
+func (r req_WatchTbl_Notify) is_reqWatchTbl()        {}
+func (r res_WatchTbl_Notify) is_resWatchTbl()        {}
+func (r req_WatchTbl_UnmountDevice) is_reqWatchTbl() {}
+func (r res_WatchTbl_UnmountDevice) is_resWatchTbl() {}
+func (r req_WatchTbl_Shutdown) is_reqWatchTbl()      {}
+func (r res_WatchTbl_Shutdown) is_resWatchTbl()      {}
+
+// Type check request/response types
+var _ reqWatchTbl = req_WatchTbl_Watch{}
+var _ resWatchTbl = res_WatchTbl_Watch{}
+var _ reqWatchTbl = req_WatchTbl_Cancel{}
+var _ resWatchTbl = res_WatchTbl_Cancel{}
+var _ reqWatchTbl = req_WatchTbl_Notify{}
+var _ resWatchTbl = res_WatchTbl_Notify{}
+var _ reqWatchTbl = req_WatchTbl_UnmountDevice{}
+var _ resWatchTbl = res_WatchTbl_UnmountDevice{}
+var _ reqWatchTbl = req_WatchTbl_Shutdown{}
+var _ resWatchTbl = res_WatchTbl_Shutdown{}
+
+func (s *server_WatchTbl) Watch(devnum, inum, mask int) (int, <-chan common.Event) {
+	s.in <- req_WatchTbl_Watch{devnum, inum, mask}
+	result := (<-s.out).(res_WatchTbl_Watch)
+	return result.Arg0, result.Arg1
+}
+func (s *server_WatchTbl) Cancel(id int) {
+	s.in <- req_WatchTbl_Cancel{id}
+	<-s.out
+}
+func (s *server_WatchTbl) Notify(devnum, inum int, event common.Event) {
+	s.in <- req_WatchTbl_Notify{devnum, inum, event}
+	<-s.out
+}
+func (s *server_WatchTbl) UnmountDevice(devnum int) {
+	s.in <- req_WatchTbl_UnmountDevice{devnum}
+	<-s.out
+}
+func (s *server_WatchTbl) Shutdown() error {
+	s.in <- req_WatchTbl_Shutdown{}
+	result := (<-s.out).(res_WatchTbl_Shutdown)
+	return result.Arg0
+}
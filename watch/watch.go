@@ -0,0 +1,104 @@
+package watch
+
+import (
+	"github.com/jnwhiteh/minixfs/common"
+)
+
+// The number of events that can be waiting on a single watch. Any further
+// events are dropped until the watcher catches up, rather than holding up
+// the file system.
+const QUEUE_SIZE = 64
+
+// A watch on a single inode, identified by its device and inode number
+type watch struct {
+	devnum int               // the device containing the inode
+	inum   int               // the inode being watched
+	mask   int               // the events the watcher is interested in
+	events chan common.Event // the events waiting to be received
+}
+
+type server_WatchTbl struct {
+	watches map[int]*watch // the active watches, by id
+	next    int            // the id of the next watch to be added
+
+	in  chan reqWatchTbl
+	out chan resWatchTbl
+}
+
+func NewWatchTbl() common.WatchTbl {
+	wtable := &server_WatchTbl{
+		make(map[int]*watch),
+		1,
+		make(chan reqWatchTbl),
+		make(chan resWatchTbl),
+	}
+
+	go wtable.loop()
+	return wtable
+}
+
+func (wtable *server_WatchTbl) loop() {
+	alive := true
+	for alive {
+		req := <-wtable.in
+		switch req := req.(type) {
+		case req_WatchTbl_Watch:
+			w := &watch{req.devnum, req.inum, req.mask, make(chan common.Event, QUEUE_SIZE)}
+			id := wtable.next
+			wtable.next++
+			wtable.watches[id] = w
+			wtable.out <- res_WatchTbl_Watch{id, w.events}
+		case req_WatchTbl_Cancel:
+			wtable.remove(req.id)
+			wtable.out <- res_WatchTbl_Cancel{}
+		case req_WatchTbl_Notify:
+			// Once a file has been deleted the inode number may be reused,
+			// so the watch ends with the deletion.
+			gone := req.event.Mask == common.IN_DELETE && req.event.Name == ""
+			for id, w := range wtable.watches {
+				if w.devnum != req.devnum || w.inum != req.inum {
+					continue
+				}
+				if w.mask&req.event.Mask != 0 {
+					w.send(req.event)
+				}
+				if gone {
+					wtable.remove(id)
+				}
+			}
+			wtable.out <- res_WatchTbl_Notify{}
+		case req_WatchTbl_UnmountDevice:
+			// Watchers are always told about an unmount, since it ends the
+			// watch.
+			for id, w := range wtable.watches {
+				if w.devnum == req.devnum {
+					w.send(common.Event{Mask: common.IN_UNMOUNT})
+					wtable.remove(id)
+				}
+			}
+			wtable.out <- res_WatchTbl_UnmountDevice{}
+		case req_WatchTbl_Shutdown:
+			for id := range wtable.watches {
+				wtable.remove(id)
+			}
+			alive = false
+			wtable.out <- res_WatchTbl_Shutdown{nil}
+		}
+	}
+}
+
+// Remove a watch, closing its channel so the watcher knows it has ended
+func (wtable *server_WatchTbl) remove(id int) {
+	if w, ok := wtable.watches[id]; ok {
+		close(w.events)
+		delete(wtable.watches, id)
+	}
+}
+
+// Queue an event for the watcher, dropping it if the queue is full
+func (w *watch) send(event common.Event) {
+	select {
+	case w.events <- event:
+	default:
+	}
+}